@@ -15,9 +15,15 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
+	"time"
 
 	"emperror.dev/errors"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
 	internalVault "github.com/bank-vaults/bank-vaults/internal/vault"
@@ -36,9 +42,65 @@ import (
 	"github.com/bank-vaults/bank-vaults/pkg/kv/oci"
 	"github.com/bank-vaults/bank-vaults/pkg/kv/ocikms"
 	"github.com/bank-vaults/bank-vaults/pkg/kv/s3"
+	"github.com/bank-vaults/bank-vaults/pkg/kv/split"
 	kvvault "github.com/bank-vaults/bank-vaults/pkg/kv/vault"
 )
 
+// keyStoreFlagDefaults holds the default values of the root command flags which configure the
+// key store, they are collected before the command line is parsed.
+var keyStoreFlagDefaults = map[string]interface{}{}
+
+// collectKeyStoreFlagDefaults collects the default values of the key store flags, it has to be
+// called after every key store flag is registered.
+func collectKeyStoreFlagDefaults() {
+	// These flags don't configure the key store itself
+	skipped := map[string]bool{
+		cfgSecretShares:             true,
+		cfgSecretThreshold:          true,
+		cfgStoreRootToken:           true,
+		cfgEphemeralRootToken:       true,
+		cfgPreFlightChecks:          true,
+		cfgInitPGPKeys:              true,
+		cfgInitPGPShareDestinations: true,
+		cfgOnce:                     true,
+		cfgOperationTimeout:         true,
+	}
+
+	flags := rootCmd.PersistentFlags()
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if skipped[flag.Name] {
+			return
+		}
+
+		switch flag.Value.Type() {
+		case "string":
+			keyStoreFlagDefaults[flag.Name], _ = flags.GetString(flag.Name)
+		case "int":
+			keyStoreFlagDefaults[flag.Name], _ = flags.GetInt(flag.Name)
+		case "stringSlice":
+			keyStoreFlagDefaults[flag.Name], _ = flags.GetStringSlice(flag.Name)
+		case "stringToString":
+			keyStoreFlagDefaults[flag.Name], _ = flags.GetStringToString(flag.Name)
+		}
+	})
+}
+
+// keyStoreConfigFromFile reads a key store configuration from a file, which has the same keys as
+// the key store flags (eg. mode: aws-kms-s3), the missing keys default to the flag defaults.
+func keyStoreConfigFromFile(path string) (*viper.Viper, error) {
+	cfg := viper.New()
+	for key, defaultValue := range keyStoreFlagDefaults {
+		cfg.SetDefault(key, defaultValue)
+	}
+
+	cfg.SetConfigFile(path)
+	if err := cfg.ReadInConfig(); err != nil {
+		return nil, errors.Wrapf(err, "error reading key store config file: %s", path)
+	}
+
+	return cfg, nil
+}
+
 func vaultConfigForConfig(c *viper.Viper) internalVault.Config {
 	return internalVault.Config{
 		SecretShares:    c.GetInt(cfgSecretShares),
@@ -47,7 +109,55 @@ func vaultConfigForConfig(c *viper.Viper) internalVault.Config {
 		InitRootToken:  c.GetString(cfgInitRootToken),
 		StoreRootToken: c.GetBool(cfgStoreRootToken),
 
+		EphemeralRootToken: c.GetBool(cfgEphemeralRootToken),
+		DevMode:            c.GetString(cfgMode) == cfgModeValueDev,
+		BootstrapAuth: internalVault.BootstrapAuthConfig{
+			Method:                  c.GetString(cfgBootstrapAuthMethod),
+			Path:                    c.GetString(cfgBootstrapAuthPath),
+			Role:                    c.GetString(cfgBootstrapAuthRole),
+			RoleID:                  c.GetString(cfgBootstrapAuthRoleID),
+			SecretIDFile:            c.GetString(cfgBootstrapAuthSecretIDFile),
+			ServiceAccountTokenFile: c.GetString(cfgBootstrapAuthServiceAccountTokenFile),
+			JWTFile:                 c.GetString(cfgBootstrapAuthJWTFile),
+			TokenFile:               c.GetString(cfgBootstrapAuthTokenFile),
+		},
+
 		PreFlightChecks: c.GetBool(cfgPreFlightChecks),
+
+		InitPGPKeys:              c.GetStringSlice(cfgInitPGPKeys),
+		InitPGPShareDestinations: c.GetStringSlice(cfgInitPGPShareDestinations),
+
+		ConfigureWorkers: c.GetInt(cfgWorkers),
+	}
+}
+
+// operationContext returns a context for a single init/unseal/rekey/configure
+// operation, which is bounded by the configured operation timeout.
+func operationContext(ctx context.Context, cfg *viper.Viper) (context.Context, context.CancelFunc) {
+	if timeout := cfg.GetDuration(cfgOperationTimeout); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// runOperation runs a single init/unseal/rekey/configure operation bounded by
+// the configured operation timeout.
+func runOperation(ctx context.Context, cfg *viper.Viper, operation func(context.Context) error) error {
+	ctx, cancel := operationContext(ctx, cfg)
+	defer cancel()
+
+	return operation(ctx)
+}
+
+// sleepContext waits for the given duration or until the context is done,
+// it returns false if the context is done.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
 	}
 }
 
@@ -78,6 +188,70 @@ func correctValues(flags, choices []string) bool {
 }
 
 func kvStoreForConfig(cfg *viper.Viper) (kv.Service, error) {
+	store, err := kvStoreForMode(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	shareStoreConfigs := cfg.GetStringSlice(cfgUnsealShareStores)
+	if len(shareStoreConfigs) == 0 {
+		return store, nil
+	}
+
+	stores := []kv.Service{store}
+	for _, path := range shareStoreConfigs {
+		shareStoreConfig, err := keyStoreConfigFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		shareStore, err := kvStoreForMode(shareStoreConfig)
+		if err != nil {
+			// The shares of the other key stores may still be enough to unseal, but init fails
+			slog.Warn(fmt.Sprintf("key store of %s is unavailable: %s", path, err.Error()))
+			shareStore = unavailableStore{err: err}
+		}
+
+		stores = append(stores, shareStore)
+	}
+
+	mapping := map[int]int{}
+	for share, store := range cfg.GetStringMapString(cfgUnsealShareMapping) {
+		shareIndex, err := strconv.Atoi(share)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid share index in unseal share mapping: %s", share)
+		}
+
+		storeIndex, err := strconv.Atoi(store)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key store index in unseal share mapping: %s", store)
+		}
+
+		mapping[shareIndex] = storeIndex
+	}
+
+	splitStore, err := split.New(stores, mapping)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating split kv store")
+	}
+
+	return splitStore, nil
+}
+
+// unavailableStore stands in for a key store which couldn't be created
+type unavailableStore struct {
+	err error
+}
+
+func (u unavailableStore) Set(string, []byte) error {
+	return errors.WrapIf(u.err, "key store is unavailable")
+}
+
+func (u unavailableStore) Get(string) ([]byte, error) {
+	return nil, errors.WrapIf(u.err, "key store is unavailable")
+}
+
+func kvStoreForMode(cfg *viper.Viper) (kv.Service, error) {
 	switch mode := cfg.GetString(cfgMode); mode {
 	case cfgModeValueGoogleCloudKMSGCS:
 		gcs, err := gcs.New(
@@ -177,6 +351,15 @@ func kvStoreForConfig(cfg *viper.Viper) (kv.Service, error) {
 			}
 		}
 
+		if quorum := cfg.GetInt(cfgAWSS3Quorum); quorum > 0 {
+			quorumService, err := multi.NewQuorum(services, quorum)
+			if err != nil {
+				return nil, errors.Wrap(err, "error creating AWS quorum kv store")
+			}
+
+			return quorumService, nil
+		}
+
 		return multi.New(services), nil
 
 	case cfgModeValueAzureKeyVault:
@@ -15,6 +15,8 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
@@ -22,6 +24,7 @@ import (
 	"strings"
 	"time"
 
+	"emperror.dev/errors"
 	"github.com/bank-vaults/internal/configuration"
 	"github.com/bank-vaults/vault-sdk/vault"
 	"github.com/fsnotify/fsnotify"
@@ -37,6 +40,22 @@ const (
 	cfgVaultConfigFile = "vault-config-file"
 	cfgFatal           = "fatal"
 	cfgDisableMetrics  = "disable-metrics"
+	cfgDryRun          = "dry-run"
+	cfgPlanOutput      = "plan-output"
+	cfgDriftPeriod     = "drift-check-period"
+	cfgDriftSelfHeal   = "drift-self-heal"
+	cfgWorkers         = "configure-workers"
+)
+
+const (
+	cfgBootstrapAuthMethod                  = "bootstrap-auth-method"
+	cfgBootstrapAuthPath                    = "bootstrap-auth-path"
+	cfgBootstrapAuthRole                    = "bootstrap-auth-role"
+	cfgBootstrapAuthRoleID                  = "bootstrap-auth-role-id"
+	cfgBootstrapAuthSecretIDFile            = "bootstrap-auth-secret-id-file"
+	cfgBootstrapAuthServiceAccountTokenFile = "bootstrap-auth-service-account-token-file"
+	cfgBootstrapAuthJWTFile                 = "bootstrap-auth-jwt-file"
+	cfgBootstrapAuthTokenFile               = "bootstrap-auth-token-file"
 )
 
 type configFile struct {
@@ -50,14 +69,18 @@ var configureCmd = &cobra.Command{
 	Long: `This configuration is an extension to what is available through the Vault configuration:
 			https://www.vaultproject.io/docs/configuration/index.html. With this it is possible to
 			configure secret engines, auth methods, etc...`,
-	Run: func(_ *cobra.Command, _ []string) {
+	Run: func(cmd *cobra.Command, _ []string) {
 		var unsealConfig unsealCfg
 
+		ctx := cmd.Context()
+
 		runOnce := c.GetBool(cfgOnce)
 		errorFatal := c.GetBool(cfgFatal)
 		unsealConfig.unsealPeriod = c.GetDuration(cfgUnsealPeriod)
 		vaultConfigFiles := c.GetStringSlice(cfgVaultConfigFile)
-		disableMetrics := c.GetBool(cfgDisableMetrics)
+		disableMetrics := c.GetBool(cfgDisableMetrics) || c.GetBool(cfgDryRun)
+		driftPeriod := c.GetDuration(cfgDriftPeriod)
+		driftSelfHeal := c.GetBool(cfgDriftSelfHeal)
 
 		store, err := kvStoreForConfig(c)
 		if err != nil {
@@ -71,7 +94,9 @@ var configureCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		v, err := internalVault.New(store, cl, vaultConfigForConfig(c))
+		vaultConfig := vaultConfigForConfig(c)
+
+		v, err := internalVault.New(store, cl, vaultConfig)
 		if err != nil {
 			slog.Error(fmt.Sprintf("error creating vault helper: %s", err.Error()))
 			os.Exit(1)
@@ -95,11 +120,28 @@ var configureCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		if c.GetBool(cfgDryRun) {
+			err := planConfigurations(ctx, v, parser, vaultConfigFiles, c.GetString(cfgPlanOutput))
+			if err != nil {
+				slog.Error(fmt.Sprintf("error planning configuration: %s", err.Error()))
+				os.Exit(1)
+			}
+
+			return
+		}
+
 		configurations := make(chan *configFile, len(vaultConfigFiles))
 
+		parseFailed := false
 		for i, vaultConfigFile := range vaultConfigFiles {
 			vaultConfigFiles[i] = filepath.Clean(vaultConfigFile)
-			configurations <- parseConfiguration(parser, vaultConfigFile)
+			if !queueConfiguration(parser, vaultConfigFiles[i], configurations) {
+				if errorFatal {
+					os.Exit(1)
+				}
+
+				parseFailed = true
+			}
 		}
 
 		if !runOnce {
@@ -114,6 +156,23 @@ var configureCmd = &cobra.Command{
 			close(configurations)
 		}
 
+		// The configurations which were applied successfully, checked for drift periodically
+		appliedConfigurations := make(map[string]*configFile)
+
+		// The drift checks read the state of Vault, which never runs the generate-root process
+		if driftPeriod > 0 && !vaultConfig.PlansWithoutRootGeneration() {
+			slog.Warn("drift checks need a stored root token or a bootstrap auth method, drift checks are disabled")
+			driftPeriod = 0
+		}
+
+		var driftCheck <-chan time.Time
+		if driftPeriod > 0 && !runOnce {
+			ticker := time.NewTicker(driftPeriod)
+			defer ticker.Stop()
+
+			driftCheck = ticker.C
+		}
+
 		// Handle backoff for configuration errors
 		b := &backoff.Backoff{
 			Min:    500 * time.Millisecond,
@@ -122,7 +181,26 @@ var configureCmd = &cobra.Command{
 			Jitter: false,
 		}
 
-		for config := range configurations {
+		for {
+			var config *configFile
+			select {
+			case <-ctx.Done():
+				slog.Info("stopped configuring vault")
+				return
+			case cfg, ok := <-configurations:
+				if !ok {
+					// The files which couldn't be parsed were not applied
+					if parseFailed {
+						os.Exit(1)
+					}
+
+					return
+				}
+				config = cfg
+			case <-driftCheck:
+				checkConfigurationDrift(ctx, v, appliedConfigurations, driftSelfHeal, configurations)
+				continue
+			}
 
 			slog.Info(fmt.Sprintf("applying config file: %s", config.Path))
 
@@ -132,7 +210,9 @@ var configureCmd = &cobra.Command{
 					sealed, err := v.Sealed()
 					if err != nil {
 						slog.Error(fmt.Sprintf("error checking if vault is sealed: %s, waiting %s before trying again...", err.Error(), unsealConfig.unsealPeriod))
-						time.Sleep(unsealConfig.unsealPeriod)
+						if !sleepContext(ctx, unsealConfig.unsealPeriod) {
+							return
+						}
 
 						continue
 					}
@@ -140,14 +220,19 @@ var configureCmd = &cobra.Command{
 					// If vault is sealed, we stop here and wait another unsealPeriod
 					if sealed {
 						slog.Info(fmt.Sprintf("vault is sealed, waiting %s before trying again...", unsealConfig.unsealPeriod))
-						time.Sleep(unsealConfig.unsealPeriod)
+						if !sleepContext(ctx, unsealConfig.unsealPeriod) {
+							return
+						}
 
 						continue
 					}
 
 					slog.Info("vault is unsealed, configuring...")
 
-					if err = v.Configure(config.Data); err != nil {
+					err = runOperation(ctx, c, func(ctx context.Context) error {
+						return v.Configure(ctx, config.Data)
+					})
+					if err != nil {
 						slog.Error(fmt.Sprintf("error configuring vault: %s", err.Error()))
 						if errorFatal {
 							os.Exit(1)
@@ -163,6 +248,7 @@ var configureCmd = &cobra.Command{
 					// On *any* successful configuration reset the backoff
 					b.Reset()
 					successfulConfigurationsCount++
+					appliedConfigurations[config.Path] = config
 					slog.Info("successfully configured vault")
 
 					return
@@ -172,6 +258,60 @@ var configureCmd = &cobra.Command{
 	},
 }
 
+type configPlan struct {
+	Path string `json:"path"`
+	*internalVault.Plan
+}
+
+// planConfigurations prints the changes the configuration files would make in Vault, without applying them
+func planConfigurations(ctx context.Context, v internalVault.Vault, parser multiparser.Parser, vaultConfigFiles []string, output string) error {
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unsupported plan output: %s", output)
+	}
+
+	sealed, err := v.Sealed()
+	if err != nil {
+		return fmt.Errorf("error checking if vault is sealed: %w", err)
+	}
+	if sealed {
+		return errors.New("vault is sealed")
+	}
+
+	plans := make([]configPlan, 0, len(vaultConfigFiles))
+	for _, vaultConfigFile := range vaultConfigFiles {
+		config, err := parseConfiguration(parser, filepath.Clean(vaultConfigFile))
+		if err != nil {
+			return err
+		}
+
+		var plan *internalVault.Plan
+		err = runOperation(ctx, c, func(ctx context.Context) error {
+			var err error
+			plan, err = v.Plan(ctx, config.Data)
+
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error planning %s: %w", config.Path, err)
+		}
+
+		plans = append(plans, configPlan{Path: config.Path, Plan: plan})
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(plans)
+	}
+
+	for _, plan := range plans {
+		fmt.Printf("Config file: %s\n%s\n", plan.Path, plan.Plan)
+	}
+
+	return nil
+}
+
 func handleConfigurationError(parser multiparser.Parser, vaultConfigFile string, configurations chan<- *configFile, sleepTime time.Duration) {
 	// This handler will sleep for a exponential backoff amount of time and re-inject the failed configuration into the
 	// configurations channel to be re-applied to vault
@@ -179,7 +319,28 @@ func handleConfigurationError(parser multiparser.Parser, vaultConfigFile string,
 	// non recoverable errors will be retried and keep failing every MAX BACKOFF seconds, increasing the error counters ont he vault-configurator pod.
 	slog.Info(fmt.Sprintf("Failed applying configuration file: %s , sleeping for %s before trying again", vaultConfigFile, sleepTime))
 	time.Sleep(sleepTime)
-	configurations <- parseConfiguration(parser, vaultConfigFile)
+	queueConfiguration(parser, vaultConfigFile, configurations)
+}
+
+// queueConfiguration parses the configuration file and queues it for applying. If the file can't be parsed, the
+// error is reported and the last successfully parsed configuration of the file stays applied until the file changes
+// again, it returns false in this case.
+func queueConfiguration(parser multiparser.Parser, vaultConfigFile string, configurations chan<- *configFile) bool {
+	config, err := parseConfiguration(parser, vaultConfigFile)
+	if err != nil {
+		slog.Error("error parsing vault config file, keeping the last applied configuration until it changes",
+			slog.String("file", vaultConfigFile),
+			slog.String("error", err.Error()),
+		)
+		setConfigParseError(vaultConfigFile, true)
+
+		return false
+	}
+
+	setConfigParseError(vaultConfigFile, false)
+	configurations <- config
+
+	return true
 }
 
 func watchConfigurations(parser multiparser.Parser, vaultConfigFiles []string, configurations chan<- *configFile) error {
@@ -221,11 +382,11 @@ func watchConfigurations(parser multiparser.Parser, vaultConfigFiles []string, c
 			// For Kubernetes configMaps we need to watch for CREATE on the "..data"
 			if event.Op&fsnotify.Write == fsnotify.Write && stringInSlice(vaultConfigFiles, filepath.Clean(event.Name)) {
 				slog.Info(fmt.Sprintf("file has changed: %s", event.Name))
-				configurations <- parseConfiguration(parser, filepath.Clean(event.Name))
+				queueConfiguration(parser, filepath.Clean(event.Name), configurations)
 			} else if event.Op&fsnotify.Create == fsnotify.Create && filepath.Base(event.Name) == "..data" {
 				for _, fileName := range configFileDirs[filepath.Dir(event.Name)] {
 					slog.Info(fmt.Sprintf("ConfigMap has changed, reparsing: %s", fileName))
-					configurations <- parseConfiguration(parser, fileName)
+					queueConfiguration(parser, fileName, configurations)
 				}
 			}
 		case err := <-watcher.Errors:
@@ -234,33 +395,40 @@ func watchConfigurations(parser multiparser.Parser, vaultConfigFiles []string, c
 	}
 }
 
-func parseConfiguration(parser multiparser.Parser, vaultConfigFile string) *configFile {
+// renderConfiguration reads the configuration file and executes its env templating
+func renderConfiguration(vaultConfigFile string) ([]byte, error) {
 	// Read file
 	vaultConfig, err := os.ReadFile(vaultConfigFile)
 	if err != nil {
-		slog.Error(fmt.Sprintf("error reading vault config template: %s", err.Error()))
-		os.Exit(1)
+		return nil, fmt.Errorf("error reading vault config template: %w", err)
 	}
 
 	// Replace env templating data
 	templater := configuration.NewTemplater(configuration.DefaultLeftDelimiter, configuration.DefaultRightDelimiter)
 	buffer, err := templater.EnvTemplate(string(vaultConfig))
 	if err != nil {
-		slog.Error(fmt.Sprintf("error executing vault config template: %s", err.Error()))
-		os.Exit(1)
+		return nil, fmt.Errorf("error executing vault config template: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func parseConfiguration(parser multiparser.Parser, vaultConfigFile string) (*configFile, error) {
+	vaultConfig, err := renderConfiguration(vaultConfigFile)
+	if err != nil {
+		return nil, err
 	}
 
 	// Load raw data into map
 	var data map[string]interface{}
-	if err := parser.Parse(buffer.Bytes(), &data); err != nil {
-		slog.Error(fmt.Sprintf("error parsing vault config file: %v", err))
-		os.Exit(1)
+	if err := parser.Parse(vaultConfig, &data); err != nil {
+		return nil, fmt.Errorf("error parsing vault config file %s: %w", vaultConfigFile, err)
 	}
 
 	return &configFile{
 		Path: vaultConfigFile,
 		Data: data,
-	}
+	}, nil
 }
 
 func stringInSlice(list []string, match string) bool {
@@ -276,6 +444,19 @@ func init() {
 	configBoolVar(configureCmd, cfgFatal, false, "Make configuration errors fatal to the configurator")
 	configStringSliceVar(configureCmd, cfgVaultConfigFile, []string{internalVault.DefaultConfigFile}, "The filename of the YAML/JSON Vault configuration")
 	configBoolVar(configureCmd, cfgDisableMetrics, false, "Disable configurer metrics")
+	configBoolVar(configureCmd, cfgDryRun, false, "Print the changes the configuration would make in Vault without applying them")
+	configStringVar(configureCmd, cfgPlanOutput, "text", "Output format of the dry-run plan: text or json")
+	configDurationVar(configureCmd, cfgDriftPeriod, 0, "How often to compare the live state of Vault with the applied configuration, disabled if 0, it needs a stored root token or a bootstrap auth method")
+	configBoolVar(configureCmd, cfgDriftSelfHeal, false, "Reapply the configuration when drift is detected")
+	configIntVar(configureCmd, cfgWorkers, 1, "How many independent resources of the configuration are applied concurrently")
+	configStringVar(configureCmd, cfgBootstrapAuthMethod, "", "Auth method to obtain the token of the configuration with instead of the root token: approle, kubernetes, jwt or token (read from a file), see the policy command for the policy it needs")
+	configStringVar(configureCmd, cfgBootstrapAuthPath, "", "Mount path of the bootstrap auth method, defaults to its type")
+	configStringVar(configureCmd, cfgBootstrapAuthRole, "", "Role to log in with to the bootstrap Kubernetes or JWT auth method")
+	configStringVar(configureCmd, cfgBootstrapAuthRoleID, "", "Role ID to log in with to the bootstrap AppRole auth method")
+	configStringVar(configureCmd, cfgBootstrapAuthSecretIDFile, "", "File holding the secret ID to log in with to the bootstrap AppRole auth method")
+	configStringVar(configureCmd, cfgBootstrapAuthServiceAccountTokenFile, "", "File holding the service account token to log in with to the bootstrap Kubernetes auth method, defaults to the token of the pod")
+	configStringVar(configureCmd, cfgBootstrapAuthJWTFile, "", "File holding the JWT to log in with to the bootstrap JWT auth method")
+	configStringVar(configureCmd, cfgBootstrapAuthTokenFile, "", "File holding the token to configure with when the bootstrap auth method is token, it is not revoked")
 
 	rootCmd.AddCommand(configureCmd)
 }
@@ -0,0 +1,107 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	internalVault "github.com/bank-vaults/bank-vaults/internal/vault"
+)
+
+// fileConfigDrift holds the drifted resources each config file reported in its last successful
+// drift check, they stay reported while the drift check of the file fails.
+var fileConfigDrift = map[string][]configDriftKey{}
+
+// checkConfigurationDrift compares the applied configurations with the live state of Vault, reports
+// the drifted resources in the metrics and queues the drifted configurations for reapplying if
+// self-healing is enabled.
+func checkConfigurationDrift(ctx context.Context, v internalVault.Vault, appliedConfigurations map[string]*configFile, selfHeal bool, configurations chan<- *configFile) {
+	sealed, err := v.Sealed()
+	if err != nil {
+		slog.Error(fmt.Sprintf("error checking if vault is sealed: %s, skipping drift check", err.Error()))
+		return
+	}
+	if sealed {
+		slog.Info("vault is sealed, skipping drift check")
+		return
+	}
+
+	paths := make([]string, 0, len(appliedConfigurations))
+	for path := range appliedConfigurations {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	drift := map[configDriftKey]bool{}
+	for _, path := range paths {
+		config := appliedConfigurations[path]
+
+		var plan *internalVault.Plan
+		err := runOperation(ctx, c, func(ctx context.Context) error {
+			var err error
+			plan, err = v.Plan(ctx, config.Data)
+
+			return err
+		})
+		if err != nil {
+			slog.Error(fmt.Sprintf("error checking drift of config file %s: %s", path, err.Error()))
+			for _, key := range fileConfigDrift[path] {
+				drift[key] = true
+			}
+			continue
+		}
+
+		var fileDrift []configDriftKey
+		for _, change := range plan.Changes {
+			// The change can't be determined from the live state, so it's not a drift
+			if change.Reason != "" {
+				continue
+			}
+
+			slog.Warn("configuration drift detected",
+				slog.String("file", path),
+				slog.String("action", string(change.Action)),
+				slog.String("kind", change.Kind),
+				slog.String("name", change.Name),
+				slog.Any("fields", change.Fields),
+			)
+
+			key := configDriftKey{Kind: change.Kind, Name: change.Name}
+			drift[key] = true
+			fileDrift = append(fileDrift, key)
+		}
+		fileConfigDrift[path] = fileDrift
+
+		if len(fileDrift) > 0 && selfHeal {
+			slog.Info(fmt.Sprintf("reapplying drifted config file: %s", path))
+
+			// The configurations are consumed by the caller, so they can't be queued synchronously
+			go func() {
+				configurations <- config
+			}()
+		}
+	}
+
+	for path := range fileConfigDrift {
+		if _, ok := appliedConfigurations[path]; !ok {
+			delete(fileConfigDrift, path)
+		}
+	}
+
+	setConfigDrift(drift)
+}
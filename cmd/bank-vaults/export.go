@@ -0,0 +1,100 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/bank-vaults/vault-sdk/vault"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	internalVault "github.com/bank-vaults/bank-vaults/internal/vault"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [vault-config-file]",
+	Short: "Exports the current state of a Vault as a YAML configuration file",
+	Long: `This command reads the audit devices, auth methods (with their config, roles and mappings),
+identity groups and group aliases, plugins, policies and secret engines of a running Vault, and writes
+them in the configuration format of "configure", so applying the exported file doesn't change anything.
+It helps adopting an existing, manually configured Vault.
+
+Secrets Vault never returns (like passwords and the credentials in the configuration of auth methods)
+and the configuration of the secret engines are not exported, they have to be added by hand.
+
+The configuration is written to the given file, or to the standard output.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := kvStoreForConfig(c)
+		if err != nil {
+			slog.Error(fmt.Sprintf("error creating kv store: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		cl, err := vault.NewRawClient()
+		if err != nil {
+			slog.Error(fmt.Sprintf("error connecting to vault: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		v, err := internalVault.New(store, cl, vaultConfigForConfig(c))
+		if err != nil {
+			slog.Error(fmt.Sprintf("error creating vault helper: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		sealed, err := v.Sealed()
+		if err != nil {
+			slog.Error(fmt.Sprintf("error checking if vault is sealed: %s", err.Error()))
+			os.Exit(1)
+		}
+		if sealed {
+			slog.Error("vault is sealed, it can't be exported")
+			os.Exit(1)
+		}
+
+		config, err := v.Export(cmd.Context())
+		if err != nil {
+			slog.Error(fmt.Sprintf("error exporting vault: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		data, err := yaml.Marshal(config)
+		if err != nil {
+			slog.Error(fmt.Sprintf("error encoding vault config: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		if len(args) == 0 {
+			fmt.Print(string(data))
+
+			return
+		}
+
+		if err := os.WriteFile(args[0], data, 0o600); err != nil {
+			slog.Error(fmt.Sprintf("error writing vault config file: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		slog.Info(fmt.Sprintf("vault config exported to %s", args[0]))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+}
@@ -29,6 +29,11 @@ const (
 	cfgInitRootToken   = "init-root-token"
 	cfgStoreRootToken  = "store-root-token"
 	cfgPreFlightChecks = "pre-flight-checks"
+
+	cfgEphemeralRootToken = "ephemeral-root-token"
+
+	cfgInitPGPKeys              = "init-pgp-keys"
+	cfgInitPGPShareDestinations = "init-pgp-share-destinations"
 )
 
 var initCmd = &cobra.Command{
@@ -39,7 +44,7 @@ run "vault init" against the target Vault instance, before encrypting and
 storing the keys in the given backend.
 
 It will not unseal the Vault instance after initializing.`,
-	Run: func(_ *cobra.Command, _ []string) {
+	Run: func(cmd *cobra.Command, _ []string) {
 		store, err := kvStoreForConfig(c)
 		if err != nil {
 			slog.Error(fmt.Sprintf("error creating kv store: %s", err.Error()))
@@ -58,7 +63,7 @@ It will not unseal the Vault instance after initializing.`,
 			os.Exit(1)
 		}
 
-		if err = v.Init(); err != nil {
+		if err = runOperation(cmd.Context(), c, v.Init); err != nil {
 			slog.Error(fmt.Sprintf("error initializing vault: %s", err.Error()))
 			os.Exit(1)
 		}
@@ -68,7 +73,10 @@ It will not unseal the Vault instance after initializing.`,
 func init() {
 	configStringVar(initCmd, cfgInitRootToken, "", "root token for the new vault cluster")
 	configBoolVar(rootCmd, cfgStoreRootToken, true, "should the root token be stored in the key store")
+	configBoolVar(rootCmd, cfgEphemeralRootToken, false, "never store the root token in the key store: the initial root token is revoked after init, configure obtains a new token for every configuration and revokes it afterwards, needs a bootstrap auth method in dev mode or with init-pgp-keys")
 	configBoolVar(rootCmd, cfgPreFlightChecks, true, "should the key store be tested first to validate access rights")
+	configStringSliceVar(rootCmd, cfgInitPGPKeys, nil, "PGP public keys to encrypt the unseal (or recovery) shares with at init, one per share: armored or binary files, k8s:[namespace/]name/key Secret references or keybase:user, the encrypted shares can't be used to unseal automatically")
+	configStringSliceVar(rootCmd, cfgInitPGPShareDestinations, nil, "where to deliver the PGP encrypted shares to, one per PGP key: a file path or a key store key prefixed with 'kv:'")
 
 	rootCmd.AddCommand(initCmd)
 }
@@ -0,0 +1,116 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	internalVault "github.com/bank-vaults/bank-vaults/internal/vault"
+)
+
+const (
+	cfgMigrateForce = "force"
+
+	// destinationFlagPrefix is prepended to the key store flags to configure the destination of a migration
+	destinationFlagPrefix = "destination-"
+)
+
+// d holds the destination key store configuration of the keys migrate command
+var d = viper.New()
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage the keys stored by bank-vaults in the key store",
+}
+
+var keysMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the root token, unseal and recovery keys to another key store",
+	Long: `This command copies the root token, the unseal and the recovery keys from the
+key store configured by the usual flags (eg. --mode k8s) to the key store configured
+by the same flags prefixed with "destination-" (eg. --destination-mode aws-kms-s3).
+
+The keys are decrypted and re-encrypted by the respective KMS services and are read
+back from the destination to verify them. Existing keys in the destination are not
+overwritten unless --force is specified.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		source, err := kvStoreForConfig(c)
+		if err != nil {
+			slog.Error(fmt.Sprintf("error creating source kv store: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		destination, err := kvStoreForConfig(d)
+		if err != nil {
+			slog.Error(fmt.Sprintf("error creating destination kv store: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		migrateConfig := internalVault.MigrateConfig{
+			SecretShares: c.GetInt(cfgSecretShares),
+			Force:        c.GetBool(cfgMigrateForce),
+		}
+
+		ctx, cancel := operationContext(cmd.Context(), c)
+		defer cancel()
+
+		keys, err := internalVault.MigrateKeys(ctx, source, destination, migrateConfig)
+		if err != nil {
+			slog.Error(fmt.Sprintf("error migrating keys: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		slog.Info(fmt.Sprintf("successfully migrated %d keys: %s", len(keys), strings.Join(keys, ", ")))
+	},
+}
+
+// configDestinationFlags registers a "destination-" prefixed copy of every key store flag of
+// the root command, the values are bound to the destination configuration.
+func configDestinationFlags(cmd *cobra.Command) {
+	d.SetEnvPrefix("bank_vaults_destination")
+	d.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	d.AutomaticEnv()
+
+	for key, defaultValue := range keyStoreFlagDefaults {
+		name := destinationFlagPrefix + key
+		description := "Destination: " + rootCmd.PersistentFlags().Lookup(key).Usage
+
+		switch defaultValue := defaultValue.(type) {
+		case string:
+			cmd.Flags().String(name, defaultValue, description)
+		case int:
+			cmd.Flags().Int(name, defaultValue, description)
+		case []string:
+			cmd.Flags().StringSlice(name, defaultValue, description)
+		case map[string]string:
+			cmd.Flags().StringToString(name, defaultValue, description)
+		}
+
+		_ = d.BindPFlag(key, cmd.Flags().Lookup(name))
+	}
+}
+
+func init() {
+	configBoolVar(keysMigrateCmd, cfgMigrateForce, false, "Overwrite the keys which already exist in the destination key store")
+
+	keysCmd.AddCommand(keysMigrateCmd)
+	rootCmd.AddCommand(keysCmd)
+}
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -73,6 +74,7 @@ const (
 	cfgAWSS3Prefix = "aws-s3-prefix"
 	cfgAWSS3Region = "aws-s3-region"
 	cfgAWS3SSEAlgo = "aws-s3-sse-algo"
+	cfgAWSS3Quorum = "aws-s3-quorum"
 )
 
 const cfgAzureKeyVaultName = "azure-key-vault-name"
@@ -121,8 +123,14 @@ const (
 const cfgFilePath = "file-path"
 
 const (
-	cfgUnsealPeriod = "unseal-period"
-	cfgOnce         = "once"
+	cfgUnsealShareStores  = "unseal-share-stores"
+	cfgUnsealShareMapping = "unseal-share-mapping"
+)
+
+const (
+	cfgUnsealPeriod     = "unseal-period"
+	cfgOnce             = "once"
+	cfgOperationTimeout = "operation-timeout"
 )
 
 var rootCmd = &cobra.Command{
@@ -132,16 +140,12 @@ var rootCmd = &cobra.Command{
 }
 
 func execute() {
-	// Handle signals to prevent bad exit codes on `docker stop`.
-	// TODO: probably a more sophisticated exit procedure should be implemented in the future.
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGABRT)
-	go func() {
-		<-sigs
-		os.Exit(0)
-	}()
-
-	if err := rootCmd.Execute(); err != nil {
+	// Handle signals to prevent bad exit codes on `docker stop`, the context is
+	// canceled so in-flight Vault and key store calls are stopped and the commands return.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGABRT)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		slog.Error(fmt.Sprintf("error executing command: %s", err.Error()))
 		os.Exit(1)
 	}
@@ -238,6 +242,7 @@ func init() {
 	configStringSliceVar(rootCmd, cfgAWSS3Bucket, nil, "The name of the AWS S3 bucket to store values in")
 	configStringVar(rootCmd, cfgAWSS3Prefix, "", "The prefix to use for storing values in AWS S3")
 	configStringSliceVar(rootCmd, cfgAWS3SSEAlgo, []string{""}, "The algorithm to use for the S3 SSE")
+	configIntVar(rootCmd, cfgAWSS3Quorum, 0, "The number of AWS S3 buckets which have to acknowledge a write and agree on a read, 0 means writing all buckets and reading the first available one")
 
 	// Azure Key Vault flags
 	configStringVar(rootCmd, cfgAzureKeyVaultName, "", "The name of the Azure Key Vault to encrypt and store values in")
@@ -285,10 +290,19 @@ func init() {
 	// File flags
 	configStringVar(rootCmd, cfgFilePath, "", "The path prefix of the files where to store values in")
 
+	// Unseal share splitting flags
+	configStringSliceVar(rootCmd, cfgUnsealShareStores, nil, "Config files of additional key stores to split the unseal and recovery shares across, the key store configured by the flags is the first one")
+	configStringMapVar(rootCmd, cfgUnsealShareMapping, map[string]string{}, "Mapping of unseal and recovery shares to key store indexes (eg. 0=0,1=0,2=1), the unmapped shares are distributed round-robin")
+
 	// Misc common flags
-	configBoolVar(rootCmd, cfgOnce, false, "Run configure/unseal only once")
+	configBoolVar(rootCmd, cfgOnce, false, "Run configure/unseal/rotate only once")
+	configDurationVar(rootCmd, cfgOperationTimeout, 0, "Timeout of a single init/unseal/rekey/configure operation including the key store calls, 0 means no timeout")
 	configDurationVar(configureCmd, cfgUnsealPeriod, time.Second*5, "How often to attempt to unseal the Vault instance")
 	configDurationVar(configureCmd, cfgRekeyRetryPeriod, time.Second*5, "How often to attempt to rekey the Vault instance")
+
+	// These have to be called after every key store flag is registered
+	collectKeyStoreFlagDefaults()
+	configDestinationFlags(keysMigrateCmd)
 }
 
 func main() {
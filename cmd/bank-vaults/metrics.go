@@ -15,9 +15,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -55,8 +57,53 @@ var (
 		"Number of configurations files applied that failed",
 		nil, nil,
 	)
+	configParseErrors     = map[string]bool{}
+	configParseErrorsLock sync.Mutex
+	configParseErrorDesc  = prometheus.NewDesc(
+		prometheus.BuildFQName(prometheusNS, "config", "parse_error"),
+		"Is the configuration file failing to parse, its last successfully parsed version stays applied.",
+		[]string{"file"}, nil,
+	)
+	configDrift     = map[configDriftKey]bool{}
+	configDriftLock sync.Mutex
+	configDriftDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(prometheusNS, "config", "drift"),
+		"Is the live state of the configured Vault resource different from the configuration.",
+		[]string{"kind", "name"}, nil,
+	)
+	lastRotationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(prometheusNS, "rotation", "last_timestamp_seconds"),
+		"Time of the last successful root token and unseal key rotation since unix epoch in seconds.",
+		nil, nil,
+	)
 )
 
+func setConfigParseError(file string, failed bool) {
+	configParseErrorsLock.Lock()
+	defer configParseErrorsLock.Unlock()
+
+	configParseErrors[file] = failed
+}
+
+type configDriftKey struct {
+	Kind string
+	Name string
+}
+
+// setConfigDrift sets the drifted resources reported in the metrics, the resources drifted
+// earlier are kept with a zero value, so alerts can be resolved
+func setConfigDrift(drift map[configDriftKey]bool) {
+	configDriftLock.Lock()
+	defer configDriftLock.Unlock()
+
+	for key := range configDrift {
+		configDrift[key] = drift[key]
+	}
+	for key := range drift {
+		configDrift[key] = true
+	}
+}
+
 type prometheusExporter struct {
 	Vault internalVault.Vault
 	Mode  string
@@ -70,6 +117,10 @@ func (e *prometheusExporter) Describe(ch chan<- *prometheus.Desc) {
 	} else if e.Mode == "configure" {
 		ch <- successfulConfigurationsDesc
 		ch <- failedConfigurationsDesc
+		ch <- configParseErrorDesc
+		ch <- configDriftDesc
+	} else if e.Mode == "rotate" {
+		ch <- lastRotationDesc
 	}
 }
 
@@ -110,6 +161,37 @@ func (e *prometheusExporter) Collect(ch chan<- prometheus.Metric) {
 		ch <- prometheus.MustNewConstMetric(
 			failedConfigurationsDesc, prometheus.GaugeValue, failedConfigurationsCount,
 		)
+
+		configParseErrorsLock.Lock()
+		for file, failed := range configParseErrors {
+			ch <- prometheus.MustNewConstMetric(
+				configParseErrorDesc, prometheus.GaugeValue, bToF(failed), file,
+			)
+		}
+		configParseErrorsLock.Unlock()
+
+		configDriftLock.Lock()
+		for key, drifted := range configDrift {
+			ch <- prometheus.MustNewConstMetric(
+				configDriftDesc, prometheus.GaugeValue, bToF(drifted), key.Kind, key.Name,
+			)
+		}
+		configDriftLock.Unlock()
+	} else if e.Mode == "rotate" {
+		lastRotation, err := e.Vault.LastRotation(context.Background())
+		if err != nil {
+			slog.Error(fmt.Sprintf("error getting last rotation time: %s", err.Error()))
+			return
+		}
+
+		// Vault was never rotated by bank-vaults
+		if lastRotation.IsZero() {
+			return
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			lastRotationDesc, prometheus.GaugeValue, float64(lastRotation.Unix()),
+		)
 	}
 }
 
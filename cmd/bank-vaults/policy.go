@@ -0,0 +1,69 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/ramizpolic/multiparser"
+	"github.com/ramizpolic/multiparser/parser"
+	"github.com/spf13/cobra"
+
+	internalVault "github.com/bank-vaults/bank-vaults/internal/vault"
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy [vault-config-file...]",
+	Short: "Generates the minimal Vault policy needed to configure Vault with the given configuration files",
+	Long: `This command prints the HCL policy which allows exactly what "configure" needs to apply
+the given YAML/JSON configuration files (in the given order): the mounts, auth methods, policies and
+identity endpoints they touch, and the deletions of the purge config.
+
+The policy can be attached to the auth method configure logs in with (see the bootstrap-auth-* flags),
+so Vault doesn't have to be configured with a root token.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		parser, err := multiparser.New(parser.JSON, parser.YAML)
+		if err != nil {
+			slog.Error(fmt.Sprintf("error file parsers: %v", err))
+			os.Exit(1)
+		}
+
+		configs := make([]map[string]interface{}, 0, len(args))
+		for _, vaultConfigFile := range args {
+			config, err := parseConfiguration(parser, vaultConfigFile)
+			if err != nil {
+				slog.Error(err.Error())
+				os.Exit(1)
+			}
+
+			configs = append(configs, config.Data)
+		}
+
+		policy, err := internalVault.GeneratePolicy(configs...)
+		if err != nil {
+			slog.Error(fmt.Sprintf("error generating policy: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		fmt.Print(policy)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+}
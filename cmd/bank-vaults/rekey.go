@@ -15,6 +15,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -39,7 +40,7 @@ type rekeyCfg struct {
 
 var rekeyCmd = &cobra.Command{
 	Use:   "rekey",
-	Short: "Rekeying Vault using Keybase PGP keys.",
+	Short: "Rekeying Vault using PGP keys.",
 	Long: `It will continuously attempt to rekey the target Vault instance, by retrieving unseal keys
 from one of the following:
 - Google Cloud KMS keyring (backed by GCS)
@@ -47,10 +48,16 @@ from one of the following:
 - Azure Key Vault
 - Alibaba KMS (backed by OSS)
 - Kubernetes Secrets (should be used only for development purposes)
-Resulting keys will be encrypted by Keybase PGP`,
+Resulting keys will be encrypted by the given PGP keys, which can be local armored or binary files,
+Kubernetes Secret references (k8s:[namespace/]name/key) or Keybase users (keybase:user).
+Without PGP keys the unseal keys in the backend are rotated: the new keys are staged and verified
+before they replace the old ones, so Vault can still be unsealed automatically.
+The recovery keys are rekeyed instead of the unseal keys if Vault is auto-unsealed (eg. by a KMS).`,
 	Run: func(cmd *cobra.Command, args []string) {
 		var rekeyConfig rekeyCfg
 
+		ctx := cmd.Context()
+
 		rekeyConfig.rekeyRetryPeriod = c.GetDuration(cfgRekeyRetryPeriod)
 		rekeyConfig.pgpKeys = c.GetString(cfgPgpKeys)
 
@@ -76,21 +83,28 @@ Resulting keys will be encrypted by Keybase PGP`,
 		slog.Info("created vault helper")
 
 		for {
-			slog.Info("checking if unseal keys already exist...")
-			if newKeysNotExists(rekeyConfig, v) {
+			if rekeyConfig.pgpKeys == "" {
+				rotateKeys(ctx, v)
+			} else if newKeysNotExists(ctx, rekeyConfig, v) {
 				slog.Info("unseal keys do not exist, rekeying")
-				rekey(rekeyConfig, v)
+				rekey(ctx, rekeyConfig, v)
 			}
 			// wait retryPerios before trying again
 			slog.Info("waiting for retry period", "retryPeriod", rekeyConfig.rekeyRetryPeriod)
-			time.Sleep(rekeyConfig.rekeyRetryPeriod)
+			if !sleepContext(ctx, rekeyConfig.rekeyRetryPeriod) {
+				slog.Info("stopped rekeying vault")
+				return
+			}
 		}
 	},
 }
 
-func newKeysNotExists(rekeyConfig rekeyCfg, v internalVault.Vault) bool {
+func newKeysNotExists(ctx context.Context, rekeyConfig rekeyCfg, v internalVault.Vault) bool {
 	slog.Info("checking if unseal keys already exist...")
-	exists, err := v.NewUnsealKeysExists(strings.Split(rekeyConfig.pgpKeys, ","))
+	ctx, cancel := operationContext(ctx, c)
+	defer cancel()
+
+	exists, err := v.NewUnsealKeysExists(ctx, strings.Split(rekeyConfig.pgpKeys, ","))
 	if err != nil {
 		slog.Error(fmt.Sprintf("error checking if unseal keys already exist: %s", err.Error()))
 		os.Exit(1)
@@ -99,7 +113,7 @@ func newKeysNotExists(rekeyConfig rekeyCfg, v internalVault.Vault) bool {
 	return !exists
 }
 
-func rekey(rekeyConfig rekeyCfg, v internalVault.Vault) {
+func rekey(ctx context.Context, rekeyConfig rekeyCfg, v internalVault.Vault) {
 	slog.Info("checking if vault is sealed...")
 	sealed, err := v.Sealed()
 	if err != nil {
@@ -112,21 +126,50 @@ func rekey(rekeyConfig rekeyCfg, v internalVault.Vault) {
 	if !sealed {
 		slog.Info("vault is not sealed, rekeying")
 
-		if err = v.Rekey(strings.Split(rekeyConfig.pgpKeys, ",")); err != nil {
+		err = runOperation(ctx, c, func(ctx context.Context) error {
+			return v.Rekey(ctx, strings.Split(rekeyConfig.pgpKeys, ","))
+		})
+		if err != nil {
 			slog.Error(fmt.Sprintf("error rekeying vault: %s", err.Error()))
 			os.Exit(1)
 			return
 		}
 		slog.Info("successfully rekeyed vault")
-		for {
-			time.Sleep(1 * time.Second)
+		for sleepContext(ctx, 1*time.Second) {
 			slog.Info("Waiting for process to be shutted down...")
 		}
+		os.Exit(0)
+	}
+}
+
+func rotateKeys(ctx context.Context, v internalVault.Vault) {
+	slog.Info("checking if vault is sealed...")
+	sealed, err := v.Sealed()
+	if err != nil {
+		slog.Error(fmt.Sprintf("error checking if vault is sealed: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	if sealed {
+		slog.Info("vault is sealed, waiting for it to be unsealed before rotating keys")
+		return
+	}
+
+	slog.Info("vault is not sealed, rotating keys")
+	if err := runOperation(ctx, c, v.RotateKeys); err != nil {
+		slog.Error(fmt.Sprintf("error rotating keys: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	slog.Info("successfully rotated keys")
+	for sleepContext(ctx, 1*time.Second) {
+		slog.Info("Waiting for process to be shutted down...")
 	}
+	os.Exit(0)
 }
 
 func init() {
-	configStringVar(rekeyCmd, cfgPgpKeys, "", "Coma separated list of pgp keys")
+	configStringVar(rekeyCmd, cfgPgpKeys, "", "Comma separated list of PGP public keys: files, k8s:[namespace/]name/key Secret references or keybase:user")
 	configDurationVar(rekeyCmd, cfgRekeyRetryPeriod, 10*time.Second, "Retry period for rekeying")
 	rootCmd.AddCommand(rekeyCmd)
 }
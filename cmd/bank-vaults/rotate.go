@@ -0,0 +1,146 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/bank-vaults/vault-sdk/vault"
+	"github.com/spf13/cobra"
+
+	internalVault "github.com/bank-vaults/bank-vaults/internal/vault"
+)
+
+const (
+	cfgRotationPeriod      = "rotation-period"
+	cfgRotationCheckPeriod = "rotation-check-period"
+)
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Rotates the stored root token and unseal keys on a schedule.",
+	Long: `It will continuously check when the stored root token and unseal keys were last rotated,
+and rotates them when the rotation period is over:
+- the unseal keys (or the recovery keys if Vault is auto-unsealed) are rekeyed, the new keys
+  are verified before they replace the old ones in the key store
+- a new root token is generated with the generate-root process, it replaces the stored root
+  token and the old one is revoked
+The time of the last rotation is stored in the key store and exposed as the
+vault_rotation_last_timestamp_seconds metric.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		runOnce := c.GetBool(cfgOnce)
+		rotationPeriod := c.GetDuration(cfgRotationPeriod)
+		checkPeriod := c.GetDuration(cfgRotationCheckPeriod)
+
+		store, err := kvStoreForConfig(c)
+		if err != nil {
+			slog.Error(fmt.Sprintf("error creating kv store: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		cl, err := vault.NewRawClient()
+		if err != nil {
+			slog.Error(fmt.Sprintf("error connecting to vault: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		v, err := internalVault.New(store, cl, vaultConfigForConfig(c))
+		if err != nil {
+			slog.Error(fmt.Sprintf("error creating vault helper: %s", err.Error()))
+			os.Exit(1)
+		}
+
+		metrics := prometheusExporter{Vault: v, Mode: "rotate"}
+		go func() {
+			err := metrics.Run()
+			if err != nil {
+				slog.Error(fmt.Sprintf("error creating prometheus exporter: %s", err.Error()))
+				os.Exit(1)
+			}
+		}()
+
+		for {
+			wait, ok := rotateIfDue(ctx, v, rotationPeriod, checkPeriod)
+			if runOnce {
+				if !ok {
+					os.Exit(1)
+				}
+				return
+			}
+
+			if wait > checkPeriod {
+				wait = checkPeriod
+			}
+
+			slog.Info(fmt.Sprintf("waiting %s before checking the rotation again...", wait))
+			if !sleepContext(ctx, wait) {
+				slog.Info("stopped rotating vault")
+				return
+			}
+		}
+	},
+}
+
+// rotateIfDue rotates Vault if the rotation period is over since the last rotation,
+// it returns the time until the next rotation is due and false if the rotation failed.
+// A failed rotation or a sealed Vault is checked again after the check period.
+func rotateIfDue(ctx context.Context, v internalVault.Vault, rotationPeriod, checkPeriod time.Duration) (time.Duration, bool) {
+	opCtx, cancel := operationContext(ctx, c)
+	lastRotation, err := v.LastRotation(opCtx)
+	cancel()
+	if err != nil {
+		slog.Error(fmt.Sprintf("error getting last rotation time: %s", err.Error()))
+		return checkPeriod, false
+	}
+
+	if next := lastRotation.Add(rotationPeriod); time.Now().Before(next) {
+		slog.Info(fmt.Sprintf("vault was rotated at %s, next rotation is due at %s", lastRotation.Format(time.RFC3339), next.Format(time.RFC3339)))
+		return time.Until(next), true
+	}
+
+	slog.Info("checking if vault is sealed...")
+	sealed, err := v.Sealed()
+	if err != nil {
+		slog.Error(fmt.Sprintf("error checking if vault is sealed: %s", err.Error()))
+		return checkPeriod, false
+	}
+
+	if sealed {
+		slog.Info("vault is sealed, waiting for it to be unsealed before rotating")
+		return checkPeriod, true
+	}
+
+	slog.Info("vault is not sealed, rotating root token and keys...")
+	if err := runOperation(ctx, c, v.Rotate); err != nil {
+		slog.Error(fmt.Sprintf("error rotating vault: %s", err.Error()))
+		return checkPeriod, false
+	}
+
+	slog.Info("successfully rotated vault")
+
+	return rotationPeriod, true
+}
+
+func init() {
+	configDurationVar(rotateCmd, cfgRotationPeriod, 90*24*time.Hour, "How often to rotate the stored root token and unseal keys")
+	configDurationVar(rotateCmd, cfgRotationCheckPeriod, time.Hour, "How often to check whether a rotation is due, or to retry a failed rotation")
+	rootCmd.AddCommand(rotateCmd)
+}
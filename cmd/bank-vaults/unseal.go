@@ -15,6 +15,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -56,9 +57,11 @@ from one of the following:
 - Azure Key Vault
 - Alibaba KMS (backed by OSS)
 - Kubernetes Secrets (should be used only for development purposes)`,
-	Run: func(_ *cobra.Command, _ []string) {
+	Run: func(cmd *cobra.Command, _ []string) {
 		var unsealConfig unsealCfg
 
+		ctx := cmd.Context()
+
 		unsealConfig.unsealPeriod = c.GetDuration(cfgUnsealPeriod)
 		unsealConfig.proceedInit = c.GetBool(cfgInit)
 		unsealConfig.runOnce = c.GetBool(cfgOnce)
@@ -98,7 +101,9 @@ from one of the following:
 		if unsealConfig.proceedInit && unsealConfig.raft {
 			slog.Info("joining leader vault...")
 
-			initialized, err := v.RaftInitialized()
+			opCtx, cancel := operationContext(ctx, c)
+			initialized, err := v.RaftInitialized(opCtx)
+			cancel()
 			if err != nil {
 				sealed, sErr := v.Sealed()
 				if sErr != nil || sealed {
@@ -111,7 +116,7 @@ from one of the following:
 			// If this is the first instance we have to init it, this happens once in the clusters lifetime
 			if !initialized && !unsealConfig.raftSecondary {
 				slog.Info("initializing vault...")
-				if err := v.Init(); err != nil {
+				if err := runOperation(ctx, c, v.Init); err != nil {
 					slog.Error(fmt.Sprintf("error initializing vault: %s", err.Error()))
 					os.Exit(1)
 				}
@@ -124,7 +129,7 @@ from one of the following:
 			}
 		} else if unsealConfig.proceedInit {
 			slog.Info("initializing vault...")
-			if err := v.Init(); err != nil {
+			if err := runOperation(ctx, c, v.Init); err != nil {
 				slog.Error(fmt.Sprintf("error initializing vault: %s", err.Error()))
 				os.Exit(1)
 			}
@@ -133,7 +138,7 @@ from one of the following:
 		raftEstablished := false
 		for {
 			if !unsealConfig.auto {
-				unseal(unsealConfig, v)
+				unseal(ctx, unsealConfig, v)
 			}
 
 			if unsealConfig.raftHAStorage && !raftEstablished {
@@ -141,12 +146,15 @@ from one of the following:
 			}
 
 			// wait unsealPeriod before trying again
-			time.Sleep(unsealConfig.unsealPeriod)
+			if !sleepContext(ctx, unsealConfig.unsealPeriod) {
+				slog.Info("stopped unsealing vault")
+				return
+			}
 		}
 	},
 }
 
-func unseal(unsealConfig unsealCfg, v internalVault.Vault) {
+func unseal(ctx context.Context, unsealConfig unsealCfg, v internalVault.Vault) {
 	slog.Debug("checking if vault is sealed...")
 	sealed, err := v.Sealed()
 	if err != nil {
@@ -164,7 +172,7 @@ func unseal(unsealConfig unsealCfg, v internalVault.Vault) {
 
 	slog.Info("vault is sealed, unsealing")
 
-	if err = v.Unseal(); err != nil {
+	if err = runOperation(ctx, c, v.Unseal); err != nil {
 		slog.Error(fmt.Sprintf("error unsealing vault: %s", err.Error()))
 		exitIfNecessary(unsealConfig, 1)
 		return
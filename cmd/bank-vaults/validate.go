@@ -0,0 +1,94 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	internalVault "github.com/bank-vaults/bank-vaults/internal/vault"
+)
+
+const cfgPrintSchema = "print-schema"
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [vault-config-file...]",
+	Short: "Validates YAML/JSON Vault configuration files",
+	Long: `This command validates the given configuration files against the JSON Schema of the configuration
+of "configure", without connecting to Vault. All the errors are reported in the file:line:column format,
+and the command exits with a non-zero status if any of the files are invalid, so it can be used in CI.
+
+The configuration files are validated after their env templating is executed.
+With the --print-schema flag the JSON Schema is printed instead, which can be used by editors.`,
+	Run: func(_ *cobra.Command, args []string) {
+		if c.GetBool(cfgPrintSchema) {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(internalVault.ConfigSchema()); err != nil {
+				slog.Error(fmt.Sprintf("error encoding schema: %s", err.Error()))
+				os.Exit(1)
+			}
+
+			return
+		}
+
+		if len(args) == 0 {
+			slog.Error("no vault config file is given")
+			os.Exit(1)
+		}
+
+		valid := true
+		for _, vaultConfigFile := range args {
+			if !validateConfiguration(vaultConfigFile) {
+				valid = false
+			}
+		}
+
+		if !valid {
+			os.Exit(1)
+		}
+	},
+}
+
+// validateConfiguration prints the errors of the configuration file, it returns false if it's invalid
+func validateConfiguration(vaultConfigFile string) bool {
+	vaultConfig, err := renderConfiguration(vaultConfigFile)
+	if err != nil {
+		fmt.Printf("%s: %s\n", vaultConfigFile, err.Error())
+		return false
+	}
+
+	validationErrors, err := internalVault.ValidateConfig(vaultConfig)
+	if err != nil {
+		fmt.Printf("%s: %s\n", vaultConfigFile, err.Error())
+		return false
+	}
+
+	for _, validationError := range validationErrors {
+		fmt.Printf("%s:%s\n", vaultConfigFile, validationError.Error())
+	}
+
+	return len(validationErrors) == 0
+}
+
+func init() {
+	configBoolVar(validateCmd, cfgPrintSchema, false, "Print the JSON Schema of the configuration files instead of validating them")
+
+	rootCmd.AddCommand(validateCmd)
+}
@@ -15,6 +15,7 @@
 package vault
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -69,10 +70,10 @@ func (v *vault) getUnmanagedAudits(managedAudits []audit) map[string]bool {
 		delete(unmanagedAudits, managedAudit.Path)
 	}
 
-	return unmanagedAudits
+	return filterOwned(v.ownership, ownedAudit, unmanagedAudits)
 }
 
-func (v *vault) addManagedAudits(managedAudits []audit) error {
+func (v *vault) addManagedAudits(ctx context.Context, managedAudits []audit) error {
 	existingAudits, _ := v.getExistingAudits()
 
 	for _, auditDevice := range managedAudits {
@@ -87,10 +88,11 @@ func (v *vault) addManagedAudits(managedAudits []audit) error {
 
 			slog.Info(fmt.Sprintf("adding audit device %s (%s)", auditDevice.Path, auditDevice.Type))
 			slog.Debug(fmt.Sprintf("audit device options %#v", options))
-			err = v.cl.Sys().EnableAuditWithOptions(auditDevice.Path+"/", &options)
+			err = v.cl.Sys().EnableAuditWithOptionsWithContext(ctx, auditDevice.Path+"/", &options)
 			if err != nil {
 				return errors.Wrapf(err, "error enabling audit device %s in vault", auditDevice.Path)
 			}
+			v.ownership.add(ownedAudit, auditDevice.Path)
 		}
 	}
 
@@ -98,30 +100,27 @@ func (v *vault) addManagedAudits(managedAudits []audit) error {
 }
 
 // Disables any audit that's not managed if purgeUnmanagedConfig option is enabled, otherwise it leaves them
-func (v *vault) removeUnmanagedAudits(unmanagedAudits map[string]bool) error {
+func (v *vault) removeUnmanagedAudits(ctx context.Context, unmanagedAudits map[string]bool) error {
 	if len(unmanagedAudits) == 0 || !v.externalConfig.PurgeUnmanagedConfig.Enabled || v.externalConfig.PurgeUnmanagedConfig.Exclude.Audit {
 		return nil
 	}
 
 	for auditPath := range unmanagedAudits {
 		slog.Info(fmt.Sprintf("removing unmanaged audit device %s", auditPath))
-		err := v.cl.Sys().DisableAudit(auditPath)
+		err := v.cl.Sys().DisableAuditWithContext(ctx, auditPath)
 		if err != nil {
 			return errors.Wrapf(err, "error disabling %s audit in vault", auditPath)
 		}
+		v.ownership.remove(ownedAudit, auditPath)
 	}
 	return nil
 }
 
-func (v *vault) configureAuditDevices() error {
+func (v *vault) purgeAuditDevices(ctx context.Context) error {
 	managedAudits := initAuditConfig(v.externalConfig.Audit)
 	unmanagedAudits := v.getUnmanagedAudits(managedAudits)
 
-	if err := v.addManagedAudits(managedAudits); err != nil {
-		return errors.Wrap(err, "error configuring managed audits")
-	}
-
-	if err := v.removeUnmanagedAudits(unmanagedAudits); err != nil {
+	if err := v.removeUnmanagedAudits(ctx, unmanagedAudits); err != nil {
 		return errors.Wrap(err, "error while disabling unmanaged auth methods")
 	}
 
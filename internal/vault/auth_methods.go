@@ -15,6 +15,7 @@
 package vault
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -40,6 +41,9 @@ type auth struct {
 	Options          map[string]interface{} `mapstructure:"options"`
 	Map              map[string]interface{} `mapstructure:"map"`
 	Config           map[string]interface{} `mapstructure:"config"`
+	// KeepUnmanaged keeps the roles, users, groups and mappings of the auth method which are not in
+	// the configuration, even if the unmanaged configuration is purged
+	KeepUnmanaged bool `mapstructure:"keepUnmanaged"`
 }
 
 func initAuthConfig(auths []auth) []auth {
@@ -63,7 +67,7 @@ func initAuthConfig(auths []auth) []auth {
 	return auths
 }
 
-func (v *vault) addAdditionalAuthConfig(authMethod auth) error {
+func (v *vault) addAdditionalAuthConfig(ctx context.Context, authMethod auth) error {
 	switch authMethod.Type {
 	case "kubernetes":
 		config := authMethod.Config
@@ -83,16 +87,16 @@ func (v *vault) addAdditionalAuthConfig(authMethod auth) error {
 			}
 			config = defaultConfig
 		}
-		err := v.configureGenericAuthConfig(authMethod.Type, authMethod.Path, config)
+		err := v.configureGenericAuthConfig(ctx, authMethod.Type, authMethod.Path, config)
 		if err != nil {
 			return errors.Wrap(err, "error configuring kubernetes auth for vault")
 		}
-		err = v.configureGenericAuthRoles(authMethod.Type, authMethod.Path, "role", authMethod.Roles)
+		err = v.configureGenericAuthRoles(ctx, authMethod.Type, authMethod.Path, "role", authMethod.Roles)
 		if err != nil {
 			return errors.Wrap(err, "error configuring kubernetes auth roles for vault")
 		}
 	case "github":
-		err := v.configureGenericAuthConfig(authMethod.Type, authMethod.Path, authMethod.Config)
+		err := v.configureGenericAuthConfig(ctx, authMethod.Type, authMethod.Path, authMethod.Config)
 		if err != nil {
 			return errors.Wrap(err, "error configuring github auth for vault")
 		}
@@ -100,41 +104,41 @@ func (v *vault) addAdditionalAuthConfig(authMethod auth) error {
 		if err != nil {
 			return errors.Wrap(err, "error finding map block for github")
 		}
-		err = v.configureGithubMappings(authMethod.Path, mappings)
+		err = v.configureGithubMappings(ctx, authMethod.Path, mappings)
 		if err != nil {
 			return errors.Wrap(err, "error configuring github mappings for vault")
 		}
 	case "aws":
-		err := v.configureAwsConfig(authMethod.Path, authMethod.Config)
+		err := v.configureAwsConfig(ctx, authMethod.Path, authMethod.Config)
 		if err != nil {
 			return errors.Wrap(err, "error configuring aws auth for vault")
 		}
 		if authMethod.Crossaccountrole != nil {
-			err = v.configureAWSCrossAccountRoles(authMethod.Path, authMethod.Crossaccountrole)
+			err = v.configureAWSCrossAccountRoles(ctx, authMethod.Path, authMethod.Crossaccountrole)
 			if err != nil {
 				return errors.Wrap(err, "error configuring aws auth cross account roles for vault")
 			}
 		}
-		err = v.configureGenericAuthRoles(authMethod.Type, authMethod.Path, "role", authMethod.Roles)
+		err = v.configureGenericAuthRoles(ctx, authMethod.Type, authMethod.Path, "role", authMethod.Roles)
 		if err != nil {
 			return errors.Wrap(err, "error configuring aws auth roles for vault")
 		}
 	case "gcp", "oci":
-		err := v.configureGenericAuthConfig(authMethod.Type, authMethod.Path, authMethod.Config)
+		err := v.configureGenericAuthConfig(ctx, authMethod.Type, authMethod.Path, authMethod.Config)
 		if err != nil {
 			return errors.Wrapf(err, "error configuring %s auth for vault", authMethod.Type)
 		}
-		err = v.configureGenericAuthRoles(authMethod.Type, authMethod.Path, "role", authMethod.Roles)
+		err = v.configureGenericAuthRoles(ctx, authMethod.Type, authMethod.Path, "role", authMethod.Roles)
 		if err != nil {
 			return errors.Wrapf(err, "error configuring %s auth roles for vault", authMethod.Type)
 		}
 	case "approle":
-		err := v.configureGenericAuthRoles(authMethod.Type, authMethod.Path, "role", authMethod.Roles)
+		err := v.configureGenericAuthRoles(ctx, authMethod.Type, authMethod.Path, "role", authMethod.Roles)
 		if err != nil {
 			return errors.Wrap(err, "error configuring approle auth for vault")
 		}
 	case "jwt", "oidc":
-		err := v.configureGenericAuthConfig(authMethod.Type, authMethod.Path, authMethod.Config)
+		err := v.configureGenericAuthConfig(ctx, authMethod.Type, authMethod.Path, authMethod.Config)
 		if err != nil {
 			return errors.Wrapf(err, "error configuring %s auth on path %s for vault", authMethod.Type, authMethod.Path)
 		}
@@ -142,17 +146,17 @@ func (v *vault) addAdditionalAuthConfig(authMethod auth) error {
 		if err != nil {
 			return errors.Wrapf(err, "error finding roles block for %s", authMethod.Type)
 		}
-		err = v.configureJwtRoles(authMethod.Path, roles)
+		err = v.configureJwtRoles(ctx, authMethod.Path, roles)
 		if err != nil {
 			return errors.Wrapf(err, "error configuring %s roles on path %s for vault", authMethod.Type, authMethod.Path)
 		}
 	case "token":
-		err := v.configureGenericAuthRoles(authMethod.Type, "token", "roles", authMethod.Roles)
+		err := v.configureGenericAuthRoles(ctx, authMethod.Type, "token", "roles", authMethod.Roles)
 		if err != nil {
 			return errors.Wrap(err, "error configuring token roles for vault")
 		}
 	case "cert":
-		err := v.configureGenericAuthConfig(authMethod.Type, authMethod.Path, authMethod.Config)
+		err := v.configureGenericAuthConfig(ctx, authMethod.Type, authMethod.Path, authMethod.Config)
 		if err != nil {
 			return errors.Wrap(err, "error configuring cert auth for vault")
 		}
@@ -160,12 +164,12 @@ func (v *vault) addAdditionalAuthConfig(authMethod auth) error {
 		if err != nil {
 			return errors.Wrap(err, "error finding roles block for certs")
 		}
-		err = v.configureGenericAuthRoles(authMethod.Type, authMethod.Path, "certs", roles)
+		err = v.configureGenericAuthRoles(ctx, authMethod.Type, authMethod.Path, "certs", roles)
 		if err != nil {
 			return errors.Wrap(err, "error configuring certs auth roles for vault")
 		}
 	case "ldap", "okta":
-		err := v.configureGenericAuthConfig(authMethod.Type, authMethod.Path, authMethod.Config)
+		err := v.configureGenericAuthConfig(ctx, authMethod.Type, authMethod.Path, authMethod.Config)
 		if err != nil {
 			return errors.Wrapf(err, "error configuring %s auth on path %s for vault", authMethod.Type, authMethod.Path)
 		}
@@ -175,28 +179,28 @@ func (v *vault) addAdditionalAuthConfig(authMethod auth) error {
 			if err != nil {
 				return errors.Wrapf(err, "error finding users block for %s", authMethod.Type)
 			}
-			err = v.configureGenericUserAndGroupMappings(authMethod.Type, authMethod.Path, "users", users)
+			err = v.configureGenericUserAndGroupMappings(ctx, authMethod.Type, authMethod.Path, "users", users)
 			if err != nil {
 				return errors.Wrapf(err, "error configuring %s %s for vault", authMethod.Type, "users")
 			}
 		}
 		if authMethod.Groups != nil {
-			err = v.configureGenericUserAndGroupMappings(authMethod.Type, authMethod.Path, "groups", authMethod.Groups)
+			err = v.configureGenericUserAndGroupMappings(ctx, authMethod.Type, authMethod.Path, "groups", authMethod.Groups)
 			if err != nil {
 				return errors.Wrapf(err, "error configuring %s %s for vault", authMethod.Type, "groups")
 			}
 		}
 	case "userpass":
-		err := v.configureUserpassUsers(authMethod.Path, authMethod.Users)
+		err := v.configureUserpassUsers(ctx, authMethod.Path, authMethod.Users)
 		if err != nil {
 			return errors.Wrapf(err, "error configuring users for userpass in vault")
 		}
 	case "azure":
-		err := v.configureGenericAuthConfig(authMethod.Type, authMethod.Path, authMethod.Config)
+		err := v.configureGenericAuthConfig(ctx, authMethod.Type, authMethod.Path, authMethod.Config)
 		if err != nil {
 			return errors.Wrap(err, "error configuring azure auth for vault")
 		}
-		err = v.configureGenericAuthRoles(authMethod.Type, authMethod.Path, "role", authMethod.Roles)
+		err = v.configureGenericAuthRoles(ctx, authMethod.Type, authMethod.Path, "role", authMethod.Roles)
 		if err != nil {
 			return errors.Wrap(err, "error configuring azure auth roles for vault")
 		}
@@ -205,6 +209,19 @@ func (v *vault) addAdditionalAuthConfig(authMethod auth) error {
 	return nil
 }
 
+// addAuthRole writes a role of the auth method, the roles are written separately from the rest of the auth
+// method configuration, so they can be written concurrently
+func (v *vault) addAuthRole(ctx context.Context, authMethod auth, role interface{}) error {
+	switch authMethod.Type {
+	case "jwt", "oidc":
+		return v.configureJwtRoles(ctx, authMethod.Path, []interface{}{role})
+	case "token":
+		return v.configureGenericAuthRoles(ctx, authMethod.Type, "token", "roles", []interface{}{role})
+	default:
+		return v.configureGenericAuthRoles(ctx, authMethod.Type, authMethod.Path, authRoleSubPaths[authMethod.Type], []interface{}{role})
+	}
+}
+
 func (v *vault) kubernetesAuthConfigDefault() (map[string]interface{}, error) {
 	kubernetesCACert, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/ca.crt")
 	if err != nil {
@@ -234,14 +251,14 @@ func (v *vault) kubernetesAuthConfigDefault() (map[string]interface{}, error) {
 	return config, nil
 }
 
-func (v *vault) configureGithubMappings(path string, mappings map[string]interface{}) error {
+func (v *vault) configureGithubMappings(ctx context.Context, path string, mappings map[string]interface{}) error {
 	for mappingType, mapping := range mappings {
 		mapping, err := cast.ToStringMapStringE(mapping)
 		if err != nil {
 			return errors.Wrap(err, "error converting mapping for github")
 		}
 		for userOrTeam, policy := range mapping {
-			_, err := v.writeWithWarningCheck(fmt.Sprintf("auth/%s/map/%s/%s", path, mappingType, userOrTeam), map[string]interface{}{"value": policy})
+			_, err := v.writeWithWarningCheck(ctx, fmt.Sprintf("auth/%s/map/%s/%s", path, mappingType, userOrTeam), map[string]interface{}{"value": policy})
 			if err != nil {
 				return errors.Wrapf(err, "error putting %s github mapping into vault", mappingType)
 			}
@@ -250,9 +267,9 @@ func (v *vault) configureGithubMappings(path string, mappings map[string]interfa
 	return nil
 }
 
-func (v *vault) configureAwsConfig(path string, config map[string]interface{}) error {
+func (v *vault) configureAwsConfig(ctx context.Context, path string, config map[string]interface{}) error {
 	// https://www.vaultproject.io/api/auth/aws/index.html
-	_, err := v.writeWithWarningCheck(fmt.Sprintf("auth/%s/config/client", path), config)
+	_, err := v.writeWithWarningCheck(ctx, fmt.Sprintf("auth/%s/config/client", path), config)
 	if err != nil {
 		return errors.Wrap(err, "error putting aws config into vault")
 	}
@@ -260,7 +277,7 @@ func (v *vault) configureAwsConfig(path string, config map[string]interface{}) e
 	return nil
 }
 
-func (v *vault) configureUserpassUsers(path string, users interface{}) error {
+func (v *vault) configureUserpassUsers(ctx context.Context, path string, users interface{}) error {
 	usersAsserted, _ := users.([]interface{})
 	for _, userRaw := range usersAsserted {
 		user, err := cast.ToStringMapE(userRaw)
@@ -268,7 +285,7 @@ func (v *vault) configureUserpassUsers(path string, users interface{}) error {
 			return errors.Wrapf(err, "error converting user for userpass")
 		}
 
-		_, err = v.writeWithWarningCheck(fmt.Sprintf("auth/%s/%s/%s", path, "users", user["username"]), user)
+		_, err = v.writeWithWarningCheck(ctx, fmt.Sprintf("auth/%s/%s/%s", path, "users", user["username"]), user)
 		if err != nil {
 			return errors.Wrapf(err, "error putting userpass %s user into vault", user["username"])
 		}
@@ -277,7 +294,7 @@ func (v *vault) configureUserpassUsers(path string, users interface{}) error {
 	return nil
 }
 
-func (v *vault) configureAWSCrossAccountRoles(path string, crossAccountRoles []interface{}) error {
+func (v *vault) configureAWSCrossAccountRoles(ctx context.Context, path string, crossAccountRoles []interface{}) error {
 	for _, roleInterface := range crossAccountRoles {
 		crossAccountRole, err := cast.ToStringMapE(roleInterface)
 		if err != nil {
@@ -286,7 +303,7 @@ func (v *vault) configureAWSCrossAccountRoles(path string, crossAccountRoles []i
 
 		stsAccount := fmt.Sprint(crossAccountRole["sts_account"])
 
-		_, err = v.writeWithWarningCheck(fmt.Sprintf("auth/%s/config/sts/%s", path, stsAccount), crossAccountRole)
+		_, err = v.writeWithWarningCheck(ctx, fmt.Sprintf("auth/%s/config/sts/%s", path, stsAccount), crossAccountRole)
 		if err != nil {
 			return errors.Wrapf(err, "error putting %s cross account aws role into vault", stsAccount)
 		}
@@ -296,7 +313,7 @@ func (v *vault) configureAWSCrossAccountRoles(path string, crossAccountRoles []i
 }
 
 // TODO try to generalize this with configureGenericAuthRoles() fix the type flaw
-func (v *vault) configureJwtRoles(path string, roles []interface{}) error {
+func (v *vault) configureJwtRoles(ctx context.Context, path string, roles []interface{}) error {
 	for _, roleInterface := range roles {
 		role, err := cast.ToStringMapE(roleInterface)
 		if err != nil {
@@ -312,7 +329,7 @@ func (v *vault) configureJwtRoles(path string, roles []interface{}) error {
 			role["claim_mappings"] = cast.ToStringMap(val)
 		}
 
-		_, err = v.writeWithWarningCheck(fmt.Sprintf("auth/%s/role/%s", path, role["name"]), role)
+		_, err = v.writeWithWarningCheck(ctx, fmt.Sprintf("auth/%s/role/%s", path, role["name"]), role)
 		if err != nil {
 			return errors.Wrapf(err, "error putting %s jwt role into vault", role["name"])
 		}
@@ -321,13 +338,13 @@ func (v *vault) configureJwtRoles(path string, roles []interface{}) error {
 	return nil
 }
 
-func (v *vault) configureGenericUserAndGroupMappings(method, path string, mappingType string, mappings map[string]interface{}) error {
+func (v *vault) configureGenericUserAndGroupMappings(ctx context.Context, method, path string, mappingType string, mappings map[string]interface{}) error {
 	for userOrGroup, policy := range mappings {
 		mapping, err := cast.ToStringMapE(policy)
 		if err != nil {
 			return errors.Wrapf(err, "error converting mapping for %s", method)
 		}
-		_, err = v.writeWithWarningCheck(fmt.Sprintf("auth/%s/%s/%s", path, mappingType, userOrGroup), mapping)
+		_, err = v.writeWithWarningCheck(ctx, fmt.Sprintf("auth/%s/%s/%s", path, mappingType, userOrGroup), mapping)
 		if err != nil {
 			return errors.Wrapf(err, "error putting %s %s mapping into vault", method, mappingType)
 		}
@@ -342,8 +359,8 @@ func (v *vault) configureGenericUserAndGroupMappings(method, path string, mappin
 // https://www.vaultproject.io/api/auth/ldap/index.html
 // https://www.vaultproject.io/api/auth/gcp/index.html
 // https://www.vaultproject.io/api/auth/github/index.html
-func (v *vault) configureGenericAuthConfig(method, path string, config map[string]interface{}) error {
-	_, err := v.writeWithWarningCheck(fmt.Sprintf("auth/%s/config", path), config)
+func (v *vault) configureGenericAuthConfig(ctx context.Context, method, path string, config map[string]interface{}) error {
+	_, err := v.writeWithWarningCheck(ctx, fmt.Sprintf("auth/%s/config", path), config)
 	if err != nil {
 		return errors.Wrapf(err, "error putting %s auth config into vault", method)
 	}
@@ -357,14 +374,14 @@ func (v *vault) configureGenericAuthConfig(method, path string, config map[strin
 // https://www.vaultproject.io/api/auth/aws/index.html
 // https://www.vaultproject.io/api/auth/approle/index.html
 // https://www.vaultproject.io/api/auth/token/index.html
-func (v *vault) configureGenericAuthRoles(method, path, roleSubPath string, roles []interface{}) error {
+func (v *vault) configureGenericAuthRoles(ctx context.Context, method, path, roleSubPath string, roles []interface{}) error {
 	for _, roleInterface := range roles {
 		role, err := cast.ToStringMapE(roleInterface)
 		if err != nil {
 			return errors.Wrapf(err, "error converting roles for %s", method)
 		}
 
-		_, err = v.writeWithWarningCheck(fmt.Sprintf("auth/%s/%s/%s", path, roleSubPath, role["name"]), role)
+		_, err = v.writeWithWarningCheck(ctx, fmt.Sprintf("auth/%s/%s/%s", path, roleSubPath, role["name"]), role)
 		if err != nil {
 			return errors.Wrapf(err, "error putting %s %s role into vault", role["name"], method)
 		}
@@ -373,8 +390,8 @@ func (v *vault) configureGenericAuthRoles(method, path, roleSubPath string, role
 	return nil
 }
 
-func (v *vault) addManagedAuthMethods(managedAuths []auth) error {
-	existingAuths, err := v.getExistingAuthMethods()
+func (v *vault) addManagedAuthMethods(ctx context.Context, managedAuths []auth) error {
+	existingAuths, err := v.getExistingAuthMethods(ctx)
 	if err != nil {
 		return errors.Wrapf(err, "unable to list existing auth methods")
 	}
@@ -408,10 +425,12 @@ func (v *vault) addManagedAuthMethods(managedAuths []auth) error {
 		// We have to filter all existing auths, not to re-enable them as that would raise an error
 		if existingAuths[authMethod.Path] == nil {
 			slog.Info(fmt.Sprintf("adding auth method %s (%s)", authMethod.Path, authMethod.Type))
-			err := v.cl.Sys().EnableAuthWithOptions(authMethod.Path, &options)
+			err := v.cl.Sys().EnableAuthWithOptionsWithContext(ctx, authMethod.Path, &options)
+			v.cache.invalidateAuths()
 			if err != nil {
 				return errors.Wrapf(err, "error enabling %s auth method in vault", authMethod.Path)
 			}
+			v.ownership.add(ownedAuth, authMethod.Path)
 		}
 
 		// If auth method exists but has additional mount options
@@ -419,15 +438,11 @@ func (v *vault) addManagedAuthMethods(managedAuths []auth) error {
 			slog.Info(fmt.Sprintf("tuning existing auth %s (%s)", authMethod.Path, authMethod.Type))
 			// all auth methods are mounted below auth/
 			tunePath := fmt.Sprintf("auth/%s", authMethod.Path)
-			err := v.cl.Sys().TuneMount(tunePath, authConfigInput)
+			err := v.cl.Sys().TuneMountWithContext(ctx, tunePath, authConfigInput)
 			if err != nil {
 				return errors.Wrapf(err, "error tuning %s (%s) auth method in vault", authMethod.Path, authMethod.Type)
 			}
 		}
-		err := v.addAdditionalAuthConfig(authMethod)
-		if err != nil {
-			return errors.Wrapf(err, "error while adding auth method config")
-		}
 	}
 
 	return nil
@@ -435,10 +450,10 @@ func (v *vault) addManagedAuthMethods(managedAuths []auth) error {
 
 // getExistingAuthMethods gets all auth methods that are already in Vault.
 // The existing auth methods are in a map to make it easy to disable easily from it with "O(n)" complexity.
-func (v *vault) getExistingAuthMethods() (map[string]*api.MountOutput, error) {
+func (v *vault) getExistingAuthMethods(ctx context.Context) (map[string]*api.MountOutput, error) {
 	existingAuths := make(map[string]*api.MountOutput)
 
-	existingAuthList, err := v.cl.Sys().ListAuth()
+	existingAuthList, err := v.listAuth(ctx)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to list existing auth methods")
 	}
@@ -452,8 +467,8 @@ func (v *vault) getExistingAuthMethods() (map[string]*api.MountOutput, error) {
 }
 
 // getUnmanagedAuthMethods gets unmanaged auth methods by comparing what's already in Vault and what's in the externalConfig
-func (v *vault) getUnmanagedAuthMethods(managedAuthMethods []auth) map[string]*api.MountOutput {
-	unmanagedAuths, _ := v.getExistingAuthMethods()
+func (v *vault) getUnmanagedAuthMethods(ctx context.Context, managedAuthMethods []auth) map[string]*api.MountOutput {
+	unmanagedAuths, _ := v.getExistingAuthMethods(ctx)
 
 	// Remove managed auth methods form the items since the rest will be disabled.
 	for _, managedAuthMethod := range managedAuthMethods {
@@ -462,34 +477,194 @@ func (v *vault) getUnmanagedAuthMethods(managedAuthMethods []auth) map[string]*a
 	// Remove token auth method since it's the default
 	delete(unmanagedAuths, "token")
 
-	return unmanagedAuths
+	return filterOwned(v.ownership, ownedAuth, unmanagedAuths)
 }
 
 // Disables any auth method that's not managed if purgeUnmanagedConfig option is enabled
-func (v *vault) removeUnmanagedAuthMethods(unmanagedAuths map[string]*api.MountOutput) error {
+func (v *vault) removeUnmanagedAuthMethods(ctx context.Context, unmanagedAuths map[string]*api.MountOutput) error {
 	if len(unmanagedAuths) == 0 || !v.externalConfig.PurgeUnmanagedConfig.Enabled || v.externalConfig.PurgeUnmanagedConfig.Exclude.Auth {
 		return nil
 	}
 
 	for authMethod := range unmanagedAuths {
 		slog.Info(fmt.Sprintf("removing auth method %s ", authMethod))
-		err := v.cl.Sys().DisableAuth(authMethod)
+		err := v.cl.Sys().DisableAuthWithContext(ctx, authMethod)
+		v.cache.invalidateAuths()
 		if err != nil {
 			return errors.Wrapf(err, "error disabling %s auth method in vault", authMethod)
 		}
+		v.ownership.remove(ownedAuth, authMethod)
 	}
 	return nil
 }
 
-func (v *vault) configureAuthMethods() error {
+// authSubResource is a kind of items configured inside an auth method, like its roles
+type authSubResource struct {
+	// kind is the kind of the items in the plan
+	kind string
+	// path is the path the items are listed at
+	path string
+	// names are the names of the items in the configuration
+	names []string
+}
+
+// authItem is an item configured inside an auth method
+type authItem struct {
+	kind string
+	path string
+}
+
+// toStringMapOrEmpty converts the configuration block to a map, a missing block is an empty map
+func toStringMapOrEmpty(value interface{}) (map[string]interface{}, error) {
+	if value == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	return cast.ToStringMapE(value)
+}
+
+// authSubResources returns the kinds of items configured inside the auth method, with the names of the
+// items in the configuration
+func authSubResources(authMethod auth) ([]authSubResource, error) {
+	var subResources []authSubResource
+
+	namesOf := func(items []interface{}, nameKey string) ([]string, error) {
+		var names []string
+		for _, item := range items {
+			itemMap, err := cast.ToStringMapE(item)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error converting %s items", authMethod.Type)
+			}
+			names = append(names, cast.ToString(itemMap[nameKey]))
+		}
+
+		return names, nil
+	}
+
+	if roleSubPath, ok := authRoleSubPaths[authMethod.Type]; ok {
+		path := authMethod.Path
+		if authMethod.Type == "token" {
+			path = "token"
+		}
+
+		names, err := namesOf(authMethod.Roles, "name")
+		if err != nil {
+			return nil, err
+		}
+		subResources = append(subResources, authSubResource{"auth-role", fmt.Sprintf("auth/%s/%s", path, roleSubPath), names})
+	}
+
+	switch authMethod.Type {
+	case "aws":
+		names, err := namesOf(authMethod.Crossaccountrole, "sts_account")
+		if err != nil {
+			return nil, err
+		}
+		subResources = append(subResources, authSubResource{"auth-crossaccountrole", fmt.Sprintf("auth/%s/config/sts", authMethod.Path), names})
+	case "github":
+		for _, mappingType := range []string{"teams", "users"} {
+			mappings, err := toStringMapOrEmpty(authMethod.Map[mappingType])
+			if err != nil {
+				return nil, errors.Wrapf(err, "error converting %s mappings for github", mappingType)
+			}
+			subResources = append(subResources, authSubResource{"auth-mapping", fmt.Sprintf("auth/%s/map/%s", authMethod.Path, mappingType), sortedKeys(mappings)})
+		}
+	case "ldap", "okta":
+		users, err := toStringMapOrEmpty(authMethod.Users)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error finding users block for %s", authMethod.Type)
+		}
+		subResources = append(subResources,
+			authSubResource{"auth-user", fmt.Sprintf("auth/%s/users", authMethod.Path), sortedKeys(users)},
+			authSubResource{"auth-group", fmt.Sprintf("auth/%s/groups", authMethod.Path), sortedKeys(authMethod.Groups)},
+		)
+	case "userpass":
+		users, _ := authMethod.Users.([]interface{})
+		names, err := namesOf(users, "username")
+		if err != nil {
+			return nil, err
+		}
+		subResources = append(subResources, authSubResource{"auth-user", fmt.Sprintf("auth/%s/users", authMethod.Path), names})
+	}
+
+	return subResources, nil
+}
+
+// prunesAuthMethod tells whether the items inside the auth method which are not in the configuration are removed
+func (v *vault) prunesAuthMethod(authMethod auth) bool {
+	purge := v.externalConfig.PurgeUnmanagedConfig
+	if !purge.Enabled || purge.Exclude.Auth || authMethod.KeepUnmanaged {
+		return false
+	}
+
+	// Only the items of the auth methods created by the configuration are removed if the ownership is tracked
+	return v.ownership == nil || v.ownership.owns(ownedAuth, authMethod.Path)
+}
+
+// getUnmanagedAuthItems gets the items inside the auth method, like roles and users, which are in Vault but
+// not in the configuration
+func (v *vault) getUnmanagedAuthItems(ctx context.Context, authMethod auth) ([]authItem, error) {
+	subResources, err := authSubResources(authMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	var unmanagedItems []authItem
+	for _, subResource := range subResources {
+		existingNames, err := v.listKeys(ctx, subResource.path)
+		if err != nil {
+			return nil, err
+		}
+
+		// Some auth methods store the names in lower case
+		managedNames := make(map[string]bool, len(subResource.names))
+		for _, name := range subResource.names {
+			managedNames[strings.ToLower(name)] = true
+		}
+
+		for _, name := range existingNames {
+			if !managedNames[strings.ToLower(name)] {
+				unmanagedItems = append(unmanagedItems, authItem{subResource.kind, subResource.path + "/" + name})
+			}
+		}
+	}
+
+	return unmanagedItems, nil
+}
+
+// removeUnmanagedAuthItems removes the items inside the managed auth methods which are not in the configuration
+func (v *vault) removeUnmanagedAuthItems(ctx context.Context, managedAuths []auth) error {
+	for _, authMethod := range managedAuths {
+		if !v.prunesAuthMethod(authMethod) {
+			continue
+		}
+
+		unmanagedItems, err := v.getUnmanagedAuthItems(ctx, authMethod)
+		if err != nil {
+			return errors.Wrapf(err, "error listing the items of %s auth method", authMethod.Path)
+		}
+
+		for _, item := range unmanagedItems {
+			slog.Info(fmt.Sprintf("removing %s %s", item.kind, item.path))
+			if _, err := v.cl.Logical().DeleteWithContext(ctx, item.path); err != nil {
+				return errors.Wrapf(err, "error removing %s %s from vault", item.kind, item.path)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *vault) purgeAuthMethods(ctx context.Context) error {
 	managedAuths := initAuthConfig(v.externalConfig.Auth)
-	unmanagedAuths := v.getUnmanagedAuthMethods(managedAuths)
 
-	if err := v.addManagedAuthMethods(managedAuths); err != nil {
-		return errors.Wrap(err, "error configuring managed auth methods")
+	if err := v.removeUnmanagedAuthItems(ctx, managedAuths); err != nil {
+		return errors.Wrap(err, "error while removing unmanaged items of auth methods")
 	}
 
-	if err := v.removeUnmanagedAuthMethods(unmanagedAuths); err != nil {
+	unmanagedAuths := v.getUnmanagedAuthMethods(ctx, managedAuths)
+
+	if err := v.removeUnmanagedAuthMethods(ctx, unmanagedAuths); err != nil {
 		return errors.Wrap(err, "error while disabling unmanaged auth methods")
 	}
 
@@ -0,0 +1,77 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUnmanagedAuthItems(t *testing.T) {
+	v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "LIST" && r.URL.Query().Get("list") != "true" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+
+		switch r.URL.Path {
+		case "/v1/auth/ldap/users":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["alice", "bob"]}}`))
+		case "/v1/auth/ldap/groups":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["admins", "developers"]}}`))
+		case "/v1/auth/approle/role":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["ci", "legacy"]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	ldap := auth{
+		Type:  "ldap",
+		Path:  "ldap",
+		Users: map[string]interface{}{"alice": map[string]interface{}{"policies": "admin"}},
+		// None of the groups are in the configuration
+		Groups: nil,
+	}
+
+	unmanagedItems, err := v.getUnmanagedAuthItems(context.Background(), ldap)
+	require.NoError(t, err)
+
+	expected := []authItem{
+		{"auth-user", "auth/ldap/users/bob"},
+		{"auth-group", "auth/ldap/groups/admins"},
+		{"auth-group", "auth/ldap/groups/developers"},
+	}
+	assert.Equal(t, expected, unmanagedItems)
+
+	// AppRole stores the role names in lower case
+	approle := auth{
+		Type:  "approle",
+		Path:  "approle",
+		Roles: []interface{}{map[string]interface{}{"name": "CI"}},
+	}
+
+	unmanagedItems, err = v.getUnmanagedAuthItems(context.Background(), approle)
+	require.NoError(t, err)
+
+	assert.Equal(t, []authItem{{"auth-role", "auth/approle/role/legacy"}}, unmanagedItems)
+}
+
+func TestPrunesAuthMethod(t *testing.T) {
+	config := &externalConfig{}
+	config.PurgeUnmanagedConfig.Enabled = true
+
+	v := &vault{externalConfig: config}
+
+	assert.True(t, v.prunesAuthMethod(auth{Type: "approle", Path: "approle"}))
+	assert.False(t, v.prunesAuthMethod(auth{Type: "approle", Path: "approle", KeepUnmanaged: true}))
+
+	// Only the auth methods created by the configuration are pruned if the ownership is tracked
+	v.ownership = &ownership{resources: map[string]map[string]bool{ownedAuth: {"approle": true}}}
+	assert.True(t, v.prunesAuthMethod(auth{Type: "approle", Path: "approle"}))
+	assert.False(t, v.prunesAuthMethod(auth{Type: "kubernetes", Path: "kubernetes"}))
+
+	config.PurgeUnmanagedConfig.Exclude.Auth = true
+	assert.False(t, v.prunesAuthMethod(auth{Type: "approle", Path: "approle"}))
+}
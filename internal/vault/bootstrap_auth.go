@@ -0,0 +1,159 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+const (
+	bootstrapAuthAppRole    = "approle"
+	bootstrapAuthKubernetes = "kubernetes"
+	bootstrapAuthJWT        = "jwt"
+	bootstrapAuthToken      = "token"
+
+	defaultServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token" //nolint:gosec
+)
+
+// BootstrapAuthConfig holds the auth method Configure logs in with to obtain a short-lived token, instead of
+// using a root token, so the configuration can be applied with a least-privilege policy.
+type BootstrapAuthConfig struct {
+	// Method is the type of the auth method: "approle", "kubernetes", "jwt" or "token" (a token read from a file),
+	// the root token is used if empty
+	Method string
+	// Path is the mount path of the auth method, it defaults to the type of the auth method
+	Path string
+	// Role is the name of the role to log in with (kubernetes, jwt)
+	Role string
+	// RoleID is the role ID to log in with (approle)
+	RoleID string
+	// SecretIDFile is the file holding the secret ID to log in with (approle)
+	SecretIDFile string
+	// ServiceAccountTokenFile is the file holding the service account token to log in with (kubernetes),
+	// it defaults to the token mounted into the pod
+	ServiceAccountTokenFile string
+	// JWTFile is the file holding the JWT to log in with (jwt)
+	JWTFile string
+	// TokenFile is the file holding the token to use (token), the token is not revoked after the configuration
+	TokenFile string
+}
+
+// revocable tells whether the token is obtained for a single apply of the configuration
+func (a BootstrapAuthConfig) revocable() bool {
+	return a.Method != bootstrapAuthToken
+}
+
+// ephemeralToken obtains a token for a single apply of the configuration, by logging in with the
+// bootstrap auth method if it's configured, or with the generate-root process otherwise.
+func (v *vault) ephemeralToken(ctx context.Context) ([]byte, error) {
+	if v.config.BootstrapAuth.Method == "" {
+		return v.generateRootToken(ctx)
+	}
+
+	return v.bootstrapLogin(ctx)
+}
+
+// bootstrapLogin logs in with the bootstrap auth method and returns the client token, or
+// reads the token from the token file
+func (v *vault) bootstrapLogin(ctx context.Context) ([]byte, error) {
+	auth := v.config.BootstrapAuth
+
+	path := auth.Path
+	if path == "" {
+		path = auth.Method
+	}
+	path = strings.Trim(path, "/")
+
+	var data map[string]interface{}
+
+	switch auth.Method {
+	case bootstrapAuthToken:
+		token, err := os.ReadFile(auth.TokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading token")
+		}
+
+		return []byte(strings.TrimSpace(string(token))), nil
+	case bootstrapAuthAppRole:
+		secretID, err := os.ReadFile(auth.SecretIDFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading AppRole secret ID")
+		}
+
+		data = map[string]interface{}{
+			"role_id":   auth.RoleID,
+			"secret_id": strings.TrimSpace(string(secretID)),
+		}
+	case bootstrapAuthKubernetes:
+		tokenFile := auth.ServiceAccountTokenFile
+		if tokenFile == "" {
+			tokenFile = defaultServiceAccountTokenFile
+		}
+
+		jwt, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading service account token")
+		}
+
+		data = map[string]interface{}{
+			"role": auth.Role,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		}
+	case bootstrapAuthJWT:
+		jwt, err := os.ReadFile(auth.JWTFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading JWT")
+		}
+
+		data = map[string]interface{}{
+			"role": auth.Role,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		}
+	default:
+		return nil, errors.Errorf("unsupported bootstrap auth method: %s", auth.Method)
+	}
+
+	slog.Info("logging in with bootstrap auth method", slog.String("method", auth.Method), slog.String("path", path))
+
+	secret, err := v.cl.Logical().WriteWithContext(ctx, "auth/"+path+"/login", data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error logging in with auth method at %s", path)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, errors.Errorf("no token returned by auth method at %s", path)
+	}
+
+	return []byte(secret.Auth.ClientToken), nil
+}
+
+// revokeEphemeralToken revokes the token obtained for an apply of the configuration. The context of
+// the apply might be already canceled, so it can't be used here.
+func (v *vault) revokeEphemeralToken(token []byte) error {
+	v.cl.SetToken(string(token))
+	defer v.cl.SetToken("")
+
+	if err := v.cl.Auth().Token().RevokeSelfWithContext(context.Background(), ""); err != nil {
+		return errors.Wrap(err, "error revoking ephemeral token")
+	}
+
+	slog.Info("ephemeral token revoked")
+
+	return nil
+}
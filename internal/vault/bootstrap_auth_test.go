@@ -0,0 +1,57 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootstrapLogin(t *testing.T) {
+	var revoked []string
+	v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/bootstrap/login":
+			var body map[string]string
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, map[string]string{"role_id": "role", "secret_id": "secret"}, body)
+
+			_, _ = w.Write([]byte(`{"auth": {"client_token": "ephemeral-token"}}`))
+		case "/v1/auth/token/revoke-self":
+			revoked = append(revoked, r.Header.Get("X-Vault-Token"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+		}
+	}))
+
+	secretIDFile := filepath.Join(t.TempDir(), "secret-id")
+	require.NoError(t, os.WriteFile(secretIDFile, []byte("secret\n"), 0o600))
+
+	v.config.BootstrapAuth = BootstrapAuthConfig{
+		Method:       "approle",
+		Path:         "/bootstrap/",
+		RoleID:       "role",
+		SecretIDFile: secretIDFile,
+	}
+
+	token, err := v.ephemeralToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ephemeral-token", string(token))
+
+	require.NoError(t, v.revokeEphemeralToken(token))
+	assert.Equal(t, []string{"ephemeral-token"}, revoked)
+	assert.Empty(t, v.cl.Token())
+}
+
+func TestBootstrapLoginUnsupportedMethod(t *testing.T) {
+	v := &vault{config: &Config{BootstrapAuth: BootstrapAuthConfig{Method: "userpass"}}}
+
+	_, err := v.ephemeralToken(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,272 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/hashicorp/vault/api"
+	"github.com/spf13/cast"
+)
+
+// configCache holds the lists of Vault resources read during a configuration pass, so they are not listed
+// again for every resource of the configuration. The lists are dropped when resources are created or removed.
+type configCache struct {
+	mu     sync.Mutex
+	mounts map[string]*api.MountOutput
+	auths  map[string]*api.MountOutput
+	// identities holds the IDs of the groups and entities by their names, by their kinds
+	identities map[string]map[string]string
+	// aliases holds the group and entity aliases by their kinds
+	aliases map[string]map[string]identityAlias
+}
+
+func (c *configCache) invalidateMounts() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.mounts = nil
+}
+
+func (c *configCache) invalidateAuths() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.auths = nil
+}
+
+func (c *configCache) invalidateIdentities(kind string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.identities, kind)
+}
+
+func (c *configCache) invalidateAliases(kind string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.aliases, kind)
+}
+
+// updateAlias replaces the cached group or entity alias, which keeps its ID, so the list of the aliases
+// doesn't have to be read again after an alias is tuned
+func (c *configCache) updateAlias(kind string, alias identityAlias) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if aliases, ok := c.aliases[kind]; ok {
+		aliases[aliasKey(alias.name, alias.mountAccessor)] = alias
+	}
+}
+
+// listMounts returns the secret engines by their paths (with a trailing slash)
+func (v *vault) listMounts(ctx context.Context) (map[string]*api.MountOutput, error) {
+	if v.cache == nil {
+		return v.cl.Sys().ListMountsWithContext(ctx) //nolint:wrapcheck
+	}
+
+	v.cache.mu.Lock()
+	defer v.cache.mu.Unlock()
+
+	if v.cache.mounts == nil {
+		mounts, err := v.cl.Sys().ListMountsWithContext(ctx)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		v.cache.mounts = mounts
+	}
+
+	// The callers are free to modify the returned map
+	return maps.Clone(v.cache.mounts), nil
+}
+
+// listAuth returns the auth methods by their paths (with a trailing slash)
+func (v *vault) listAuth(ctx context.Context) (map[string]*api.MountOutput, error) {
+	if v.cache == nil {
+		return v.cl.Sys().ListAuthWithContext(ctx) //nolint:wrapcheck
+	}
+
+	v.cache.mu.Lock()
+	defer v.cache.mu.Unlock()
+
+	if v.cache.auths == nil {
+		auths, err := v.cl.Sys().ListAuthWithContext(ctx)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		v.cache.auths = auths
+	}
+
+	return maps.Clone(v.cache.auths), nil
+}
+
+// groupID returns the ID of the group, or an empty string if it doesn't exist
+func (v *vault) groupID(ctx context.Context, name string) (string, error) {
+	return v.identityID(ctx, "group", name)
+}
+
+// entityID returns the ID of the entity, or an empty string if it doesn't exist
+func (v *vault) entityID(ctx context.Context, name string) (string, error) {
+	return v.identityID(ctx, "entity", name)
+}
+
+// identityID returns the ID of the group or entity, or an empty string if it doesn't exist
+func (v *vault) identityID(ctx context.Context, kind, name string) (string, error) {
+	if v.cache == nil {
+		secret, err := v.cl.Logical().ReadWithContext(ctx, fmt.Sprintf("identity/%s/name/%s", kind, name))
+		if err != nil || secret == nil {
+			return "", errors.Wrapf(err, "failed to read %s %s by name", kind, name)
+		}
+
+		return cast.ToString(secret.Data["id"]), nil
+	}
+
+	v.cache.mu.Lock()
+	defer v.cache.mu.Unlock()
+
+	if v.cache.identities[kind] == nil {
+		ids, err := v.listIdentities(ctx, kind)
+		if err != nil {
+			return "", err
+		}
+
+		if v.cache.identities == nil {
+			v.cache.identities = map[string]map[string]string{}
+		}
+		v.cache.identities[kind] = ids
+	}
+
+	return v.cache.identities[kind][name], nil
+}
+
+// identityIDs returns the IDs of the groups or entities, all of which have to exist
+func (v *vault) identityIDs(ctx context.Context, kind string, names []string) ([]string, error) {
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		id, err := v.identityID(ctx, kind, name)
+		if err != nil {
+			return nil, err
+		}
+		if id == "" {
+			return nil, errors.Errorf("%s %s does not exist", kind, name)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// listIdentities returns the IDs of the groups or entities by their names
+func (v *vault) listIdentities(ctx context.Context, kind string) (map[string]string, error) {
+	secret, err := v.cl.Logical().ListWithContext(ctx, fmt.Sprintf("identity/%s/id", kind))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve list of %s", kind)
+	}
+
+	ids := map[string]string{}
+	if secret != nil {
+		for id, info := range cast.ToStringMap(secret.Data["key_info"]) {
+			ids[cast.ToString(cast.ToStringMap(info)["name"])] = id
+		}
+	}
+
+	return ids, nil
+}
+
+// identityAlias is a group or entity alias in Vault
+type identityAlias struct {
+	id            string
+	name          string
+	mountAccessor string
+	canonicalID   string
+}
+
+// aliasKey identifies an alias, the names of the aliases are only unique per auth method
+func aliasKey(name, mountAccessor string) string {
+	return name + "@" + mountAccessor
+}
+
+// listAliases returns the group or entity aliases by their names and mount accessors, see aliasKey
+func (v *vault) listAliases(ctx context.Context, kind string) (map[string]identityAlias, error) {
+	if v.cache == nil {
+		return v.readAliases(ctx, kind)
+	}
+
+	v.cache.mu.Lock()
+	defer v.cache.mu.Unlock()
+
+	if v.cache.aliases[kind] == nil {
+		aliases, err := v.readAliases(ctx, kind)
+		if err != nil {
+			return nil, err
+		}
+
+		if v.cache.aliases == nil {
+			v.cache.aliases = map[string]map[string]identityAlias{}
+		}
+		v.cache.aliases[kind] = aliases
+	}
+
+	return maps.Clone(v.cache.aliases[kind]), nil
+}
+
+func (v *vault) readAliases(ctx context.Context, kind string) (map[string]identityAlias, error) {
+	secret, err := v.cl.Logical().ListWithContext(ctx, fmt.Sprintf("identity/%s-alias/id", kind))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve list of %s-alias", kind)
+	}
+
+	aliases := map[string]identityAlias{}
+	if secret != nil {
+		for id, info := range cast.ToStringMap(secret.Data["key_info"]) {
+			info := cast.ToStringMap(info)
+			alias := identityAlias{
+				id:            id,
+				name:          cast.ToString(info["name"]),
+				mountAccessor: cast.ToString(info["mount_accessor"]),
+				canonicalID:   cast.ToString(info["canonical_id"]),
+			}
+			aliases[aliasKey(alias.name, alias.mountAccessor)] = alias
+		}
+	}
+
+	return aliases, nil
+}
@@ -0,0 +1,743 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+)
+
+// systemSecretsEngines are mounted by Vault itself, they can't be configured
+var systemSecretsEngines = map[string]bool{
+	"cubbyhole": true,
+	"identity":  true,
+	"system":    true,
+}
+
+// authConfigSubPaths holds the path of the config below the auth method of the types which have config
+var authConfigSubPaths = map[string]string{
+	"kubernetes": "config", "gcp": "config", "oci": "config", "jwt": "config", "oidc": "config", "cert": "config",
+	"ldap": "config", "okta": "config", "azure": "config", "github": "config", "aws": "config/client",
+}
+
+// Export reads the current state of Vault and returns it in the format of the external configuration,
+// so applying it with Configure doesn't change anything. Secrets Vault never returns (like passwords
+// and the credentials in the configuration of auth methods) and the configuration of the secrets
+// engines can't be read, so they are not exported.
+func (v *vault) Export(ctx context.Context) (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+
+	err := v.withConfigurationToken(ctx, func() error {
+		steps := []struct {
+			key     string
+			export  func(ctx context.Context) ([]interface{}, error)
+			message string
+		}{
+			{"audit", v.exportAuditDevices, "error exporting audit devices"},
+			{"auth", v.exportAuthMethods, "error exporting auth methods"},
+			{"entities", v.exportIdentityEntities, "error exporting entities"},
+			{"entity-aliases", v.exportIdentityEntityAliases, "error exporting entity aliases"},
+			{"groups", v.exportIdentityGroups, "error exporting groups"},
+			{"group-aliases", v.exportIdentityGroupAliases, "error exporting group aliases"},
+			{"plugins", v.exportPlugins, "error exporting plugins"},
+			{"policies", v.exportPolicies, "error exporting policies"},
+			{"secrets", v.exportSecretsEngines, "error exporting secret engines"},
+		}
+
+		for _, step := range steps {
+			if err := ctx.Err(); err != nil {
+				return errors.Wrap(err, "export aborted")
+			}
+
+			items, err := step.export(ctx)
+			if err != nil {
+				return errors.Wrap(err, step.message)
+			}
+
+			if len(items) > 0 {
+				config[step.key] = items
+			}
+		}
+
+		oidcProvider, err := v.exportOIDCProvider(ctx)
+		if err != nil {
+			return errors.Wrap(err, "error exporting oidc provider")
+		}
+		if len(oidcProvider) > 0 {
+			config["oidcProvider"] = oidcProvider
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (v *vault) exportAuditDevices(ctx context.Context) ([]interface{}, error) {
+	audits, err := v.cl.Sys().ListAuditWithContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list existing audits")
+	}
+
+	var exported []interface{}
+	for _, path := range sortedKeys(audits) {
+		audit := audits[path]
+
+		exportedAudit := map[string]interface{}{
+			"type": audit.Type,
+			"path": strings.Trim(path, "/"),
+		}
+		if audit.Description != "" {
+			exportedAudit["description"] = audit.Description
+		}
+		if len(audit.Options) > 0 {
+			exportedAudit["options"] = audit.Options
+		}
+
+		exported = append(exported, exportedAudit)
+	}
+
+	return exported, nil
+}
+
+func (v *vault) exportAuthMethods(ctx context.Context) ([]interface{}, error) {
+	auths, err := v.cl.Sys().ListAuthWithContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting list of auth engines")
+	}
+
+	var exported []interface{}
+	for _, mountPath := range sortedKeys(auths) {
+		authMethod := auths[mountPath]
+		path := strings.Trim(mountPath, "/")
+
+		exportedAuth := map[string]interface{}{
+			"type": authMethod.Type,
+			"path": path,
+		}
+		if authMethod.Description != "" {
+			exportedAuth["description"] = authMethod.Description
+		}
+
+		options, err := exportMountConfig(authMethod.Config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error exporting auth method %s options", path)
+		}
+		if len(options) > 0 {
+			exportedAuth["options"] = options
+		}
+
+		if configSubPath, ok := authConfigSubPaths[authMethod.Type]; ok {
+			config, err := v.readData(ctx, fmt.Sprintf("auth/%s/%s", path, configSubPath))
+			if err != nil {
+				return nil, err
+			}
+			if len(config) > 0 {
+				exportedAuth["config"] = config
+			}
+		}
+
+		if roleSubPath, ok := authRoleSubPaths[authMethod.Type]; ok {
+			roles, err := v.exportNamedData(ctx, fmt.Sprintf("auth/%s/%s", path, roleSubPath), "name")
+			if err != nil {
+				return nil, err
+			}
+			if len(roles) > 0 {
+				exportedAuth["roles"] = roles
+			}
+		}
+
+		switch authMethod.Type {
+		case "token":
+			// The token auth method always exists, it's only configured for its roles
+			if exportedAuth["roles"] == nil {
+				continue
+			}
+		case "aws":
+			crossAccountRoles, err := v.exportNamedData(ctx, fmt.Sprintf("auth/%s/config/sts", path), "sts_account")
+			if err != nil {
+				return nil, err
+			}
+			if len(crossAccountRoles) > 0 {
+				exportedAuth["crossaccountrole"] = crossAccountRoles
+			}
+		case "github":
+			mappings := map[string]interface{}{}
+			for _, mappingType := range []string{"teams", "users"} {
+				mapping, err := v.exportMappings(ctx, fmt.Sprintf("auth/%s/map/%s", path, mappingType))
+				if err != nil {
+					return nil, err
+				}
+				if len(mapping) > 0 {
+					values := map[string]interface{}{}
+					for name, data := range mapping {
+						values[name] = data["value"]
+					}
+					mappings[mappingType] = values
+				}
+			}
+			if len(mappings) > 0 {
+				exportedAuth["map"] = mappings
+			}
+		case "ldap", "okta":
+			for _, mappingType := range []string{"users", "groups"} {
+				mapping, err := v.exportMappings(ctx, fmt.Sprintf("auth/%s/%s", path, mappingType))
+				if err != nil {
+					return nil, err
+				}
+				if len(mapping) > 0 {
+					exportedAuth[mappingType] = mapping
+				}
+			}
+		case "userpass":
+			users, err := v.exportNamedData(ctx, fmt.Sprintf("auth/%s/users", path), "username")
+			if err != nil {
+				return nil, err
+			}
+			if len(users) > 0 {
+				exportedAuth["users"] = users
+			}
+		}
+
+		exported = append(exported, exportedAuth)
+	}
+
+	return exported, nil
+}
+
+func (v *vault) exportIdentityGroups(ctx context.Context) ([]interface{}, error) {
+	names, err := v.listKeys(ctx, "identity/group/name")
+	if err != nil {
+		return nil, err
+	}
+
+	// The members of the internal groups are exported by their names
+	var entityNames, groupNames map[string]string
+
+	var exported []interface{}
+	for _, name := range names {
+		group, err := readVaultGroup(name, v.cl)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading group")
+		}
+		if group == nil {
+			continue
+		}
+
+		exportedGroup := map[string]interface{}{
+			"name": name,
+			"type": group.Data["type"],
+		}
+		if policies := cast.ToStringSlice(group.Data["policies"]); len(policies) > 0 {
+			exportedGroup["policies"] = policies
+		}
+		if metadata := cast.ToStringMap(group.Data["metadata"]); len(metadata) > 0 {
+			exportedGroup["metadata"] = metadata
+		}
+		if ids := cast.ToStringSlice(group.Data["member_entity_ids"]); len(ids) > 0 {
+			if entityNames == nil {
+				if entityNames, err = v.identityNames(ctx, "entity"); err != nil {
+					return nil, err
+				}
+			}
+			exportedGroup["member_entities"] = namesOf(ids, entityNames)
+		}
+		if ids := cast.ToStringSlice(group.Data["member_group_ids"]); len(ids) > 0 {
+			if groupNames == nil {
+				if groupNames, err = v.identityNames(ctx, "group"); err != nil {
+					return nil, err
+				}
+			}
+			exportedGroup["member_groups"] = namesOf(ids, groupNames)
+		}
+
+		exported = append(exported, exportedGroup)
+	}
+
+	return exported, nil
+}
+
+// identityNames returns the names of the groups or entities by their IDs
+func (v *vault) identityNames(ctx context.Context, kind string) (map[string]string, error) {
+	ids, err := v.listIdentities(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(ids))
+	for name, id := range ids {
+		names[id] = name
+	}
+
+	return names, nil
+}
+
+// namesOf returns the names of the IDs, sorted, the IDs without a name are left out
+func namesOf(ids []string, names map[string]string) []string {
+	var found []string
+	for _, id := range ids {
+		if name, ok := names[id]; ok {
+			found = append(found, name)
+		}
+	}
+	sort.Strings(found)
+
+	return found
+}
+
+func (v *vault) exportIdentityEntities(ctx context.Context) ([]interface{}, error) {
+	names, err := v.listKeys(ctx, "identity/entity/name")
+	if err != nil {
+		return nil, err
+	}
+
+	var exported []interface{}
+	for _, name := range names {
+		entity, err := v.cl.Logical().ReadWithContext(ctx, "identity/entity/name/"+name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read entity %s by name", name)
+		}
+		if entity == nil {
+			continue
+		}
+
+		exportedEntity := map[string]interface{}{
+			"name": name,
+		}
+		if policies := cast.ToStringSlice(entity.Data["policies"]); len(policies) > 0 {
+			exportedEntity["policies"] = policies
+		}
+		if metadata := cast.ToStringMap(entity.Data["metadata"]); len(metadata) > 0 {
+			exportedEntity["metadata"] = metadata
+		}
+		if cast.ToBool(entity.Data["disabled"]) {
+			exportedEntity["disabled"] = true
+		}
+
+		exported = append(exported, exportedEntity)
+	}
+
+	return exported, nil
+}
+
+func (v *vault) exportIdentityEntityAliases(ctx context.Context) ([]interface{}, error) {
+	aliases, err := v.listAliases(ctx, "entity")
+	if err != nil {
+		return nil, err
+	}
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+
+	auths, err := v.cl.Sys().ListAuthWithContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting list of auth engines")
+	}
+
+	mountPaths := map[string]string{}
+	for path, authMethod := range auths {
+		mountPaths[authMethod.Accessor] = strings.Trim(path, "/")
+	}
+
+	entityNames, err := v.identityNames(ctx, "entity")
+	if err != nil {
+		return nil, err
+	}
+
+	var exported []interface{}
+	for _, key := range sortedKeys(aliases) {
+		alias := aliases[key]
+
+		entityName, ok := entityNames[alias.canonicalID]
+		if !ok {
+			continue
+		}
+
+		exported = append(exported, map[string]interface{}{
+			"name":      alias.name,
+			"mountpath": mountPaths[alias.mountAccessor],
+			"entity":    entityName,
+		})
+	}
+
+	return exported, nil
+}
+
+func (v *vault) exportIdentityGroupAliases(ctx context.Context) ([]interface{}, error) {
+	aliases, err := v.listAliases(ctx, "group")
+	if err != nil {
+		return nil, err
+	}
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+
+	auths, err := v.cl.Sys().ListAuthWithContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting list of auth engines")
+	}
+
+	mountPaths := map[string]string{}
+	for path, authMethod := range auths {
+		mountPaths[authMethod.Accessor] = strings.Trim(path, "/")
+	}
+
+	groupNames, err := v.identityNames(ctx, "group")
+	if err != nil {
+		return nil, err
+	}
+
+	var exported []interface{}
+	for _, key := range sortedKeys(aliases) {
+		alias := aliases[key]
+
+		groupName, ok := groupNames[alias.canonicalID]
+		if !ok {
+			continue
+		}
+
+		exported = append(exported, map[string]interface{}{
+			"name":      alias.name,
+			"mountpath": mountPaths[alias.mountAccessor],
+			"group":     groupName,
+		})
+	}
+
+	return exported, nil
+}
+
+// oidcExportedFields holds the fields of the OIDC provider resources by their kinds, which are exported as they
+// are, except for the durations and the references by IDs
+var oidcExportedFields = map[string][]string{
+	"key":        {"algorithm", "rotation_period", "verification_ttl", "allowed_client_ids"},
+	"role":       {"key", "template", "client_id", "ttl"},
+	"assignment": {},
+	"scope":      {"template", "description"},
+	"client":     {"key", "redirect_uris", "assignments", "client_type", "id_token_ttl", "access_token_ttl"},
+	"provider":   {"issuer", "scopes_supported"},
+}
+
+var oidcDurationFields = map[string]bool{
+	"rotation_period": true, "verification_ttl": true, "ttl": true, "id_token_ttl": true, "access_token_ttl": true,
+}
+
+func (v *vault) exportOIDCProvider(ctx context.Context) (map[string]interface{}, error) {
+	exported := map[string]interface{}{}
+
+	var entityNames, groupNames map[string]string
+	// clientNames holds the names of the clients by their client IDs, the providers reference them by the IDs
+	clientNames := map[string]string{}
+
+	for _, kind := range oidcKinds {
+		names, err := v.listKeys(ctx, "identity/oidc/"+kind)
+		if err != nil {
+			return nil, err
+		}
+
+		var items []interface{}
+		for _, name := range names {
+			secret, err := v.cl.Logical().ReadWithContext(ctx, oidcPath(kind, name))
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read oidc %s %s", kind, name)
+			}
+			if secret == nil {
+				continue
+			}
+
+			if kind == "client" {
+				clientNames[cast.ToString(secret.Data["client_id"])] = name
+			}
+			if oidcBuiltins[kind][name] {
+				continue
+			}
+
+			item := map[string]interface{}{"name": name}
+			for _, field := range oidcExportedFields[kind] {
+				value := secret.Data[field]
+				if value == nil || value == "" {
+					continue
+				}
+
+				if oidcDurationFields[field] {
+					if seconds, err := cast.ToInt64E(value); err == nil {
+						value = (time.Duration(seconds) * time.Second).String()
+					}
+				}
+
+				item[field] = value
+			}
+
+			switch kind {
+			case "assignment":
+				if ids := cast.ToStringSlice(secret.Data["entity_ids"]); len(ids) > 0 {
+					if entityNames == nil {
+						if entityNames, err = v.identityNames(ctx, "entity"); err != nil {
+							return nil, err
+						}
+					}
+					item["entities"] = namesOf(ids, entityNames)
+				}
+				if ids := cast.ToStringSlice(secret.Data["group_ids"]); len(ids) > 0 {
+					if groupNames == nil {
+						if groupNames, err = v.identityNames(ctx, "group"); err != nil {
+							return nil, err
+						}
+					}
+					item["groups"] = namesOf(ids, groupNames)
+				}
+			case "provider":
+				// Vault returns the issuer with the path of the provider
+				item["issuer"] = strings.TrimSuffix(cast.ToString(item["issuer"]), "/v1/"+oidcPath(kind, name))
+				if item["issuer"] == "" {
+					delete(item, "issuer")
+				}
+
+				var allowedClients []string
+				for _, id := range cast.ToStringSlice(secret.Data["allowed_client_ids"]) {
+					if id == "*" {
+						allowedClients = append(allowedClients, id)
+					} else if name, ok := clientNames[id]; ok {
+						allowedClients = append(allowedClients, name)
+					}
+				}
+				if len(allowedClients) > 0 {
+					item["allowed_clients"] = allowedClients
+				}
+			}
+
+			items = append(items, item)
+		}
+
+		if len(items) > 0 {
+			exported[kind+"s"] = items
+		}
+	}
+
+	return exported, nil
+}
+
+func (v *vault) exportPlugins(ctx context.Context) ([]interface{}, error) {
+	plugins, err := v.getExistingPlugins()
+	if err != nil {
+		return nil, err
+	}
+
+	var exported []interface{}
+	for _, pluginType := range sortedKeys(plugins) {
+		for _, name := range sortedKeys(plugins[pluginType]) {
+			parsedType, err := api.ParsePluginType(pluginType)
+			if err != nil {
+				return nil, errors.Wrap(err, "error parsing type for plugin")
+			}
+
+			plugin, err := v.cl.Sys().GetPluginWithContext(ctx, &api.GetPluginInput{Name: name, Type: parsedType})
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to retrieve plugin %s/%s", pluginType, name)
+			}
+
+			exported = append(exported, map[string]interface{}{
+				"plugin_name": name,
+				"type":        pluginType,
+				"command":     plugin.Command,
+				"sha256":      plugin.SHA256,
+			})
+		}
+	}
+
+	return exported, nil
+}
+
+func (v *vault) exportPolicies(ctx context.Context) ([]interface{}, error) {
+	policies, err := v.cl.Sys().ListPoliciesWithContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list existing policies")
+	}
+
+	var exported []interface{}
+	for _, name := range policies {
+		// The root policy can't be changed, the default policy is created by Vault
+		if name == "root" || name == "default" {
+			continue
+		}
+
+		rules, err := v.cl.Sys().GetPolicyWithContext(ctx, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading %s policy", name)
+		}
+
+		exported = append(exported, map[string]interface{}{
+			"name":  name,
+			"rules": rules,
+		})
+	}
+
+	return exported, nil
+}
+
+func (v *vault) exportSecretsEngines(ctx context.Context) ([]interface{}, error) {
+	mounts, err := v.cl.Sys().ListMountsWithContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading mounts from vault")
+	}
+
+	var exported []interface{}
+	for _, mountPath := range sortedKeys(mounts) {
+		mount := mounts[mountPath]
+		path := strings.Trim(mountPath, "/")
+
+		if systemSecretsEngines[mount.Type] {
+			continue
+		}
+
+		exportedSecretEngine := map[string]interface{}{
+			"type": mount.Type,
+			"path": path,
+		}
+		if mount.Description != "" {
+			exportedSecretEngine["description"] = mount.Description
+		}
+		if mount.Local {
+			exportedSecretEngine["local"] = true
+		}
+		if mount.SealWrap {
+			exportedSecretEngine["seal_wrap"] = true
+		}
+		if len(mount.Options) > 0 {
+			exportedSecretEngine["options"] = mount.Options
+		}
+
+		config, err := exportMountConfig(mount.Config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error exporting secret engine %s config", path)
+		}
+		if len(config) > 0 {
+			exportedSecretEngine["config"] = config
+		}
+
+		exported = append(exported, exportedSecretEngine)
+	}
+
+	return exported, nil
+}
+
+// exportMountConfig converts the config of a mount to the config format of Configure: the fields
+// with default values are left out and the TTLs are converted to durations.
+func exportMountConfig(config api.MountConfigOutput) (map[string]interface{}, error) {
+	exported := map[string]interface{}{}
+	if err := mapstructure.Decode(config, &exported); err != nil {
+		return nil, errors.Wrap(err, "error decoding mount config")
+	}
+
+	// The user lockout config has no mapstructure tags, so it can't be configured through the mount config
+	delete(exported, "UserLockoutConfig")
+	delete(exported, "plugin_name")
+
+	for key, value := range exported {
+		if value == nil || reflect.ValueOf(value).IsZero() {
+			delete(exported, key)
+		} else if value := reflect.ValueOf(value); value.Kind() == reflect.Slice && value.Len() == 0 {
+			delete(exported, key)
+		}
+	}
+
+	for _, key := range []string{"default_lease_ttl", "max_lease_ttl"} {
+		if seconds, ok := exported[key].(int); ok {
+			exported[key] = (time.Duration(seconds) * time.Second).String()
+		}
+	}
+
+	if exported["token_type"] == "default-service" {
+		delete(exported, "token_type")
+	}
+
+	return exported, nil
+}
+
+// readData reads the data at the path, it returns nil if the path doesn't exist
+func (v *vault) readData(ctx context.Context, path string) (map[string]interface{}, error) {
+	secret, err := v.cl.Logical().ReadWithContext(ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", path)
+	}
+	if secret == nil {
+		return nil, nil
+	}
+
+	return secret.Data, nil
+}
+
+// listKeys lists the keys below the path, it returns nil if there are no keys
+func (v *vault) listKeys(ctx context.Context, path string) ([]string, error) {
+	secret, err := v.cl.Logical().ListWithContext(ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing %s", path)
+	}
+	if secret == nil {
+		return nil, nil
+	}
+
+	return cast.ToStringSlice(secret.Data["keys"]), nil
+}
+
+// exportNamedData reads every item below the path and returns them as a list of items, with
+// their names under nameKey, like roles in the auth method config
+func (v *vault) exportNamedData(ctx context.Context, path, nameKey string) ([]interface{}, error) {
+	mappings, err := v.exportMappings(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var exported []interface{}
+	for _, name := range sortedKeys(mappings) {
+		data := mappings[name]
+		data[nameKey] = name
+		exported = append(exported, data)
+	}
+
+	return exported, nil
+}
+
+// exportMappings reads every item below the path and returns them by name, like users in the auth method config
+func (v *vault) exportMappings(ctx context.Context, path string) (map[string]map[string]interface{}, error) {
+	names, err := v.listKeys(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := map[string]map[string]interface{}{}
+	for _, name := range names {
+		data, err := v.readData(ctx, path+"/"+name)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			mappings[name] = data
+		}
+	}
+
+	return mappings, nil
+}
@@ -0,0 +1,60 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportMountConfig(t *testing.T) {
+	config := api.MountConfigOutput{
+		DefaultLeaseTTL:           3600,
+		MaxLeaseTTL:               0,
+		ListingVisibility:         "unauth",
+		TokenType:                 "default-service",
+		UserLockoutConfig:         &api.UserLockoutConfigOutput{},
+		AllowedResponseHeaders:    []string{},
+		PassthroughRequestHeaders: []string{"X-Request-Id"},
+	}
+
+	exported, err := exportMountConfig(config)
+	require.NoError(t, err)
+
+	expected := map[string]interface{}{
+		"default_lease_ttl":           "1h0m0s",
+		"listing_visibility":          "unauth",
+		"passthrough_request_headers": []string{"X-Request-Id"},
+	}
+	assert.Equal(t, expected, exported)
+}
+
+func TestExportSecretsEngines(t *testing.T) {
+	v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/sys/mounts", r.URL.Path)
+
+		_, _ = w.Write([]byte(`{"data": {
+			"cubbyhole/": {"type": "cubbyhole", "description": "per-token private secret storage"},
+			"identity/": {"type": "identity"},
+			"sys/": {"type": "system"},
+			"secret/": {"type": "kv", "options": {"version": "2"}, "config": {"default_lease_ttl": 0, "max_lease_ttl": 86400}}
+		}}`))
+	}))
+
+	exported, err := v.exportSecretsEngines(context.Background())
+	require.NoError(t, err)
+
+	expected := []interface{}{
+		map[string]interface{}{
+			"type":    "kv",
+			"path":    "secret",
+			"options": map[string]string{"version": "2"},
+			"config":  map[string]interface{}{"max_lease_ttl": "24h0m0s"},
+		},
+	}
+	assert.Equal(t, expected, exported)
+}
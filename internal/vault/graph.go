@@ -0,0 +1,582 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/spf13/cast"
+)
+
+// configNode is a resource of the configuration, which is applied after the resources it depends on
+type configNode struct {
+	id        string
+	apply     func(ctx context.Context) error
+	dependsOn []string
+}
+
+// configGraph holds the resources of the configuration and the dependencies between them
+type configGraph struct {
+	nodes map[string]*configNode
+	// ids holds the node IDs in the order of the configuration, which is kept for independent nodes
+	ids []string
+	// missing holds the errors of the references to resources which are neither configured nor exist in Vault
+	missing []error
+}
+
+func newConfigGraph() *configGraph {
+	return &configGraph{nodes: map[string]*configNode{}}
+}
+
+func (g *configGraph) add(id string, apply func(ctx context.Context) error, dependsOn ...string) *configNode {
+	node := &configNode{id: id, apply: apply, dependsOn: dependsOn}
+
+	// A resource configured more than once is applied once, with its last configuration
+	if _, ok := g.nodes[id]; !ok {
+		g.ids = append(g.ids, id)
+	}
+	g.nodes[id] = node
+
+	return node
+}
+
+// dependOn adds a dependency on the node if it's configured, otherwise the resource is expected to exist
+// in Vault already or not be needed for the node to be applied
+func (g *configGraph) dependOn(node *configNode, id string) {
+	if _, ok := g.nodes[id]; ok && id != node.id {
+		node.dependsOn = append(node.dependsOn, id)
+	}
+}
+
+// require adds a dependency on the node if it's configured, otherwise the resource has to exist in Vault
+func (g *configGraph) require(node *configNode, id string, exists func() (bool, error)) {
+	if _, ok := g.nodes[id]; ok {
+		node.dependsOn = append(node.dependsOn, id)
+		return
+	}
+
+	found, err := exists()
+	if err != nil {
+		g.missing = append(g.missing, errors.Wrapf(err, "error checking %s referenced by %s", id, node.id))
+	} else if !found {
+		g.missing = append(g.missing, errors.Errorf("%s references %s, which is neither configured nor exists in vault", node.id, id))
+	}
+}
+
+// sorted returns the nodes in a topological order: every node comes after its dependencies, otherwise the
+// nodes are in the order of the configuration
+func (g *configGraph) sorted() ([]*configNode, error) {
+	if len(g.missing) > 0 {
+		return nil, errors.Wrap(errors.Combine(g.missing...), "missing references in the configuration")
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(g.nodes))
+	sorted := make([]*configNode, 0, len(g.nodes))
+
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		switch state[id] {
+		case visited:
+			return nil
+		case visiting:
+			for i, pathID := range path {
+				if pathID == id {
+					return errors.Errorf("dependency cycle in the configuration: %s", strings.Join(append(path[i:], id), " -> "))
+				}
+			}
+		}
+
+		state[id] = visiting
+		for _, dependency := range g.nodes[id].dependsOn {
+			if err := visit(dependency, append(path, id)); err != nil {
+				return err
+			}
+		}
+		state[id] = visited
+
+		sorted = append(sorted, g.nodes[id])
+
+		return nil
+	}
+
+	for _, id := range g.ids {
+		if err := visit(id, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// applyNodes applies the nodes, which are in a topological order, with at most the given number of workers.
+// A node is applied once all of its dependencies got applied, the nodes depending on a node which failed are
+// skipped. The errors are returned in the order of the nodes, regardless of the order they got applied in.
+func applyNodes(ctx context.Context, nodes []*configNode, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	index := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		index[node.id] = i
+	}
+
+	// remaining holds the number of dependencies of the nodes which are not applied yet
+	remaining := make([]int, len(nodes))
+	dependents := make([][]int, len(nodes))
+	for i, node := range nodes {
+		seen := map[int]bool{}
+		for _, dependency := range node.dependsOn {
+			j, ok := index[dependency]
+			if !ok || seen[j] {
+				continue
+			}
+			seen[j] = true
+
+			remaining[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	var ready []int
+	for i := range nodes {
+		if remaining[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	errs := make([]error, len(nodes))
+	skipped := make([]bool, len(nodes))
+
+	var finish func(i int, applied bool)
+	finish = func(i int, applied bool) {
+		for _, dependent := range dependents[i] {
+			if skipped[dependent] {
+				continue
+			}
+
+			if !applied {
+				slog.Warn(fmt.Sprintf("skipping %s, because %s could not be configured", nodes[dependent].id, nodes[i].id))
+				skipped[dependent] = true
+				finish(dependent, false)
+
+				continue
+			}
+
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+
+		// The ready nodes are started in the order of the configuration
+		sort.Ints(ready)
+	}
+
+	type result struct {
+		index int
+		err   error
+	}
+
+	results := make(chan result)
+	running := 0
+
+	for running > 0 || (len(ready) > 0 && ctx.Err() == nil) {
+		// Stop starting new nodes if the configuration got canceled or timed out
+		for running < workers && len(ready) > 0 && ctx.Err() == nil {
+			i := ready[0]
+			ready = ready[1:]
+			running++
+
+			go func() {
+				results <- result{index: i, err: nodes[i].apply(ctx)}
+			}()
+		}
+
+		r := <-results
+		running--
+
+		if r.err != nil {
+			errs[r.index] = errors.Wrapf(r.err, "error configuring %s", nodes[r.index].id)
+		}
+		finish(r.index, r.err == nil)
+	}
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, errors.Wrap(err, "configuration aborted"))
+	}
+
+	return errors.Combine(errs...)
+}
+
+func auditNodeID(path string) string {
+	return "audit/" + path
+}
+
+func authNodeID(path string) string {
+	return "auth/" + path
+}
+
+func authConfigNodeID(path string) string {
+	return "auth-config/" + path
+}
+
+func authRoleNodeID(path string, name string) string {
+	return fmt.Sprintf("auth-role/%s/%s", path, name)
+}
+
+func entityNodeID(name string) string {
+	return "entity/" + name
+}
+
+func entityAliasNodeID(alias entityAlias) string {
+	return fmt.Sprintf("entity-alias/%s@%s", alias.Name, alias.MountPath)
+}
+
+func groupNodeID(name string) string {
+	return "group/" + name
+}
+
+func groupAliasNodeID(alias groupAlias) string {
+	return fmt.Sprintf("group-alias/%s@%s", alias.Name, alias.MountPath)
+}
+
+func oidcNodeID(kind, name string) string {
+	return fmt.Sprintf("oidc-%s/%s", kind, name)
+}
+
+func pluginNodeID(pluginType, name string) string {
+	return fmt.Sprintf("plugin/%s/%s", pluginType, name)
+}
+
+func policyNodeID(name string) string {
+	return "policy/" + name
+}
+
+func secretsEngineNodeID(path string) string {
+	return "secrets/" + path
+}
+
+func startupSecretNodeID(path string) string {
+	return "startup-secret/" + path
+}
+
+// configurationGraph builds the dependency graph of the resources of the configuration:
+//   - plugins are registered before the auth methods and secret engines using them
+//   - auth methods are enabled before their configuration and the group aliases and policies referencing them
+//   - auth methods are configured before their roles, which are written independently of each other
+//   - policies are written before the roles, entities and groups referencing them
+//   - entities are written before their aliases and the groups they are members of
+//   - groups are written before their aliases and the groups they are members of
+//   - secret engines are mounted before the startup secrets written into them
+//   - the OIDC provider resources are written after the entities, groups and OIDC resources they reference
+func (v *vault) configurationGraph(ctx context.Context) *configGraph {
+	g := newConfigGraph()
+	config := v.externalConfig
+
+	// Add all the nodes first, so the references can be resolved regardless of the order of the configuration
+	for _, managedPlugin := range config.Plugins {
+		g.add(pluginNodeID(managedPlugin.Type, managedPlugin.Name), func(ctx context.Context) error {
+			return v.addManagedPlugins(ctx, []plugin{managedPlugin})
+		})
+	}
+
+	for _, auditDevice := range initAuditConfig(config.Audit) {
+		g.add(auditNodeID(auditDevice.Path), func(ctx context.Context) error {
+			return v.addManagedAudits(ctx, []audit{auditDevice})
+		})
+	}
+
+	managedAuths := initAuthConfig(config.Auth)
+	for _, authMethod := range managedAuths {
+		g.add(authNodeID(authMethod.Path), func(ctx context.Context) error {
+			return v.addManagedAuthMethods(ctx, []auth{authMethod})
+		})
+
+		if _, ok := authRoleSubPaths[authMethod.Type]; !ok {
+			g.add(authConfigNodeID(authMethod.Path), func(ctx context.Context) error {
+				return errors.Wrap(v.addAdditionalAuthConfig(ctx, authMethod), "error while adding auth method config")
+			}, authNodeID(authMethod.Path))
+
+			continue
+		}
+
+		// The roles are written by their own nodes
+		authConfig := authMethod
+		authConfig.Roles = nil
+		g.add(authConfigNodeID(authMethod.Path), func(ctx context.Context) error {
+			return errors.Wrap(v.addAdditionalAuthConfig(ctx, authConfig), "error while adding auth method config")
+		}, authNodeID(authMethod.Path))
+
+		for i, role := range authMethod.Roles {
+			g.add(authRoleNodeID(authMethod.Path, authRoleName(role, i)), func(ctx context.Context) error {
+				return errors.Wrap(v.addAuthRole(ctx, authMethod, role), "error while adding auth method role")
+			}, authConfigNodeID(authMethod.Path))
+		}
+	}
+
+	for _, managedPolicy := range config.Policies {
+		g.add(policyNodeID(managedPolicy.Name), func(ctx context.Context) error {
+			return v.addManagedPolicy(ctx, managedPolicy)
+		})
+	}
+
+	for _, managedEntity := range config.Entities {
+		g.add(entityNodeID(managedEntity.Name), func(ctx context.Context) error {
+			return v.addManagedEntities(ctx, []entity{managedEntity})
+		})
+	}
+
+	for _, managedEntityAlias := range config.EntityAliases {
+		g.add(entityAliasNodeID(managedEntityAlias), func(ctx context.Context) error {
+			return v.addManagedEntityAliases(ctx, []entityAlias{managedEntityAlias})
+		})
+	}
+
+	for _, managedGroup := range config.Groups {
+		g.add(groupNodeID(managedGroup.Name), func(ctx context.Context) error {
+			return v.addManagedGroups(ctx, []group{managedGroup})
+		})
+	}
+
+	for _, managedGroupAlias := range config.GroupAliases {
+		g.add(groupAliasNodeID(managedGroupAlias), func(ctx context.Context) error {
+			return v.addManagedGroupAliases(ctx, []groupAlias{managedGroupAlias})
+		})
+	}
+
+	oidcResources := v.oidcResources()
+	for _, resource := range oidcResources {
+		g.add(oidcNodeID(resource.kind, resource.name), func(ctx context.Context) error {
+			return v.addOIDCResource(ctx, resource)
+		})
+	}
+
+	managedSecretsEngines := initSecretsEnginesConfig(config.Secrets)
+	for _, managedSecretEngine := range managedSecretsEngines {
+		g.add(secretsEngineNodeID(managedSecretEngine.Path), func(ctx context.Context) error {
+			return v.addManagedSecretsEngines(ctx, []secretEngine{managedSecretEngine})
+		})
+	}
+
+	for _, startupSecret := range config.StartupSecrets {
+		g.add(startupSecretNodeID(startupSecret.Path), func(ctx context.Context) error {
+			return v.addStartupSecret(ctx, startupSecret)
+		})
+	}
+
+	// Add the dependencies
+	for _, authMethod := range managedAuths {
+		g.dependOn(g.nodes[authNodeID(authMethod.Path)], pluginNodeID("auth", authMethod.Type))
+
+		node := g.nodes[authConfigNodeID(authMethod.Path)]
+		if _, ok := authRoleSubPaths[authMethod.Type]; !ok {
+			for _, policyName := range policyReferences(authMethod.Roles, authMethod.Users, authMethod.Groups) {
+				g.dependOn(node, policyNodeID(policyName))
+			}
+
+			continue
+		}
+
+		for _, policyName := range policyReferences(authMethod.Users, authMethod.Groups) {
+			g.dependOn(node, policyNodeID(policyName))
+		}
+		for i, role := range authMethod.Roles {
+			node := g.nodes[authRoleNodeID(authMethod.Path, authRoleName(role, i))]
+			for _, policyName := range policyReferences([]interface{}{role}) {
+				g.dependOn(node, policyNodeID(policyName))
+			}
+		}
+	}
+
+	for _, policy := range config.Policies {
+		node := g.nodes[policyNodeID(policy.Name)]
+		for _, authMethod := range managedAuths {
+			if strings.Contains(policy.Rules, "__accessor__"+authMethod.Path) {
+				g.dependOn(node, authNodeID(authMethod.Path))
+			}
+		}
+	}
+
+	for _, entity := range config.Entities {
+		node := g.nodes[entityNodeID(entity.Name)]
+		for _, policyName := range entity.Policies {
+			g.dependOn(node, policyNodeID(policyName))
+		}
+	}
+
+	for _, group := range config.Groups {
+		node := g.nodes[groupNodeID(group.Name)]
+		for _, policyName := range group.Policies {
+			g.dependOn(node, policyNodeID(policyName))
+		}
+	}
+
+	var existingAuths map[string]bool
+	authExists := func(path string) func() (bool, error) {
+		return func() (bool, error) {
+			if existingAuths == nil {
+				auths, err := v.getExistingAuthMethods(ctx)
+				if err != nil {
+					return false, err
+				}
+
+				existingAuths = make(map[string]bool, len(auths))
+				for authPath := range auths {
+					existingAuths[authPath] = true
+				}
+			}
+
+			return existingAuths[strings.Trim(path, "/")], nil
+		}
+	}
+
+	identityExists := func(kind, name string) func() (bool, error) {
+		return func() (bool, error) {
+			id, err := v.identityID(ctx, kind, name)
+			return id != "", err
+		}
+	}
+
+	for _, groupAlias := range config.GroupAliases {
+		node := g.nodes[groupAliasNodeID(groupAlias)]
+		g.require(node, authNodeID(strings.Trim(groupAlias.MountPath, "/")), authExists(groupAlias.MountPath))
+		g.require(node, groupNodeID(groupAlias.Group), identityExists("group", groupAlias.Group))
+	}
+
+	for _, entityAlias := range config.EntityAliases {
+		node := g.nodes[entityAliasNodeID(entityAlias)]
+		g.require(node, authNodeID(strings.Trim(entityAlias.MountPath, "/")), authExists(entityAlias.MountPath))
+		g.require(node, entityNodeID(entityAlias.Entity), identityExists("entity", entityAlias.Entity))
+	}
+
+	for _, group := range config.Groups {
+		node := g.nodes[groupNodeID(group.Name)]
+		for _, memberEntity := range group.MemberEntities {
+			g.require(node, entityNodeID(memberEntity), identityExists("entity", memberEntity))
+		}
+		for _, memberGroup := range group.MemberGroups {
+			g.require(node, groupNodeID(memberGroup), identityExists("group", memberGroup))
+		}
+	}
+
+	for _, resource := range oidcResources {
+		node := g.nodes[oidcNodeID(resource.kind, resource.name)]
+		for _, reference := range resource.references {
+			switch {
+			case reference.name == "" || oidcBuiltins[reference.kind][reference.name]:
+				continue
+			case reference.kind == "entity":
+				g.require(node, entityNodeID(reference.name), identityExists("entity", reference.name))
+			case reference.kind == "group":
+				g.require(node, groupNodeID(reference.name), identityExists("group", reference.name))
+			default:
+				g.require(node, oidcNodeID(reference.kind, reference.name), func() (bool, error) {
+					return v.oidcResourceExists(ctx, reference.kind, reference.name)
+				})
+			}
+		}
+	}
+
+	for _, secretEngine := range managedSecretsEngines {
+		node := g.nodes[secretsEngineNodeID(secretEngine.Path)]
+
+		pluginName := secretEngine.PluginName
+		if pluginName == "" {
+			pluginName = secretEngine.Type
+		}
+		g.dependOn(node, pluginNodeID("secret", pluginName))
+
+		// Database connections reference database plugins
+		for _, configData := range secretEngine.Configuration {
+			for _, subConfigData := range cast.ToSlice(configData) {
+				if pluginName := cast.ToString(cast.ToStringMap(subConfigData)["plugin_name"]); pluginName != "" {
+					g.dependOn(node, pluginNodeID("database", pluginName))
+				}
+			}
+		}
+	}
+
+	for _, startupSecret := range config.StartupSecrets {
+		node := g.nodes[startupSecretNodeID(startupSecret.Path)]
+
+		// The secret is written into the secret engine with the longest matching path
+		mountPath := ""
+		for _, secretEngine := range managedSecretsEngines {
+			if strings.HasPrefix(startupSecret.Path, secretEngine.Path+"/") && len(secretEngine.Path) > len(mountPath) {
+				mountPath = secretEngine.Path
+			}
+		}
+		if mountPath != "" {
+			g.dependOn(node, secretsEngineNodeID(mountPath))
+		}
+	}
+
+	return g
+}
+
+// authRoleName returns the name of the role, or its index in the configuration if it has no name
+func authRoleName(role interface{}, i int) string {
+	if name := cast.ToString(cast.ToStringMap(role)["name"]); name != "" {
+		return name
+	}
+
+	return fmt.Sprintf("#%d", i)
+}
+
+// policyReferences returns the policy names referenced in the policies and token_policies fields of the roles
+// and the user and group mappings of auth methods
+func policyReferences(values ...interface{}) []string {
+	var policies []string
+
+	var collect func(value interface{}, depth int)
+	collect = func(value interface{}, depth int) {
+		if depth > 2 {
+			return
+		}
+
+		switch value := value.(type) {
+		case []interface{}:
+			for _, item := range value {
+				collect(item, depth+1)
+			}
+		case map[string]interface{}, map[interface{}]interface{}:
+			for key, item := range cast.ToStringMap(value) {
+				if key == "policies" || key == "token_policies" {
+					policies = append(policies, toStringSlice(item)...)
+				} else {
+					collect(item, depth+1)
+				}
+			}
+		}
+	}
+
+	for _, value := range values {
+		collect(value, 0)
+	}
+
+	return policies
+}
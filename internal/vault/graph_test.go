@@ -0,0 +1,176 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"emperror.dev/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nodeIDs(nodes []*configNode) []string {
+	ids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.id)
+	}
+
+	return ids
+}
+
+func TestConfigurationGraph(t *testing.T) {
+	config, err := decodeExternalConfig(&externalConfig{}, map[string]interface{}{
+		"group-aliases": []interface{}{
+			map[string]interface{}{"name": "admins", "mountpath": "oidc", "group": "admin"},
+		},
+		"groups": []interface{}{
+			map[string]interface{}{"name": "operators", "member_entities": []interface{}{"alice"}, "member_groups": []interface{}{"admin"}},
+			map[string]interface{}{"name": "admin", "type": "external", "policies": []interface{}{"admin"}},
+		},
+		"entity-aliases": []interface{}{
+			map[string]interface{}{"name": "alice@example.com", "mountpath": "oidc", "entity": "alice"},
+		},
+		"entities": []interface{}{
+			map[string]interface{}{"name": "alice", "policies": []interface{}{"admin"}},
+		},
+		"auth": []interface{}{
+			map[string]interface{}{
+				"type": "oidc",
+				"roles": []interface{}{
+					map[string]interface{}{"name": "default", "token_policies": "admin,default"},
+				},
+			},
+		},
+		"policies": []interface{}{
+			map[string]interface{}{"name": "admin", "rules": `path "auth/__accessor__oidc" {}`},
+		},
+		"plugins": []interface{}{
+			map[string]interface{}{"plugin_name": "oidc", "type": "auth", "command": "oidc", "sha256": "abc"},
+		},
+	})
+	require.NoError(t, err)
+
+	v := &vault{externalConfig: config}
+
+	nodes, err := v.configurationGraph(context.Background()).sorted()
+	require.NoError(t, err)
+
+	expected := []string{
+		"plugin/auth/oidc",
+		"auth/oidc",
+		"auth-config/oidc",
+		"policy/admin",
+		"auth-role/oidc/default",
+		"entity/alice",
+		"entity-alias/alice@example.com@oidc",
+		"group/admin",
+		"group/operators",
+		"group-alias/admins@oidc",
+	}
+	assert.Equal(t, expected, nodeIDs(nodes))
+}
+
+func TestConfigGraphCycle(t *testing.T) {
+	g := newConfigGraph()
+	g.add("a", nil, "b")
+	g.add("b", nil, "c")
+	g.add("c", nil, "a")
+
+	_, err := g.sorted()
+
+	assert.EqualError(t, err, "dependency cycle in the configuration: a -> b -> c -> a")
+}
+
+func TestConfigurationGraphMissingReference(t *testing.T) {
+	v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sys/auth":
+			_, _ = w.Write([]byte(`{"data": {"token/": {"type": "token"}}}`))
+		case "/v1/identity/group/name/admin":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+		}
+	}))
+
+	config, err := decodeExternalConfig(&externalConfig{}, map[string]interface{}{
+		"group-aliases": []interface{}{
+			map[string]interface{}{"name": "admins", "mountpath": "oidc", "group": "admin"},
+		},
+	})
+	require.NoError(t, err)
+
+	v.externalConfig = config
+
+	_, err = v.configurationGraph(context.Background()).sorted()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "group-alias/admins@oidc references auth/oidc, which is neither configured nor exists in vault")
+	assert.Contains(t, err.Error(), "group-alias/admins@oidc references group/admin, which is neither configured nor exists in vault")
+}
+
+func TestApplyNodes(t *testing.T) {
+	var mu sync.Mutex
+	var applied []string
+
+	apply := func(id string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err == nil {
+				applied = append(applied, id)
+			}
+
+			return err
+		}
+	}
+
+	g := newConfigGraph()
+	g.add("a", apply("a", nil))
+	g.add("b", apply("b", errors.New("b failed")), "a")
+	g.add("c", apply("c", nil), "b")
+	g.add("d", apply("d", errors.New("d failed")))
+	g.add("e", apply("e", nil), "a", "a")
+
+	nodes, err := g.sorted()
+	require.NoError(t, err)
+
+	err = applyNodes(context.Background(), nodes, 2)
+
+	require.Error(t, err)
+	assert.Equal(t, "error configuring b: b failed; error configuring d: d failed", err.Error())
+	assert.ElementsMatch(t, []string{"a", "e"}, applied)
+}
+
+func TestApplyNodesCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	applied := 0
+	g := newConfigGraph()
+	g.add("a", func(nodeCtx context.Context) error {
+		applied++
+		cancel()
+
+		// The context of the configuration is passed to the Vault calls of the node
+		assert.ErrorIs(t, nodeCtx.Err(), context.Canceled)
+
+		return nil
+	})
+	g.add("b", func(context.Context) error {
+		applied++
+
+		return nil
+	}, "a")
+
+	nodes, err := g.sorted()
+	require.NoError(t, err)
+
+	err = applyNodes(ctx, nodes, 1)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, applied)
+}
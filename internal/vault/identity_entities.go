@@ -0,0 +1,236 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"emperror.dev/errors"
+)
+
+type entity struct {
+	Name     string                 `mapstructure:"name"`
+	Policies []string               `mapstructure:"policies"`
+	Metadata map[string]interface{} `mapstructure:"metadata"`
+	Disabled bool                   `mapstructure:"disabled"`
+}
+
+type entityAlias struct {
+	Name      string `mapstructure:"name"`
+	MountPath string `mapstructure:"mountpath"`
+	Entity    string `mapstructure:"entity"`
+}
+
+// purgesEntities tells whether the unmanaged entities or entity aliases are removed. Vault creates an entity
+// and an alias for every login through an auth method, so only the ones created by the configuration are
+// removed, which needs the ownership to be tracked.
+func (v *vault) purgesEntities(excluded bool) bool {
+	return v.purges(excluded) && v.ownership != nil
+}
+
+//
+// Entities.
+
+func (v *vault) getExistingEntities(ctx context.Context) (map[string]bool, error) {
+	names, err := v.listKeys(ctx, "identity/entity/name")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve list of entities")
+	}
+
+	existingEntities := make(map[string]bool, len(names))
+	for _, name := range names {
+		existingEntities[name] = true
+	}
+
+	return existingEntities, nil
+}
+
+func getUnmanagedEntities(existingEntities map[string]bool, managedEntities []entity) map[string]bool {
+	for _, managedEntity := range managedEntities {
+		delete(existingEntities, managedEntity.Name)
+	}
+
+	return existingEntities
+}
+
+func (v *vault) addManagedEntities(ctx context.Context, managedEntities []entity) error {
+	for _, entity := range managedEntities {
+		id, err := v.entityID(ctx, entity.Name)
+		if err != nil {
+			return errors.Wrap(err, "error reading entity")
+		}
+
+		config := map[string]interface{}{
+			"name":     entity.Name,
+			"policies": entity.Policies,
+			"metadata": entity.Metadata,
+			"disabled": entity.Disabled,
+		}
+
+		if id == "" {
+			slog.Info(fmt.Sprintf("adding entity %s", entity.Name))
+			_, err = v.writeWithWarningCheck(ctx, "identity/entity", config)
+			v.cache.invalidateIdentities("entity")
+			if err != nil {
+				return errors.Wrapf(err, "failed to create entity %s", entity.Name)
+			}
+			v.ownership.add(ownedEntities, entity.Name)
+		} else {
+			slog.Info(fmt.Sprintf("tuning already existing entity: %s", entity.Name))
+			_, err = v.writeWithWarningCheck(ctx, fmt.Sprintf("identity/entity/name/%s", entity.Name), config)
+			if err != nil {
+				return errors.Wrapf(err, "failed to tune entity %s", entity.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *vault) removeUnmanagedEntities(ctx context.Context, managedEntities []entity) error {
+	if !v.purgesEntities(v.externalConfig.PurgeUnmanagedConfig.Exclude.Entities) {
+		slog.Debug("purge config is disabled or not limited to the owned resources, no unmanaged entities will be removed")
+		return nil
+	}
+
+	existingEntities, err := v.getExistingEntities(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get existing entities from vault")
+	}
+
+	unmanagedEntities := filterOwned(v.ownership, ownedEntities, getUnmanagedEntities(existingEntities, managedEntities))
+	for _, unmanagedEntityName := range sortedKeys(unmanagedEntities) {
+		slog.Info(fmt.Sprintf("removing entity %s", unmanagedEntityName))
+		_, err := v.cl.Logical().DeleteWithContext(ctx, "identity/entity/name/"+unmanagedEntityName)
+		v.cache.invalidateIdentities("entity")
+		v.cache.invalidateAliases("entity")
+		if err != nil {
+			return errors.Wrapf(err, "error removing entity %s from vault", unmanagedEntityName)
+		}
+		v.ownership.remove(ownedEntities, unmanagedEntityName)
+	}
+
+	return nil
+}
+
+//
+// Entity Aliases.
+
+func (v *vault) addManagedEntityAliases(ctx context.Context, managedEntityAliases []entityAlias) error {
+	for _, entityAlias := range managedEntityAliases {
+		name := aliasName(entityAlias.Name, entityAlias.MountPath)
+
+		accessor, err := v.authMountAccessor(ctx, entityAlias.MountPath)
+		if err != nil {
+			return errors.Wrapf(err, "error getting mount accessor for %s", entityAlias.MountPath)
+		}
+
+		id, err := v.entityID(ctx, entityAlias.Entity)
+		if err != nil {
+			return errors.Wrapf(err, "error getting canonical_id for entity %s", entityAlias.Entity)
+		}
+		if id == "" {
+			return errors.Errorf("entity %s does not exist", entityAlias.Entity)
+		}
+
+		config := map[string]interface{}{
+			"name":           entityAlias.Name,
+			"mount_accessor": accessor,
+			"canonical_id":   id,
+		}
+
+		existingAliases, err := v.listAliases(ctx, "entity")
+		if err != nil {
+			return errors.Wrap(err, "error listing entity-aliases")
+		}
+
+		existing, ok := existingAliases[aliasKey(entityAlias.Name, accessor)]
+		if !ok {
+			slog.Info(fmt.Sprintf("adding entity-alias: %s", name))
+			_, err = v.writeWithWarningCheck(ctx, "identity/entity-alias", config)
+			v.cache.invalidateAliases("entity")
+			if err != nil {
+				return errors.Wrapf(err, "failed to create entity-alias %s", name)
+			}
+			v.ownership.add(ownedEntityAliases, name)
+		} else {
+			slog.Info(fmt.Sprintf("tuning already existing entity-alias: %s - ID: %s", name, existing.id))
+			_, err = v.writeWithWarningCheck(ctx, fmt.Sprintf("identity/entity-alias/id/%s", existing.id), config)
+			if err != nil {
+				return errors.Wrapf(err, "failed to tune entity-alias %s", name)
+			}
+			existing.canonicalID = id
+			v.cache.updateAlias("entity", existing)
+		}
+	}
+
+	return nil
+}
+
+// getUnmanagedEntityAliases returns the IDs of the entity aliases which are not in the configuration,
+// by their names and the paths of their auth methods
+func (v *vault) getUnmanagedEntityAliases(ctx context.Context, managedEntityAliases []entityAlias) (map[string]string, error) {
+	managed := map[string]bool{}
+	for _, managedEntityAlias := range managedEntityAliases {
+		managed[aliasName(managedEntityAlias.Name, managedEntityAlias.MountPath)] = true
+	}
+
+	return v.getUnmanagedAliases(ctx, "entity", managed)
+}
+
+func (v *vault) removeUnmanagedEntityAliases(ctx context.Context, managedEntityAliases []entityAlias) error {
+	if !v.purgesEntities(v.externalConfig.PurgeUnmanagedConfig.Exclude.EntityAliases) {
+		slog.Debug("purge config is disabled or not limited to the owned resources, no unmanaged entity-alias will be removed")
+		return nil
+	}
+
+	unmanagedAliases, err := v.getUnmanagedEntityAliases(ctx, managedEntityAliases)
+	if err != nil {
+		return errors.Wrap(err, "failed to get existing entity-alias from vault")
+	}
+
+	unmanagedAliases = filterOwned(v.ownership, ownedEntityAliases, unmanagedAliases)
+	for _, unmanagedAliasName := range sortedKeys(unmanagedAliases) {
+		unmanagedAliasID := unmanagedAliases[unmanagedAliasName]
+
+		slog.Info(fmt.Sprintf("removing entity-alias %s", unmanagedAliasName))
+		_, err := v.cl.Logical().DeleteWithContext(ctx, "identity/entity-alias/id/"+unmanagedAliasID)
+		v.cache.invalidateAliases("entity")
+		if err != nil {
+			return errors.Wrapf(err, "error removing entity-alias %s with ID %s from vault", unmanagedAliasName, unmanagedAliasID)
+		}
+		v.ownership.remove(ownedEntityAliases, unmanagedAliasName)
+	}
+
+	return nil
+}
+
+//
+// Purge unmanaged entities and entity-aliases.
+
+func (v *vault) purgeIdentityEntities(ctx context.Context) error {
+	// The aliases go first, as they are removed together with their entities
+	if err := v.removeUnmanagedEntityAliases(ctx, v.externalConfig.EntityAliases); err != nil {
+		return errors.Wrap(err, "error while removing entity aliases")
+	}
+
+	if err := v.removeUnmanagedEntities(ctx, v.externalConfig.Entities); err != nil {
+		return errors.Wrap(err, "error while removing entities")
+	}
+
+	return nil
+}
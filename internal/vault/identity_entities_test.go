@@ -0,0 +1,118 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newIdentityTestServer serves a Vault with the userpass and oidc auth methods, the alice entity with an alias
+// and the admins group with an alias on both auth methods, and records the writes and counts the lists by their paths
+func newIdentityTestServer(t *testing.T) (*vault, map[string]map[string]interface{}, map[string]int) {
+	var mu sync.Mutex
+	lists := map[string]int{}
+
+	handler, writes := recordWrites(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "LIST" || r.URL.Query().Get("list") == "true" {
+			mu.Lock()
+			lists[r.URL.Path]++
+			mu.Unlock()
+		}
+
+		switch r.URL.Path {
+		case "/v1/sys/auth":
+			_, _ = w.Write([]byte(`{"data": {"userpass/": {"type": "userpass", "accessor": "auth_userpass_1"},
+				"oidc/": {"type": "oidc", "accessor": "auth_oidc_1"}}}`))
+		case "/v1/identity/entity/id":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["e1", "e2"], "key_info": {"e1": {"name": "alice"}, "e2": {"name": "bob"}}}}`))
+		case "/v1/identity/group/id":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["g1"], "key_info": {"g1": {"name": "admins"}}}}`))
+		case "/v1/identity/entity-alias/id":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["a1", "a2"], "key_info": {
+				"a1": {"name": "alice", "mount_accessor": "auth_userpass_1", "canonical_id": "e1"},
+				"a2": {"name": "carol", "mount_accessor": "auth_userpass_1", "canonical_id": "e2"}
+			}}}`))
+		case "/v1/identity/group-alias/id":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["ga1", "ga2"], "key_info": {
+				"ga1": {"name": "admins", "mount_accessor": "auth_oidc_1", "canonical_id": "g1"},
+				"ga2": {"name": "admins", "mount_accessor": "auth_userpass_1", "canonical_id": "g1"}
+			}}}`))
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	v := newTestVault(t, handler)
+	v.cache = &configCache{}
+
+	return v, writes, lists
+}
+
+func TestAddManagedEntityAliases(t *testing.T) {
+	v, writes, lists := newIdentityTestServer(t)
+
+	err := v.addManagedEntityAliases(context.Background(), []entityAlias{
+		// The existing alias is moved to another entity
+		{Name: "alice", MountPath: "userpass", Entity: "bob"},
+		{Name: "bob", MountPath: "userpass/", Entity: "bob"},
+	})
+	require.NoError(t, err)
+
+	expected := map[string]map[string]interface{}{
+		"/v1/identity/entity-alias/id/a1": {"name": "alice", "mount_accessor": "auth_userpass_1", "canonical_id": "e2"},
+		"/v1/identity/entity-alias":       {"name": "bob", "mount_accessor": "auth_userpass_1", "canonical_id": "e2"},
+	}
+	assert.Equal(t, expected, writes)
+
+	// The tuned alias is updated in the cache, so the aliases are only listed once
+	assert.Equal(t, 1, lists["/v1/identity/entity-alias/id"])
+
+	err = v.addManagedEntityAliases(context.Background(), []entityAlias{{Name: "dave", MountPath: "userpass", Entity: "dave"}})
+	assert.EqualError(t, err, "entity dave does not exist")
+}
+
+func TestGetUnmanagedEntityAliases(t *testing.T) {
+	v, _, _ := newIdentityTestServer(t)
+
+	unmanaged, err := v.getUnmanagedEntityAliases(context.Background(), []entityAlias{{Name: "alice", MountPath: "userpass", Entity: "alice"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"carol@userpass": "a2"}, unmanaged)
+
+	// Vault creates entities and aliases on logins, so they are only removed if the ownership is tracked
+	v.externalConfig.PurgeUnmanagedConfig.Enabled = true
+	assert.False(t, v.purgesEntities(false))
+
+	v.ownership = &ownership{resources: map[string]map[string]bool{}}
+	assert.True(t, v.purgesEntities(false))
+	assert.False(t, v.purgesEntities(true))
+}
+
+func TestAddManagedInternalGroups(t *testing.T) {
+	v, writes, _ := newIdentityTestServer(t)
+
+	err := v.addManagedGroups(context.Background(), []group{
+		{Name: "admins", MemberEntities: []string{"alice", "bob"}},
+		{Name: "operators", Type: "internal", MemberGroups: []string{"admins"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"name":              "admins",
+		"type":              "internal",
+		"policies":          nil,
+		"metadata":          nil,
+		"member_entity_ids": []interface{}{"e1", "e2"},
+		"member_group_ids":  []interface{}{},
+	}, writes["/v1/identity/group/name/admins"])
+	assert.Equal(t, []interface{}{"g1"}, writes["/v1/identity/group"]["member_group_ids"])
+
+	err = v.addManagedGroups(context.Background(), []group{{Name: "oidc", Type: "external", MemberEntities: []string{"alice"}}})
+	assert.EqualError(t, err, "external group oidc can't have members, use group aliases instead")
+
+	err = v.addManagedGroups(context.Background(), []group{{Name: "admins", MemberGroups: []string{"missing"}}})
+	assert.EqualError(t, err, "error resolving the member groups of group admins: group missing does not exist")
+}
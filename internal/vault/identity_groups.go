@@ -15,6 +15,7 @@
 package vault
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -29,6 +30,9 @@ type group struct {
 	Type     string                 `mapstructure:"type"`
 	Policies []string               `mapstructure:"policies"`
 	Metadata map[string]interface{} `mapstructure:"metadata"`
+	// The members of internal groups by their names, external groups get their members through group aliases
+	MemberEntities []string `mapstructure:"member_entities"`
+	MemberGroups   []string `mapstructure:"member_groups"`
 }
 
 type groupAlias struct {
@@ -52,21 +56,10 @@ func readVaultGroup(group string, client *api.Client) (secret *api.Secret, err e
 	return secret, nil
 }
 
-func readVaultGroupAlias(id string, client *api.Client) (secret *api.Secret, err error) {
-	secret, err = client.Logical().Read(fmt.Sprintf("identity/group-alias/id/%s", id))
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to read group alias by id %s", id)
-	}
-	if secret == nil {
-		// No Data returned, Group does not exist
-		return nil, nil
-	}
-	return secret, nil
-}
-
-func getVaultAuthMountAccessor(path string, client *api.Client) (accessor string, err error) {
+// authMountAccessor returns the accessor of the auth method mounted at the path
+func (v *vault) authMountAccessor(ctx context.Context, path string) (accessor string, err error) {
 	path = strings.TrimRight(path, "/") + "/"
-	mounts, err := client.Sys().ListAuth()
+	mounts, err := v.listAuth(ctx)
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to read auth mounts from vault")
 	}
@@ -76,66 +69,43 @@ func getVaultAuthMountAccessor(path string, client *api.Client) (accessor string
 	return mounts[path].Accessor, nil
 }
 
-func getVaultGroupID(group string, client *api.Client) (id string, err error) {
-	g, err := readVaultGroup(group, client)
-	if err != nil {
-		return "", errors.Wrapf(err, "error reading group %s", group)
-	}
-	if g == nil {
-		return "", errors.Errorf("group %s does not exist", group)
-	}
-	return g.Data["id"].(string), nil
+// aliasName identifies the group or entity alias in the logs, the plan and the ownership record,
+// the names of the aliases are only unique per auth method
+func aliasName(name, mountPath string) string {
+	return name + "@" + strings.Trim(mountPath, "/")
 }
 
-func getVaultGroupAliasName(aliasID string, client *api.Client) (id string, err error) {
-	alias, err := readVaultGroupAlias(aliasID, client)
+// getUnmanagedAliases returns the IDs of the group or entity aliases which are not managed, by their names
+// and the paths of their auth methods, see aliasName
+func (v *vault) getUnmanagedAliases(ctx context.Context, kind string, managed map[string]bool) (map[string]string, error) {
+	existingAliases, err := v.listAliases(ctx, kind)
 	if err != nil {
-		return "", errors.Wrapf(err, "error reading group alias %s", aliasID)
-	}
-	if alias == nil {
-		return "", errors.Errorf("group alias %s does not exist", aliasID)
+		return nil, errors.Wrapf(err, "failed to retrieve list of %s-alias", kind)
 	}
-	return alias.Data["name"].(string), nil
-}
 
-func getVaultGroupAliasMount(aliasID string, client *api.Client) (id string, err error) {
-	alias, err := readVaultGroupAlias(aliasID, client)
+	auths, err := v.listAuth(ctx)
 	if err != nil {
-		return "", errors.Wrapf(err, "error reading group alias %s", aliasID)
-	}
-	if alias == nil {
-		return "", errors.Errorf("group alias %s does not exist", aliasID)
+		return nil, errors.Wrap(err, "error while getting list of auth engines")
 	}
-	return alias.Data["mount_accessor"].(string), nil
-}
 
-func findVaultGroupAliasIDFromNameAndMount(name string, accessor string, client *api.Client) (id string, err error) {
-	aliases, err := client.Logical().List("identity/group-alias/id")
-	if err != nil {
-		return "", errors.Wrap(err, "error listing group aliases")
-	}
-	if aliases == nil {
-		return "", nil
+	mountPaths := map[string]string{}
+	for path, authMethod := range auths {
+		mountPaths[authMethod.Accessor] = path
 	}
 
-	for _, alias := range aliases.Data["keys"].([]interface{}) {
-		aliasName, err := getVaultGroupAliasName(cast.ToString(alias), client)
-		if err != nil {
-			return "", errors.Wrapf(err, "error fetching name for alias id: %s err", alias)
+	unmanagedAliases := map[string]string{}
+	for _, alias := range existingAliases {
+		mountPath, ok := mountPaths[alias.mountAccessor]
+		if !ok {
+			mountPath = alias.mountAccessor
 		}
 
-		aliasMount, err := getVaultGroupAliasMount(cast.ToString(alias), client)
-		if err != nil {
-			return "", errors.Wrapf(err, "error fetching mount for alias id: %s err", alias)
-		}
-
-		if aliasName == name && aliasMount == accessor {
-			return cast.ToString(alias), nil
+		if name := aliasName(alias.name, mountPath); !managed[name] {
+			unmanagedAliases[name] = alias.id
 		}
 	}
 
-	// Did not find any alias matching Name and MountPath
-	return "", nil
+	return unmanagedAliases, nil
 }
 
 //
@@ -170,19 +140,13 @@ func getUnmanagedGroups(existingGroups map[string]bool, managedGroups []group) m
 	return existingGroups
 }
 
-func (v *vault) addManagedGroups(managedGroups []group) error {
+func (v *vault) addManagedGroups(ctx context.Context, managedGroups []group) error {
 	for _, group := range managedGroups {
-		g, err := readVaultGroup(group.Name, v.cl)
+		id, err := v.groupID(ctx, group.Name)
 		if err != nil {
 			return errors.Wrap(err, "error reading group")
 		}
 
-		// Currently does not support specifying members directly in the group config
-		// Use group aliases for that
-		if group.Type != "external" {
-			return errors.Errorf("only external groups are supported for now")
-		}
-
 		config := map[string]interface{}{
 			"name":     group.Name,
 			"type":     group.Type,
@@ -190,15 +154,40 @@ func (v *vault) addManagedGroups(managedGroups []group) error {
 			"metadata": group.Metadata,
 		}
 
-		if g == nil {
+		switch group.Type {
+		case "external":
+			if len(group.MemberEntities) > 0 || len(group.MemberGroups) > 0 {
+				return errors.Errorf("external group %s can't have members, use group aliases instead", group.Name)
+			}
+		case "", "internal":
+			memberEntityIDs, err := v.identityIDs(ctx, "entity", group.MemberEntities)
+			if err != nil {
+				return errors.Wrapf(err, "error resolving the member entities of group %s", group.Name)
+			}
+
+			memberGroupIDs, err := v.identityIDs(ctx, "group", group.MemberGroups)
+			if err != nil {
+				return errors.Wrapf(err, "error resolving the member groups of group %s", group.Name)
+			}
+
+			config["type"] = "internal"
+			config["member_entity_ids"] = memberEntityIDs
+			config["member_group_ids"] = memberGroupIDs
+		default:
+			return errors.Errorf("unsupported type %s of group %s", group.Type, group.Name)
+		}
+
+		if id == "" {
 			slog.Info(fmt.Sprintf("adding group %s", group.Name))
-			_, err = v.writeWithWarningCheck("identity/group", config)
+			_, err = v.writeWithWarningCheck(ctx, "identity/group", config)
+			v.cache.invalidateIdentities("group")
 			if err != nil {
 				return errors.Wrapf(err, "failed to create group %s", group.Name)
 			}
+			v.ownership.add(ownedGroups, group.Name)
 		} else {
 			slog.Info(fmt.Sprintf("tuning already existing group: %s", group.Name))
-			_, err = v.writeWithWarningCheck(fmt.Sprintf("identity/group/name/%s", group.Name), config)
+			_, err = v.writeWithWarningCheck(ctx, fmt.Sprintf("identity/group/name/%s", group.Name), config)
 			if err != nil {
 				return errors.Wrapf(err, "failed to tune group %s", group.Name)
 			}
@@ -208,7 +197,7 @@ func (v *vault) addManagedGroups(managedGroups []group) error {
 	return nil
 }
 
-func (v *vault) removeUnmanagedGroups(managedGroups []group) error {
+func (v *vault) removeUnmanagedGroups(ctx context.Context, managedGroups []group) error {
 	if !v.externalConfig.PurgeUnmanagedConfig.Enabled || v.externalConfig.PurgeUnmanagedConfig.Exclude.Groups {
 		slog.Debug("purge config is disabled, no unmanaged groups will be removed")
 		return nil
@@ -219,13 +208,15 @@ func (v *vault) removeUnmanagedGroups(managedGroups []group) error {
 		return errors.Wrapf(err, "failed to get existing groups from vault")
 	}
 
-	unmanagedGroups := getUnmanagedGroups(existingGroups, managedGroups)
+	unmanagedGroups := filterOwned(v.ownership, ownedGroups, getUnmanagedGroups(existingGroups, managedGroups))
 	for unmanagedGroupName := range unmanagedGroups {
 		slog.Info(fmt.Sprintf("removing group %s", unmanagedGroupName))
-		_, err := v.cl.Logical().Delete("identity/group/name/" + unmanagedGroupName)
+		_, err := v.cl.Logical().DeleteWithContext(ctx, "identity/group/name/"+unmanagedGroupName)
+		v.cache.invalidateIdentities("group")
 		if err != nil {
 			return errors.Wrapf(err, "error removing group %s from vault", unmanagedGroupName)
 		}
+		v.ownership.remove(ownedGroups, unmanagedGroupName)
 	}
 
 	return nil
@@ -234,19 +225,24 @@ func (v *vault) removeUnmanagedGroups(managedGroups []group) error {
 //
 // Group Aliases.
 
-func (v *vault) addManagedGroupAliases(managedGroupAliases []groupAlias) error {
+func (v *vault) addManagedGroupAliases(ctx context.Context, managedGroupAliases []groupAlias) error {
 	// Group Aliases for External Groups might require to have the same Name when on different Mount/Path combinations
 	// external groups can only have ONE alias so we need to make sure not to overwrite any
 	for _, groupAlias := range managedGroupAliases {
-		accessor, err := getVaultAuthMountAccessor(groupAlias.MountPath, v.cl)
+		name := aliasName(groupAlias.Name, groupAlias.MountPath)
+
+		accessor, err := v.authMountAccessor(ctx, groupAlias.MountPath)
 		if err != nil {
 			return errors.Wrapf(err, "error getting mount accessor for %s", groupAlias.MountPath)
 		}
 
-		id, err := getVaultGroupID(groupAlias.Group, v.cl)
+		id, err := v.groupID(ctx, groupAlias.Group)
 		if err != nil {
 			return errors.Wrapf(err, "error getting canonical_id for group %s", groupAlias.Group)
 		}
+		if id == "" {
+			return errors.Errorf("group %s does not exist", groupAlias.Group)
+		}
 
 		config := map[string]interface{}{
 			"name":           groupAlias.Name,
@@ -255,104 +251,85 @@ func (v *vault) addManagedGroupAliases(managedGroupAliases []groupAlias) error {
 		}
 
 		// Find a matching alias for NAME and MOUNT
-		ga, err := findVaultGroupAliasIDFromNameAndMount(groupAlias.Name, accessor, v.cl)
+		existingAliases, err := v.listAliases(ctx, "group")
 		if err != nil {
-			return errors.Wrapf(err, "error finding group-alias %s", groupAlias.Name)
+			return errors.Wrap(err, "error listing group-aliases")
 		}
 
-		if ga == "" {
-			slog.Info(fmt.Sprintf("adding group-alias: %s@%s", groupAlias.Name, accessor))
-			_, err = v.writeWithWarningCheck("identity/group-alias", config)
+		existing, ok := existingAliases[aliasKey(groupAlias.Name, accessor)]
+		if !ok {
+			slog.Info(fmt.Sprintf("adding group-alias: %s", name))
+			_, err = v.writeWithWarningCheck(ctx, "identity/group-alias", config)
+			v.cache.invalidateAliases("group")
 			if err != nil {
-				return errors.Wrapf(err, "failed to create group-alias %s", groupAlias.Name)
+				return errors.Wrapf(err, "failed to create group-alias %s", name)
 			}
+			v.ownership.add(ownedGroupAliases, name)
 		} else {
-			slog.Info(fmt.Sprintf("tuning already existing group-alias: %s@%s - ID: %s", groupAlias.Name, accessor, ga))
-			_, err = v.writeWithWarningCheck(fmt.Sprintf("identity/group-alias/id/%s", ga), config)
+			slog.Info(fmt.Sprintf("tuning already existing group-alias: %s - ID: %s", name, existing.id))
+			_, err = v.writeWithWarningCheck(ctx, fmt.Sprintf("identity/group-alias/id/%s", existing.id), config)
 			if err != nil {
-				return errors.Wrapf(err, "failed to tune group-alias %s", ga)
+				return errors.Wrapf(err, "failed to tune group-alias %s", name)
 			}
+			existing.canonicalID = id
+			v.cache.updateAlias("group", existing)
 		}
 	}
 
 	return nil
 }
 
-func (v *vault) getExistingGroupAliases() (map[string]string, error) {
-	existingGroupAliases := make(map[string]string)
-
-	existingGroupAliasesRaw, err := v.cl.Logical().ReadWithData(
-		"identity/group-alias/id", map[string][]string{"list": {"true"}})
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to retrieve list of group-alias")
-	}
-
-	if existingGroupAliasesRaw == nil {
-		slog.Debug("vault has no group-aliases")
-		return nil, nil
-	}
-
-	existingGroupAliasesData := cast.ToStringMap(existingGroupAliasesRaw.Data["key_info"])
-	for existingGroupAliasID, existingGroupAliasRaw := range existingGroupAliasesData {
-		existingGroupAlias := cast.ToStringMapString(existingGroupAliasRaw)
-		existingGroupAliases[existingGroupAlias["name"]] = existingGroupAliasID
-	}
-
-	return existingGroupAliases, nil
-}
-
-func getUnmanagedGroupAliases(existingGroupAliases map[string]string, managedGroupAliases []groupAlias) map[string]string {
+// getUnmanagedGroupAliases returns the IDs of the group aliases which are not in the configuration,
+// by their names and the paths of their auth methods
+func (v *vault) getUnmanagedGroupAliases(ctx context.Context, managedGroupAliases []groupAlias) (map[string]string, error) {
+	managed := map[string]bool{}
 	for _, managedGroupAlias := range managedGroupAliases {
-		delete(existingGroupAliases, managedGroupAlias.Name)
+		managed[aliasName(managedGroupAlias.Name, managedGroupAlias.MountPath)] = true
 	}
 
-	return existingGroupAliases
+	return v.getUnmanagedAliases(ctx, "group", managed)
 }
 
-func (v *vault) removeUnmanagedGroupAliases(managedGroupAliases []groupAlias) error {
+func (v *vault) removeUnmanagedGroupAliases(ctx context.Context, managedGroupAliases []groupAlias) error {
 	if !v.externalConfig.PurgeUnmanagedConfig.Enabled || v.externalConfig.PurgeUnmanagedConfig.Exclude.GroupAliases {
 		slog.Debug("purge config is disabled, no unmanaged group-alias will be removed")
 		return nil
 	}
 
-	existingGroupAliases, err := v.getExistingGroupAliases()
+	unmanagedGroupAliases, err := v.getUnmanagedGroupAliases(ctx, managedGroupAliases)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get existing group-alias from vault")
 	}
-	unmanagedGroupAliases := getUnmanagedGroupAliases(existingGroupAliases, managedGroupAliases)
 
-	slog.Info(fmt.Sprintf("removing group-aliases ... %T", unmanagedGroupAliases))
-	for unmanagedGroupAliasName, unmanagedGroupAliasID := range unmanagedGroupAliases {
-		_, err := v.cl.Logical().Delete("identity/group-alias/id/" + unmanagedGroupAliasID)
+	unmanagedGroupAliases = filterOwned(v.ownership, ownedGroupAliases, unmanagedGroupAliases)
+	for _, unmanagedGroupAliasName := range sortedKeys(unmanagedGroupAliases) {
+		unmanagedGroupAliasID := unmanagedGroupAliases[unmanagedGroupAliasName]
+
+		slog.Info(fmt.Sprintf("removing group-alias %s", unmanagedGroupAliasName))
+		_, err := v.cl.Logical().DeleteWithContext(ctx, "identity/group-alias/id/"+unmanagedGroupAliasID)
+		v.cache.invalidateAliases("group")
 		if err != nil {
 			return errors.Wrapf(err, "error removing group-alias %s with ID %s from vault",
 				unmanagedGroupAliasName, unmanagedGroupAliasID)
 		}
+		v.ownership.remove(ownedGroupAliases, unmanagedGroupAliasName)
 	}
 
 	return nil
 }
 
 //
-// Configure groups and group-aliases.
+// Purge unmanaged groups and group-aliases.
 
-func (v *vault) configureIdentityGroups() error {
+func (v *vault) purgeIdentityGroups(ctx context.Context) error {
 	managedGroups := v.externalConfig.Groups
 	managedGroupAliases := v.externalConfig.GroupAliases
 
-	if err := v.addManagedGroups(managedGroups); err != nil {
-		return errors.Wrap(err, "error while adding groups")
-	}
-
-	if err := v.addManagedGroupAliases(managedGroupAliases); err != nil {
-		return errors.Wrap(err, "error while adding groups aliases")
-	}
-
-	if err := v.removeUnmanagedGroups(managedGroups); err != nil {
+	if err := v.removeUnmanagedGroups(ctx, managedGroups); err != nil {
 		return errors.Wrap(err, "error while removing groups")
 	}
 
-	if err := v.removeUnmanagedGroupAliases(managedGroupAliases); err != nil {
+	if err := v.removeUnmanagedGroupAliases(ctx, managedGroupAliases); err != nil {
 		return errors.Wrap(err, "error while removing group aliases")
 	}
 
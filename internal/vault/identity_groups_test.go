@@ -0,0 +1,38 @@
+package vault
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddManagedGroupAliases(t *testing.T) {
+	v, writes, lists := newIdentityTestServer(t)
+
+	err := v.addManagedGroupAliases(context.Background(), []groupAlias{
+		{Name: "admins", MountPath: "userpass", Group: "admins"},
+		{Name: "developers", MountPath: "oidc", Group: "admins"},
+	})
+	require.NoError(t, err)
+
+	expected := map[string]map[string]interface{}{
+		"/v1/identity/group-alias/id/ga2": {"name": "admins", "mount_accessor": "auth_userpass_1", "canonical_id": "g1"},
+		"/v1/identity/group-alias":        {"name": "developers", "mount_accessor": "auth_oidc_1", "canonical_id": "g1"},
+	}
+	assert.Equal(t, expected, writes)
+
+	// The tuned alias is updated in the cache, so the aliases are only listed once
+	assert.Equal(t, 1, lists["/v1/identity/group-alias/id"])
+}
+
+func TestGetUnmanagedGroupAliases(t *testing.T) {
+	v, _, _ := newIdentityTestServer(t)
+
+	// The aliases with the same name on different auth methods are different aliases
+	unmanaged, err := v.getUnmanagedGroupAliases(context.Background(), []groupAlias{{Name: "admins", MountPath: "oidc/", Group: "admins"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"admins@userpass": "ga2"}, unmanaged)
+}
@@ -0,0 +1,133 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"emperror.dev/errors"
+
+	"github.com/bank-vaults/bank-vaults/pkg/kv"
+)
+
+// MigrateConfig holds the parameters of a key migration between two key stores.
+type MigrateConfig struct {
+	// SecretShares is the number of unseal and recovery keys to look for,
+	// when the source key store can't list its keys.
+	SecretShares int
+	// Force allows overwriting keys which already exist in the destination.
+	Force bool
+}
+
+// MigrateKeys copies the root token, the unseal and the recovery keys from
+// the source to the destination key store. The values are decrypted by the
+// source and re-encrypted by the destination (eg. by their KMS wrappers) and
+// they are read back from the destination to verify the copy.
+// It returns the name of the migrated keys.
+func MigrateKeys(ctx context.Context, source, destination KVService, config MigrateConfig) ([]string, error) {
+	keys, err := migrationKeys(ctx, source, config.SecretShares)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceStore := kv.WithContext(source)
+	destinationStore := kv.WithContext(destination)
+
+	values := map[string][]byte{}
+	var migratedKeys []string
+	for _, key := range keys {
+		value, err := sourceStore.GetContext(ctx, key)
+		if err != nil {
+			if isNotFoundError(err) {
+				continue
+			}
+
+			return nil, errors.Wrapf(err, "error reading key '%s' from source key store", key)
+		}
+
+		values[key] = value
+		migratedKeys = append(migratedKeys, key)
+	}
+
+	if len(migratedKeys) == 0 {
+		return nil, errors.New("no keys found in source key store")
+	}
+
+	if !config.Force {
+		var existingKeys []string
+		for _, key := range migratedKeys {
+			_, err := destinationStore.GetContext(ctx, key)
+			if err == nil {
+				existingKeys = append(existingKeys, key)
+			} else if !isNotFoundError(err) {
+				return nil, errors.Wrapf(err, "error checking key '%s' in destination key store", key)
+			}
+		}
+
+		if len(existingKeys) > 0 {
+			return nil, errors.Errorf("keys already exist in destination key store, use force to overwrite them: %s", strings.Join(existingKeys, ", "))
+		}
+	}
+
+	for _, key := range migratedKeys {
+		if err := destinationStore.SetContext(ctx, key, values[key]); err != nil {
+			return nil, errors.Wrapf(err, "error writing key '%s' to destination key store", key)
+		}
+
+		value, err := destinationStore.GetContext(ctx, key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading back key '%s' from destination key store", key)
+		}
+
+		if !bytes.Equal(value, values[key]) {
+			return nil, errors.Errorf("verification of key '%s' failed: value read back from destination key store differs", key)
+		}
+
+		slog.Info(fmt.Sprintf("key %s migrated", key))
+	}
+
+	return migratedKeys, nil
+}
+
+// migrationKeys returns the candidate keys to migrate, they are listed from the
+// source key store if it's supported, otherwise derived from the number of shares.
+func migrationKeys(ctx context.Context, source KVService, secretShares int) ([]string, error) {
+	if _, ok := source.(kv.ManagedService); ok {
+		allKeys, err := kv.List(ctx, source, "")
+		if err != nil {
+			return nil, errors.Wrap(err, "error listing keys in source key store")
+		}
+
+		var keys []string
+		for _, key := range allKeys {
+			if key == keyRootToken || strings.HasPrefix(key, keyUnsealPrefix) || strings.HasPrefix(key, keyRecoveryPrefix) {
+				keys = append(keys, key)
+			}
+		}
+
+		return keys, nil
+	}
+
+	keys := []string{keyRootToken}
+	for i := 0; i < secretShares; i++ {
+		keys = append(keys, keyUnsealForID(i), keyRecoveryForID(i))
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,55 @@
+package vault
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateKeys(t *testing.T) {
+	ctx := context.Background()
+
+	source := newMockKVService()
+	source.store[keyRootToken] = []byte("root")
+	source.store[keyUnsealForID(0)] = []byte("unseal-0")
+	source.store[keyUnsealForID(1)] = []byte("unseal-1")
+	source.store[keyTestField] = []byte("test")
+
+	config := MigrateConfig{SecretShares: 2}
+
+	t.Run("copies keys", func(t *testing.T) {
+		destination := newMockKVService()
+
+		keys, err := MigrateKeys(ctx, source, destination, config)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{keyRootToken, keyUnsealForID(0), keyUnsealForID(1)}, keys)
+		assert.Equal(t, []byte("unseal-1"), destination.store[keyUnsealForID(1)])
+		assert.NotContains(t, destination.store, keyTestField)
+	})
+
+	t.Run("refuses to overwrite", func(t *testing.T) {
+		destination := newMockKVService()
+		destination.store[keyUnsealForID(0)] = []byte("other")
+
+		_, err := MigrateKeys(ctx, source, destination, config)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), keyUnsealForID(0))
+		assert.Equal(t, []byte("other"), destination.store[keyUnsealForID(0)])
+		assert.NotContains(t, destination.store, keyRootToken)
+
+		config := config
+		config.Force = true
+
+		_, err = MigrateKeys(ctx, source, destination, config)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("unseal-0"), destination.store[keyUnsealForID(0)])
+	})
+
+	t.Run("empty source", func(t *testing.T) {
+		_, err := MigrateKeys(ctx, newMockKVService(), newMockKVService(), config)
+		assert.Error(t, err)
+	})
+}
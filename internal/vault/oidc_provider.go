@@ -0,0 +1,418 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"emperror.dev/errors"
+	"github.com/spf13/cast"
+)
+
+// oidcProviderConfig configures Vault as an OIDC provider, the resources reference each other by their names
+type oidcProviderConfig struct {
+	Keys        []oidcKey        `mapstructure:"keys"`
+	Roles       []oidcRole       `mapstructure:"roles"`
+	Assignments []oidcAssignment `mapstructure:"assignments"`
+	Scopes      []oidcScope      `mapstructure:"scopes"`
+	Clients     []oidcClient     `mapstructure:"clients"`
+	Providers   []oidcProvider   `mapstructure:"providers"`
+}
+
+type oidcKey struct {
+	Name            string `mapstructure:"name"`
+	Algorithm       string `mapstructure:"algorithm"`
+	RotationPeriod  string `mapstructure:"rotation_period"`
+	VerificationTTL string `mapstructure:"verification_ttl"`
+	// The IDs of the clients and roles allowed to use the key, or "*" for all of them. The clients reference
+	// their key, so the IDs generated by Vault can't be resolved here.
+	AllowedClientIDs []string `mapstructure:"allowed_client_ids"`
+}
+
+type oidcRole struct {
+	Name     string `mapstructure:"name"`
+	Key      string `mapstructure:"key"`
+	Template string `mapstructure:"template"`
+	ClientID string `mapstructure:"client_id"`
+	TTL      string `mapstructure:"ttl"`
+}
+
+type oidcAssignment struct {
+	Name string `mapstructure:"name"`
+	// The members of the assignment by their names
+	Entities []string `mapstructure:"entities"`
+	Groups   []string `mapstructure:"groups"`
+}
+
+type oidcScope struct {
+	Name        string `mapstructure:"name"`
+	Template    string `mapstructure:"template"`
+	Description string `mapstructure:"description"`
+}
+
+type oidcClient struct {
+	Name           string   `mapstructure:"name"`
+	Key            string   `mapstructure:"key"`
+	RedirectURIs   []string `mapstructure:"redirect_uris"`
+	Assignments    []string `mapstructure:"assignments"`
+	ClientType     string   `mapstructure:"client_type"`
+	IDTokenTTL     string   `mapstructure:"id_token_ttl"`
+	AccessTokenTTL string   `mapstructure:"access_token_ttl"`
+}
+
+type oidcProvider struct {
+	Name   string `mapstructure:"name"`
+	Issuer string `mapstructure:"issuer"`
+	// The names of the clients allowed to use the provider, or "*" for all of them
+	AllowedClients  []string `mapstructure:"allowed_clients"`
+	ScopesSupported []string `mapstructure:"scopes_supported"`
+}
+
+// The kinds of the OIDC provider resources, in the order they depend on each other
+var oidcKinds = []string{"key", "role", "assignment", "scope", "client", "provider"}
+
+// oidcBuiltins are created by Vault, they are never removed
+var oidcBuiltins = map[string]map[string]bool{
+	"key":        {"default": true},
+	"assignment": {"allow_all": true},
+	"provider":   {"default": true},
+}
+
+// oidcReference is a resource referenced by an OIDC provider resource by its name
+type oidcReference struct {
+	kind string
+	name string
+}
+
+// oidcResource is an OIDC provider resource of the configuration, written to identity/oidc/<kind>/<name>
+type oidcResource struct {
+	kind       string
+	name       string
+	references []oidcReference
+	// data returns the request data with the references resolved, the ones which don't exist yet are
+	// left as names if not strict, like in the plan
+	data func(ctx context.Context, strict bool) (map[string]interface{}, error)
+}
+
+func (r oidcResource) path() string {
+	return oidcPath(r.kind, r.name)
+}
+
+func oidcPath(kind, name string) string {
+	return fmt.Sprintf("identity/oidc/%s/%s", kind, name)
+}
+
+// oidcOwnedKind is the kind of the OIDC provider resources in the ownership record
+func oidcOwnedKind(kind string) string {
+	return "oidc-" + kind
+}
+
+// oidcData drops the unset optional fields, so Vault keeps its defaults for them, but sends the unset lists
+// as empty ones, as they are only managed by the configuration
+func oidcData(data map[string]interface{}) map[string]interface{} {
+	for key, value := range data {
+		switch value := value.(type) {
+		case string:
+			if value == "" {
+				delete(data, key)
+			}
+		case []string:
+			if value == nil {
+				data[key] = []string{}
+			}
+		}
+	}
+
+	return data
+}
+
+// oidcResources returns the OIDC provider resources of the configuration in the order of oidcKinds
+func (v *vault) oidcResources() []oidcResource {
+	config := v.externalConfig.OIDCProvider
+	if config == nil {
+		return nil
+	}
+
+	var resources []oidcResource
+
+	for _, key := range config.Keys {
+		resources = append(resources, oidcResource{
+			kind: "key",
+			name: key.Name,
+			data: func(context.Context, bool) (map[string]interface{}, error) {
+				return oidcData(map[string]interface{}{
+					"algorithm":          key.Algorithm,
+					"rotation_period":    key.RotationPeriod,
+					"verification_ttl":   key.VerificationTTL,
+					"allowed_client_ids": key.AllowedClientIDs,
+				}), nil
+			},
+		})
+	}
+
+	for _, role := range config.Roles {
+		resources = append(resources, oidcResource{
+			kind:       "role",
+			name:       role.Name,
+			references: []oidcReference{{"key", role.Key}},
+			data: func(context.Context, bool) (map[string]interface{}, error) {
+				return oidcData(map[string]interface{}{
+					"key":       role.Key,
+					"template":  role.Template,
+					"client_id": role.ClientID,
+					"ttl":       role.TTL,
+				}), nil
+			},
+		})
+	}
+
+	for _, assignment := range config.Assignments {
+		var references []oidcReference
+		for _, entity := range assignment.Entities {
+			references = append(references, oidcReference{"entity", entity})
+		}
+		for _, group := range assignment.Groups {
+			references = append(references, oidcReference{"group", group})
+		}
+
+		resources = append(resources, oidcResource{
+			kind:       "assignment",
+			name:       assignment.Name,
+			references: references,
+			data: func(ctx context.Context, strict bool) (map[string]interface{}, error) {
+				resolve := v.planIdentityIDs
+				if strict {
+					resolve = v.identityIDs
+				}
+
+				entityIDs, err := resolve(ctx, "entity", assignment.Entities)
+				if err != nil {
+					return nil, errors.Wrapf(err, "error resolving the entities of assignment %s", assignment.Name)
+				}
+
+				groupIDs, err := resolve(ctx, "group", assignment.Groups)
+				if err != nil {
+					return nil, errors.Wrapf(err, "error resolving the groups of assignment %s", assignment.Name)
+				}
+
+				return oidcData(map[string]interface{}{
+					"entity_ids": entityIDs,
+					"group_ids":  groupIDs,
+				}), nil
+			},
+		})
+	}
+
+	for _, scope := range config.Scopes {
+		resources = append(resources, oidcResource{
+			kind: "scope",
+			name: scope.Name,
+			data: func(context.Context, bool) (map[string]interface{}, error) {
+				return oidcData(map[string]interface{}{
+					"template":    scope.Template,
+					"description": scope.Description,
+				}), nil
+			},
+		})
+	}
+
+	for _, client := range config.Clients {
+		var references []oidcReference
+		if client.Key != "" {
+			references = append(references, oidcReference{"key", client.Key})
+		}
+		for _, assignment := range client.Assignments {
+			references = append(references, oidcReference{"assignment", assignment})
+		}
+
+		resources = append(resources, oidcResource{
+			kind:       "client",
+			name:       client.Name,
+			references: references,
+			data: func(context.Context, bool) (map[string]interface{}, error) {
+				return oidcData(map[string]interface{}{
+					"key":              client.Key,
+					"redirect_uris":    client.RedirectURIs,
+					"assignments":      client.Assignments,
+					"client_type":      client.ClientType,
+					"id_token_ttl":     client.IDTokenTTL,
+					"access_token_ttl": client.AccessTokenTTL,
+				}), nil
+			},
+		})
+	}
+
+	for _, provider := range config.Providers {
+		var references []oidcReference
+		for _, client := range provider.AllowedClients {
+			if client != "*" {
+				references = append(references, oidcReference{"client", client})
+			}
+		}
+		for _, scope := range provider.ScopesSupported {
+			references = append(references, oidcReference{"scope", scope})
+		}
+
+		resources = append(resources, oidcResource{
+			kind:       "provider",
+			name:       provider.Name,
+			references: references,
+			data: func(ctx context.Context, strict bool) (map[string]interface{}, error) {
+				clientIDs, err := v.oidcClientIDs(ctx, provider.AllowedClients, strict)
+				if err != nil {
+					return nil, errors.Wrapf(err, "error resolving the allowed clients of provider %s", provider.Name)
+				}
+
+				return oidcData(map[string]interface{}{
+					"issuer":             provider.Issuer,
+					"allowed_client_ids": clientIDs,
+					"scopes_supported":   provider.ScopesSupported,
+				}), nil
+			},
+		})
+	}
+
+	return resources
+}
+
+// oidcClientIDs returns the client IDs Vault generated for the clients, "*" allows all the clients
+func (v *vault) oidcClientIDs(ctx context.Context, names []string, strict bool) ([]string, error) {
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == "*" {
+			ids = append(ids, name)
+			continue
+		}
+
+		client, err := v.cl.Logical().ReadWithContext(ctx, oidcPath("client", name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read client %s", name)
+		}
+
+		switch {
+		case client != nil:
+			ids = append(ids, cast.ToString(client.Data["client_id"]))
+		case strict:
+			return nil, errors.Errorf("client %s does not exist", name)
+		default:
+			// The client is created by this configuration
+			ids = append(ids, name)
+		}
+	}
+
+	return ids, nil
+}
+
+func (v *vault) oidcResourceExists(ctx context.Context, kind, name string) (bool, error) {
+	secret, err := v.cl.Logical().ReadWithContext(ctx, oidcPath(kind, name))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read %s %s", kind, name)
+	}
+
+	return secret != nil, nil
+}
+
+func (v *vault) addOIDCResource(ctx context.Context, resource oidcResource) error {
+	data, err := resource.data(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	exists, err := v.oidcResourceExists(ctx, resource.kind, resource.name)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		slog.Info(fmt.Sprintf("tuning already existing oidc %s: %s", resource.kind, resource.name))
+	} else {
+		slog.Info(fmt.Sprintf("adding oidc %s %s", resource.kind, resource.name))
+	}
+
+	if _, err := v.writeWithWarningCheck(ctx, resource.path(), data); err != nil {
+		return errors.Wrapf(err, "failed to write oidc %s %s", resource.kind, resource.name)
+	}
+
+	if !exists {
+		v.ownership.add(oidcOwnedKind(resource.kind), resource.name)
+	}
+
+	return nil
+}
+
+// getUnmanagedOIDCResources returns the OIDC provider resources which are not in the configuration by
+// their kinds, the resources created by Vault are left out
+func (v *vault) getUnmanagedOIDCResources(ctx context.Context) (map[string]map[string]bool, error) {
+	managed := map[string]map[string]bool{}
+	for _, resource := range v.oidcResources() {
+		if managed[resource.kind] == nil {
+			managed[resource.kind] = map[string]bool{}
+		}
+		managed[resource.kind][resource.name] = true
+	}
+
+	unmanaged := map[string]map[string]bool{}
+	for _, kind := range oidcKinds {
+		names, err := v.listKeys(ctx, "identity/oidc/"+kind)
+		if err != nil {
+			return nil, err
+		}
+
+		unmanaged[kind] = map[string]bool{}
+		for _, name := range names {
+			if !managed[kind][name] && !oidcBuiltins[kind][name] {
+				unmanaged[kind][name] = true
+			}
+		}
+
+		unmanaged[kind] = filterOwned(v.ownership, oidcOwnedKind(kind), unmanaged[kind])
+	}
+
+	return unmanaged, nil
+}
+
+// purgesOIDCProvider tells whether the unmanaged OIDC provider resources are removed, which needs an
+// oidcProvider section, so the configurations which don't manage the OIDC provider leave it alone.
+func (v *vault) purgesOIDCProvider() bool {
+	return v.purges(v.externalConfig.PurgeUnmanagedConfig.Exclude.OIDCProvider) && v.externalConfig.OIDCProvider != nil
+}
+
+func (v *vault) purgeOIDCProvider(ctx context.Context) error {
+	if !v.purgesOIDCProvider() {
+		slog.Debug("purge config is disabled or there is no oidc provider config, no unmanaged oidc provider resources will be removed")
+		return nil
+	}
+
+	unmanaged, err := v.getUnmanagedOIDCResources(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get existing oidc provider resources from vault")
+	}
+
+	// The resources are removed before the ones they reference, Vault doesn't remove referenced keys
+	for i := len(oidcKinds) - 1; i >= 0; i-- {
+		kind := oidcKinds[i]
+		for _, name := range sortedKeys(unmanaged[kind]) {
+			slog.Info(fmt.Sprintf("removing oidc %s %s", kind, name))
+			if _, err := v.cl.Logical().DeleteWithContext(ctx, oidcPath(kind, name)); err != nil {
+				return errors.Wrapf(err, "error removing oidc %s %s from vault", kind, name)
+			}
+			v.ownership.remove(oidcOwnedKind(kind), name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOIDCTestVault(t *testing.T, config map[string]interface{}) (*vault, map[string]map[string]interface{}) {
+	handler, writes := recordWrites(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/identity/entity/id":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["e1"], "key_info": {"e1": {"name": "alice"}}}}`))
+		case "/v1/identity/group/id":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["g1"], "key_info": {"g1": {"name": "admins"}}}}`))
+		case "/v1/identity/oidc/client/app":
+			_, _ = w.Write([]byte(`{"data": {"client_id": "app-client-id", "key": "default"}}`))
+		case "/v1/identity/oidc/key":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["default", "legacy", "signing"]}}`))
+		case "/v1/identity/oidc/assignment":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["allow_all", "developers"]}}`))
+		case "/v1/identity/oidc/provider":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["default"]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	v := newTestVault(t, handler)
+	v.cache = &configCache{}
+
+	externalConfig, err := decodeExternalConfig(&externalConfig{}, config)
+	require.NoError(t, err)
+	v.externalConfig = externalConfig
+
+	return v, writes
+}
+
+func TestOIDCProviderGraph(t *testing.T) {
+	v, _ := newOIDCTestVault(t, map[string]interface{}{
+		"oidcProvider": map[string]interface{}{
+			"providers": []interface{}{
+				map[string]interface{}{"name": "internal", "allowed_clients": []interface{}{"portal", "*"}, "scopes_supported": []interface{}{"groups"}},
+			},
+			"clients": []interface{}{
+				map[string]interface{}{"name": "portal", "key": "signing", "assignments": []interface{}{"developers", "allow_all"}},
+			},
+			"scopes": []interface{}{
+				map[string]interface{}{"name": "groups", "template": `{"groups": {{identity.entity.groups.names}}}`},
+			},
+			"assignments": []interface{}{
+				map[string]interface{}{"name": "developers", "entities": []interface{}{"alice"}, "groups": []interface{}{"developers"}},
+			},
+			"keys": []interface{}{
+				map[string]interface{}{"name": "signing", "allowed_client_ids": []interface{}{"*"}},
+			},
+		},
+		"groups": []interface{}{
+			map[string]interface{}{"name": "developers"},
+		},
+	})
+
+	nodes, err := v.configurationGraph(context.Background()).sorted()
+	require.NoError(t, err)
+
+	expected := []string{
+		"group/developers",
+		"oidc-key/signing",
+		"oidc-assignment/developers",
+		"oidc-scope/groups",
+		"oidc-client/portal",
+		"oidc-provider/internal",
+	}
+	assert.Equal(t, expected, nodeIDs(nodes))
+}
+
+func TestAddOIDCResource(t *testing.T) {
+	v, writes := newOIDCTestVault(t, map[string]interface{}{
+		"oidcProvider": map[string]interface{}{
+			"assignments": []interface{}{
+				map[string]interface{}{"name": "admins", "entities": []interface{}{"alice"}, "groups": []interface{}{"admins"}},
+			},
+			"providers": []interface{}{
+				map[string]interface{}{"name": "internal", "allowed_clients": []interface{}{"app"}},
+				map[string]interface{}{"name": "broken", "allowed_clients": []interface{}{"missing"}},
+			},
+		},
+	})
+
+	resources := v.oidcResources()
+	require.Len(t, resources, 3)
+
+	require.NoError(t, v.addOIDCResource(context.Background(), resources[0]))
+	require.NoError(t, v.addOIDCResource(context.Background(), resources[1]))
+
+	expected := map[string]map[string]interface{}{
+		"/v1/identity/oidc/assignment/admins": {"entity_ids": []interface{}{"e1"}, "group_ids": []interface{}{"g1"}},
+		"/v1/identity/oidc/provider/internal": {"allowed_client_ids": []interface{}{"app-client-id"}, "scopes_supported": []interface{}{}},
+	}
+	assert.Equal(t, expected, writes)
+
+	err := v.addOIDCResource(context.Background(), resources[2])
+	assert.EqualError(t, err, "error resolving the allowed clients of provider broken: client missing does not exist")
+}
+
+func TestGetUnmanagedOIDCResources(t *testing.T) {
+	v, _ := newOIDCTestVault(t, map[string]interface{}{
+		"oidcProvider": map[string]interface{}{
+			"keys": []interface{}{map[string]interface{}{"name": "signing"}},
+		},
+	})
+
+	unmanaged, err := v.getUnmanagedOIDCResources(context.Background())
+	require.NoError(t, err)
+
+	// The resources created by Vault are never removed
+	assert.Equal(t, map[string]bool{"legacy": true}, unmanaged["key"])
+	assert.Equal(t, map[string]bool{"developers": true}, unmanaged["assignment"])
+	assert.Empty(t, unmanaged["provider"])
+	assert.Empty(t, unmanaged["client"])
+
+	// The OIDC provider is only purged if the configuration manages it
+	v.externalConfig.PurgeUnmanagedConfig.Enabled = true
+	assert.True(t, v.purgesOIDCProvider())
+
+	v.externalConfig.OIDCProvider = nil
+	assert.False(t, v.purgesOIDCProvider())
+	assert.NoError(t, v.purgeOIDCProvider(context.Background()))
+}
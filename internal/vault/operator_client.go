@@ -24,6 +24,7 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"emperror.dev/errors"
@@ -34,30 +35,39 @@ import (
 	"github.com/ProtonMail/go-crypto/openpgp"
 	cleanhttp "github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+
+	"github.com/bank-vaults/bank-vaults/pkg/kv"
 )
 
 const (
 	// DefaultConfigFile is the name of the default config file
 	DefaultConfigFile = "vault-config.yml"
 
-	keyRootToken = "vault-root"
-	keyTestField = "vault-test"
+	keyRootToken      = "vault-root"
+	keyTestField      = "vault-test"
+	keyUnsealPrefix   = "vault-unseal-"
+	keyRecoveryPrefix = "vault-recovery-"
 )
 
 // Vault is an interface that can be used to attempt to perform actions against
 // a Vault server.
 type Vault interface {
-	Init() error
-	RaftInitialized() (bool, error)
+	Init(ctx context.Context) error
+	RaftInitialized(ctx context.Context) (bool, error)
 	RaftJoin(leaderAddress string) error
 	Sealed() (bool, error)
 	Active() (bool, error)
-	Unseal() error
-	Rekey(pgpKeys []string) error
+	Unseal(ctx context.Context) error
+	Rekey(ctx context.Context, pgpKeys []string) error
+	RotateKeys(ctx context.Context) error
+	Rotate(ctx context.Context) error
+	LastRotation(ctx context.Context) (time.Time, error)
 	Leader() (bool, error)
 	LeaderAddress() (string, error)
-	Configure(config map[string]interface{}) error
-	NewUnsealKeysExists(pgpKeys []string) (bool, error)
+	Configure(ctx context.Context, config map[string]interface{}) error
+	Plan(ctx context.Context, config map[string]interface{}) (*Plan, error)
+	Export(ctx context.Context) (map[string]interface{}, error)
+	NewUnsealKeysExists(ctx context.Context, pgpKeys []string) (bool, error)
 }
 
 type KVService interface {
@@ -79,18 +89,49 @@ type Config struct {
 
 	// should the KV backend be tested first to validate access rights
 	PreFlightChecks bool
+
+	// PGP public keys to encrypt the unseal (or recovery) shares with at init, one per share
+	// (an armored or binary file, a k8s:[<namespace>/]<name>/<key> Secret reference or a keybase:<user>)
+	InitPGPKeys []string
+	// where to deliver the PGP encrypted shares to, one per PGP key (a file path, or a key in the keyStore prefixed with "kv:")
+	InitPGPShareDestinations []string
+
+	// should the root token be short-lived: it's never stored in the keyStore, the initial root token is revoked
+	// after init and Configure obtains a new token for every apply, which is revoked afterwards
+	EphemeralRootToken bool
+	// the key store of the dev mode only holds the root token, so no root token can be generated with the unseal keys
+	DevMode bool
+	// the auth method to obtain the token of Configure with instead of the generate-root process
+	BootstrapAuth BootstrapAuthConfig
+
+	// how many resources of the configuration are applied concurrently, defaults to 1
+	ConfigureWorkers int
+}
+
+// PlansWithoutRootGeneration tells whether the configuration can be planned without the generate-root process,
+// which is the case if the root token is stored or a bootstrap auth method is configured
+func (c Config) PlansWithoutRootGeneration() bool {
+	return (c.StoreRootToken && !c.EphemeralRootToken) || c.BootstrapAuth.Method != ""
 }
 
 type purgeUnmanagedConfig struct {
 	Enabled bool `mapstructure:"enabled"`
+	// OwnedOnly limits the purge to the resources created by the configuration, which are recorded in the
+	// key store, so the resources created by others are left intact
+	OwnedOnly bool `mapstructure:"ownedOnly"`
+	// Owner distinguishes the records of the configurations sharing the same key store
+	Owner   string `mapstructure:"owner"`
 	Exclude struct {
-		Audit        bool `mapstructure:"audit"`
-		Auth         bool `mapstructure:"auth"`
-		Groups       bool `mapstructure:"groups"`
-		GroupAliases bool `mapstructure:"group-aliases"`
-		Plugins      bool `mapstructure:"plugins"`
-		Policies     bool `mapstructure:"policies"`
-		Secrets      bool `mapstructure:"secrets"`
+		Audit         bool `mapstructure:"audit"`
+		Auth          bool `mapstructure:"auth"`
+		Entities      bool `mapstructure:"entities"`
+		EntityAliases bool `mapstructure:"entity-aliases"`
+		Groups        bool `mapstructure:"groups"`
+		GroupAliases  bool `mapstructure:"group-aliases"`
+		OIDCProvider  bool `mapstructure:"oidcProvider"`
+		Plugins       bool `mapstructure:"plugins"`
+		Policies      bool `mapstructure:"policies"`
+		Secrets       bool `mapstructure:"secrets"`
 	} `mapstructure:"exclude"`
 }
 
@@ -98,8 +139,11 @@ type externalConfig struct {
 	PurgeUnmanagedConfig purgeUnmanagedConfig `mapstructure:"purgeUnmanagedConfig"`
 	Audit                []audit              `mapstructure:"audit"`
 	Auth                 []auth               `mapstructure:"auth"`
+	Entities             []entity             `mapstructure:"entities"`
+	EntityAliases        []entityAlias        `mapstructure:"entity-aliases"`
 	Groups               []group              `mapstructure:"groups"`
 	GroupAliases         []groupAlias         `mapstructure:"group-aliases"`
+	OIDCProvider         *oidcProviderConfig  `mapstructure:"oidcProvider"`
 	Plugins              []plugin             `mapstructure:"plugins"`
 	Policies             []policy             `mapstructure:"policies"`
 	Secrets              []secretEngine       `mapstructure:"secrets"`
@@ -110,15 +154,39 @@ type kvTester struct {
 	Service KVService
 }
 
-func (t kvTester) Test(key string) error {
-	_, err := t.Service.Get(key)
+func (t kvTester) Test(ctx context.Context, key string) error {
+	// Every key store of a split key store holds some of the shares, so all of them are tested
+	if composite, ok := t.Service.(kv.CompositeService); ok {
+		for i, service := range composite.Services() {
+			if err := (kvTester{Service: service}).Test(ctx, key); err != nil {
+				return errors.Wrapf(err, "error testing key store #%d", i)
+			}
+		}
+
+		return nil
+	}
+
+	service := kv.WithContext(t.Service)
+
+	_, err := service.GetContext(ctx, key)
 	if err != nil {
 		if !isNotFoundError(err) {
 			return err //nolint:wrapcheck
 		}
 	}
 
-	return t.Service.Set(key, []byte(key))
+	if err := service.SetContext(ctx, key, []byte(key)); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	// The test key is only needed to verify write access, remove it if the store allows it
+	if _, ok := t.Service.(kv.ManagedService); ok {
+		if err := kv.Delete(ctx, t.Service, key); err != nil {
+			slog.Warn(fmt.Sprintf("failed to remove test key %q from key store: %s", key, err))
+		}
+	}
+
+	return nil
 }
 
 var _ Vault = &vault{}
@@ -131,6 +199,11 @@ type vault struct {
 	config         *Config
 	externalConfig *externalConfig
 	rotateCache    map[string]bool
+	rotateMu       sync.Mutex
+	// cache is only set while the configuration is applied
+	cache *configCache
+	// ownership is only set if the purge is limited to the owned resources
+	ownership *ownership
 }
 
 // New returns a new vault Vault, or an error.
@@ -139,6 +212,21 @@ func New(k KVService, cl *api.Client, config Config) (Vault, error) {
 		return nil, errors.Errorf("the secret threshold can't be bigger than the shares [%d < %d]", config.SecretShares, config.SecretThreshold)
 	}
 
+	// An ephemeral root token is never stored
+	if config.EphemeralRootToken {
+		config.StoreRootToken = false
+	}
+
+	// Without a bootstrap auth method the ephemeral root tokens are generated with the stored unseal keys
+	if config.EphemeralRootToken && config.BootstrapAuth.Method == "" {
+		if config.DevMode {
+			return nil, errors.New("ephemeral root tokens need a bootstrap auth method in dev mode, the dev key store only holds the root token")
+		}
+		if len(config.InitPGPKeys) > 0 {
+			return nil, errors.New("ephemeral root tokens need a bootstrap auth method with PGP encrypted shares, the unseal keys are not stored to generate a root token with")
+		}
+	}
+
 	return &vault{
 		keyStore:       k,
 		cl:             cl,
@@ -191,23 +279,53 @@ func (v *vault) LeaderAddress() (string, error) {
 	return resp.LeaderAddress, nil
 }
 
+// kvStore returns the key store of vault with context support.
+func (v *vault) kvStore() kv.ContextService {
+	return kv.WithContext(v.keyStore)
+}
+
 // Unseal will attempt to unseal vault by retrieving keys from the kms service
 // and sending unseal requests to vault. It will return an error if retrieving
 // a key fails, or if the unseal progress is reset to 0 (indicating that a key)
-// was invalid.
-func (v *vault) Unseal() error {
+// was invalid. If the keys are split across multiple key stores, the keys which
+// can't be retrieved are skipped as long as the others may meet the threshold.
+func (v *vault) Unseal(ctx context.Context) error {
 	defer runtime.GC()
+
+	_, split := v.keyStore.(kv.CompositeService)
+
+	var firstErr error
 	for i := 0; ; i++ {
 		keyID := keyUnsealForID(i)
 
 		slog.Info("retrieving key from kms service...")
-		k, err := v.keyStore.Get(keyID)
+		k, err := v.kvStore().GetContext(ctx, keyID)
 		if err != nil {
-			return errors.Wrapf(err, "unable to get key '%s'", keyID)
+			err = errors.Wrapf(err, "unable to get key '%s'", keyID)
+
+			// The shares may be split across multiple key stores, so the unreachable ones
+			// are skipped until the threshold is met or the configured shares run out.
+			if !split || ctx.Err() != nil {
+				return err
+			}
+			if i >= v.config.SecretShares {
+				if firstErr == nil {
+					return err
+				}
+
+				return errors.Wrap(firstErr, "not enough unseal keys to unseal vault")
+			}
+
+			slog.Warn(fmt.Sprintf("skipping unseal key: %s", err.Error()))
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			continue
 		}
 
 		slog.Info("sending unseal request to vault...")
-		resp, err := v.cl.Sys().Unseal(string(k))
+		resp, err := v.cl.Sys().UnsealWithContext(ctx, string(k))
 		if err != nil {
 			return errors.Wrap(err, "fail to send unseal request to vault")
 		}
@@ -225,50 +343,57 @@ func (v *vault) Unseal() error {
 	}
 }
 
-func (v *vault) NewUnsealKeysExists(pgpKeys []string) (bool, error) {
+func (v *vault) NewUnsealKeysExists(ctx context.Context, pgpKeys []string) (bool, error) {
 	if len(pgpKeys) == 0 {
 		return false, nil
 	}
-	// Check for first keybase key with format "keybase:username-vault-unseal-0"
-	slog.Info("checking if unseal key exists", slog.String("pgp_key", pgpKeys[0]))
-	notFound, checkErr := v.keyStoreNotFound(pgpKeys[0] + "-" + keyUnsealForID(0))
+	target, err := v.rekeyTarget(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Check for first PGP key with format "<name>-vault-unseal-0" or "<name>-vault-recovery-0"
+	slog.Info(fmt.Sprintf("checking if %s key exists", target.kind), slog.String("pgp_key", pgpKeys[0]))
+	notFound, checkErr := v.keyStoreNotFound(ctx, pgpKeyName(pgpKeys[0])+"-"+target.keyForID(0))
 	if checkErr != nil {
 		return false, errors.Wrap(checkErr, "error checking key existence")
 	}
 	return !notFound, nil
 }
 
-func (v *vault) Rekey(pgpKeys []string) error {
+func (v *vault) Rekey(ctx context.Context, pgpKeys []string) error {
 	defer runtime.GC()
 	if len(pgpKeys) == 0 {
 		return errors.New("no PGP keys provided for rekey operation")
 	}
 
-	// Use defer with a closure to ensure sensitive data is wiped
-	defer func() {
-		for _, key := range pgpKeys {
-			os.Remove(key)
-		}
-	}()
-
 	slog.Info("starting rekey process...")
 
-	slog.Info("checking rekey status...")
-	respStatus, err := v.cl.Sys().RekeyStatus()
+	// Validate the PGP keys before starting, so an invalid key doesn't leave a rekey operation behind
+	slog.Info("loading PGP keys...")
+	pgpKeyNames, pgpKeyValues, err := loadPGPKeys(ctx, pgpKeys, readK8SSecretKey)
 	if err != nil {
-		return errors.Wrapf(err, "unable to check rekey status")
+		return errors.Wrap(err, "unable to load PGP keys")
+	}
+	if len(pgpKeyValues) != v.config.SecretShares {
+		return errors.Errorf("the number of PGP keys (%d) must match the number of secret shares (%d)", len(pgpKeyValues), v.config.SecretShares)
+	}
+
+	target, err := v.rekeyTarget(ctx)
+	if err != nil {
+		return err
 	}
 
-	slog.Info("fetching Keybase PGP keys...")
-	keybaseKeys, err := FetchKeybasePubkeys(pgpKeys)
+	slog.Info(fmt.Sprintf("checking %s key rekey status...", target.kind))
+	respStatus, err := target.status(ctx)
 	if err != nil {
-		return errors.Wrapf(err, "unable to fetch Keybase PGP keys: %v", err)
+		return errors.Wrapf(err, "unable to check rekey status")
 	}
 
 	// Initialize rekey operation if not already started
 	var nonce string
 	if !respStatus.Started {
-		nonce, err = v.initializeRekey(keybaseKeys)
+		nonce, err = v.initializeRekey(ctx, target, pgpKeyValues)
 		if err != nil {
 			return err
 		}
@@ -278,28 +403,28 @@ func (v *vault) Rekey(pgpKeys []string) error {
 	}
 
 	// Send rekey updates until complete
-	resp, err := v.sendRekeyUpdates(nonce)
+	resp, err := v.sendRekeyUpdates(ctx, target, nonce)
 	if err != nil {
 		return err
 	}
 
 	// Store the new keys
-	return v.finishRekey(resp, pgpKeys)
+	return v.finishRekey(ctx, resp, target.keyForID, pgpKeyNames)
 }
 
-func (v *vault) initializeRekey(pgpKeys []string) (string, error) {
+func (v *vault) initializeRekey(ctx context.Context, target rekeyTarget, pgpKeys []string) (string, error) {
 	rekeyRequest := api.RekeyInitRequest{
 		SecretShares:    v.config.SecretShares,
 		SecretThreshold: v.config.SecretThreshold,
 		PGPKeys:         pgpKeys,
 	}
 
-	slog.Info("initializing rekey operation...",
+	slog.Info(fmt.Sprintf("initializing %s key rekey operation...", target.kind),
 		slog.Int("shares", v.config.SecretShares),
 		slog.Int("threshold", v.config.SecretThreshold),
 		slog.Any("pgpKeys", pgpKeys))
 
-	resp, err := v.cl.Sys().RekeyInit(&rekeyRequest)
+	resp, err := target.init(ctx, &rekeyRequest)
 	if err != nil {
 		return "", errors.Wrapf(err, "unable to start rekey init process")
 	}
@@ -312,25 +437,21 @@ func (v *vault) initializeRekey(pgpKeys []string) (string, error) {
 	return resp.Nonce, nil
 }
 
-func (v *vault) sendRekeyUpdates(nonce string) (*api.RekeyUpdateResponse, error) {
+func (v *vault) sendRekeyUpdates(ctx context.Context, target rekeyTarget, nonce string) (*api.RekeyUpdateResponse, error) {
 	// Track progress for logging
 	for i := 0; ; i++ {
-		keyID := keyUnsealForID(i)
+		keyID := target.keyForID(i)
 
 		slog.Info("retrieving key from kms service...", slog.String("key_id", keyID))
-		k, err := v.keyStore.Get(keyID)
+		k, err := v.kvStore().GetContext(ctx, keyID)
 		if err != nil {
 			return nil, errors.Wrapf(err, "unable to get key '%s'", keyID)
 		}
 
 		slog.Info("sending rekey update request to vault...")
-		resp, err := v.cl.Sys().RekeyUpdate(string(k), nonce)
+		resp, err := target.update(ctx, string(k), nonce)
 		if err != nil {
-			// Cancel rekey operation on error to avoid leaving it in an inconsistent state
-			if cerr := v.cl.Sys().RekeyCancel(); cerr != nil {
-				slog.Error("failed to cancel rekey operation after error",
-					slog.String("error", cerr.Error()))
-			}
+			target.cancelRekey()
 			return nil, errors.Wrap(err, "failed to send rekey update request to vault")
 		}
 
@@ -343,23 +464,23 @@ func (v *vault) sendRekeyUpdates(nonce string) (*api.RekeyUpdateResponse, error)
 	}
 }
 
-func (v *vault) finishRekey(resp *api.RekeyUpdateResponse, pgpKeyNames []string) error {
+func (v *vault) finishRekey(ctx context.Context, resp *api.RekeyUpdateResponse, keyForID func(int) string, pgpKeyNames []string) error {
 	slog.Info("rekey operation completed successfully", slog.Int("total_keys", len(resp.KeysB64)))
 
 	for i, k := range resp.KeysB64 {
-		keyID := pgpKeyNames[i] + "-" + keyUnsealForID(i)
-		if err := v.keyPGPSet(keyID, []byte(k)); err != nil {
-			return errors.Wrapf(err, "error storing unseal key '%s'", keyID)
+		keyID := pgpKeyNames[i] + "-" + keyForID(i)
+		if err := v.keyPGPSet(ctx, keyID, []byte(k)); err != nil {
+			return errors.Wrapf(err, "error storing rekeyed key '%s'", keyID)
 		}
 
-		slog.With(slog.String("key", keyID)).Info("unseal key stored in key store")
+		slog.With(slog.String("key", keyID)).Info("rekeyed key stored in key store")
 	}
 
 	return nil
 }
 
-func (v *vault) keyStoreNotFound(key string) (bool, error) {
-	_, err := v.keyStore.Get(key)
+func (v *vault) keyStoreNotFound(ctx context.Context, key string) (bool, error) {
+	_, err := v.kvStore().GetContext(ctx, key)
 	if isNotFoundError(err) {
 		return true, nil
 	}
@@ -367,18 +488,18 @@ func (v *vault) keyStoreNotFound(key string) (bool, error) {
 	return false, err //nolint:wrapcheck
 }
 
-func (v *vault) keyPGPSet(key string, val []byte) error {
+func (v *vault) keyPGPSet(ctx context.Context, key string, val []byte) error {
 	pgpText, err := base64.StdEncoding.DecodeString(string(val[:]))
 	if err != nil {
 		return errors.Wrap(err, "error setting data")
 	}
-	return v.keyStore.Set(key, pgpText)
+	return v.kvStore().SetContext(ctx, key, pgpText)
 }
 
-func (v *vault) keyStoreSet(key string, val []byte) error {
-	notFound, err := v.keyStoreNotFound(key)
+func (v *vault) keyStoreSet(ctx context.Context, key string, val []byte) error {
+	notFound, err := v.keyStoreNotFound(ctx, key)
 	if notFound {
-		return v.keyStore.Set(key, val)
+		return v.kvStore().SetContext(ctx, key, val)
 	}
 	if err == nil {
 		return errors.Errorf("error setting key '%s': it already exists", key)
@@ -386,9 +507,27 @@ func (v *vault) keyStoreSet(key string, val []byte) error {
 	return errors.Wrapf(err, "error setting key '%s'", key)
 }
 
+// checkKeyStores checks that every key store of a split key store is reachable,
+// the shares stored in an unreachable key store would be lost at init.
+func (v *vault) checkKeyStores(ctx context.Context) error {
+	composite, ok := v.keyStore.(kv.CompositeService)
+	if !ok {
+		return nil
+	}
+
+	for i, service := range composite.Services() {
+		_, err := kv.WithContext(service).GetContext(ctx, keyTestField)
+		if err != nil && !isNotFoundError(err) {
+			return errors.Wrapf(err, "key store #%d is unavailable", i)
+		}
+	}
+
+	return nil
+}
+
 // Init initializes Vault if is not initialized already
-func (v *vault) Init() error {
-	initialized, err := v.cl.Sys().InitStatus()
+func (v *vault) Init(ctx context.Context) error {
+	initialized, err := v.cl.Sys().InitStatusWithContext(ctx)
 	if err != nil {
 		return errors.Wrap(err, "error testing if vault is initialized")
 	}
@@ -403,10 +542,27 @@ func (v *vault) Init() error {
 	// test backend first
 	if v.config.PreFlightChecks {
 		tester := kvTester{Service: v.keyStore}
-		err = tester.Test(keyTestField)
+		err = tester.Test(ctx, keyTestField)
 		if err != nil {
 			return errors.Wrap(err, "error testing keystore before init")
 		}
+	} else if err := v.checkKeyStores(ctx); err != nil {
+		return errors.Wrap(err, "error before init")
+	}
+
+	var pgpKeyNames, pgpKeys []string
+	if len(v.config.InitPGPKeys) > 0 {
+		if len(v.config.InitPGPKeys) != v.config.SecretShares {
+			return errors.Errorf("the number of PGP keys (%d) must match the number of secret shares (%d)", len(v.config.InitPGPKeys), v.config.SecretShares)
+		}
+		if len(v.config.InitPGPShareDestinations) > 0 && len(v.config.InitPGPShareDestinations) != len(v.config.InitPGPKeys) {
+			return errors.Errorf("the number of PGP share destinations (%d) must match the number of PGP keys (%d)", len(v.config.InitPGPShareDestinations), len(v.config.InitPGPKeys))
+		}
+
+		pgpKeyNames, pgpKeys, err = loadPGPKeys(ctx, v.config.InitPGPKeys, readK8SSecretKey)
+		if err != nil {
+			return errors.Wrap(err, "error reading PGP keys")
+		}
 	}
 
 	// test for an existing keys
@@ -418,18 +574,22 @@ func (v *vault) Init() error {
 	for i := 0; i <= v.config.SecretShares; i++ {
 		keys = append(keys, keyUnsealForID(i))
 	}
+	for i, name := range pgpKeyNames {
+		keys = append(keys, name+"-"+keyUnsealForID(i), name+"-"+keyRecoveryForID(i))
+	}
 
 	// test every key
 	for _, key := range keys {
-		notFound, err := v.keyStoreNotFound(key)
-		if notFound && err != nil {
+		notFound, err := v.keyStoreNotFound(ctx, key)
+		if err != nil {
 			return errors.Wrapf(err, "error before init: checking key '%s' failed", key)
-		} else if !notFound && err == nil {
+		}
+		if !notFound {
 			return errors.Errorf("error before init: value for key '%s' already exists", key)
 		}
 	}
 
-	sealResp, err := v.cl.Sys().SealStatus()
+	sealResp, err := v.cl.Sys().SealStatusWithContext(ctx)
 	if err != nil {
 		return errors.Wrap(err, "error getting seal status")
 	}
@@ -440,35 +600,47 @@ func (v *vault) Init() error {
 	case true:
 		initRequest.RecoveryShares = v.config.SecretShares
 		initRequest.RecoveryThreshold = v.config.SecretThreshold
+		initRequest.RecoveryPGPKeys = pgpKeys
 	default:
 		initRequest.SecretShares = v.config.SecretShares
 		initRequest.SecretThreshold = v.config.SecretThreshold
+		initRequest.PGPKeys = pgpKeys
 	}
 
-	resp, err := v.cl.Sys().Init(&initRequest)
+	resp, err := v.cl.Sys().InitWithContext(ctx, &initRequest)
 
 	if err != nil {
 		return errors.Wrap(err, "error initializing vault")
 	}
 
-	for i, k := range resp.Keys {
-		keyID := keyUnsealForID(i)
-		err := v.keyStoreSet(keyID, []byte(k))
-		if err != nil {
-			return errors.Wrapf(err, "error storing unseal key '%s'", keyID)
+	if len(pgpKeyNames) > 0 {
+		// PGP encrypted shares are stored under the name of their key holders, only they can unseal
+		if err := v.storePGPShares(ctx, resp.KeysB64, keyUnsealForID, pgpKeyNames); err != nil {
+			return errors.Wrap(err, "error storing PGP encrypted unseal keys")
 		}
+		if err := v.storePGPShares(ctx, resp.RecoveryKeysB64, keyRecoveryForID, pgpKeyNames); err != nil {
+			return errors.Wrap(err, "error storing PGP encrypted recovery keys")
+		}
+	} else {
+		for i, k := range resp.Keys {
+			keyID := keyUnsealForID(i)
+			err := v.keyStoreSet(ctx, keyID, []byte(k))
+			if err != nil {
+				return errors.Wrapf(err, "error storing unseal key '%s'", keyID)
+			}
 
-		slog.With(slog.String("key", keyID)).Info("unseal key stored in key store")
-	}
-
-	for i, k := range resp.RecoveryKeys {
-		keyID := keyRecoveryForID(i)
-		err := v.keyStoreSet(keyID, []byte(k))
-		if err != nil {
-			return errors.Wrapf(err, "error storing recovery key '%s'", keyID)
+			slog.With(slog.String("key", keyID)).Info("unseal key stored in key store")
 		}
 
-		slog.With(slog.String("key", keyID)).Info("recovery key stored in key store")
+		for i, k := range resp.RecoveryKeys {
+			keyID := keyRecoveryForID(i)
+			err := v.keyStoreSet(ctx, keyID, []byte(k))
+			if err != nil {
+				return errors.Wrapf(err, "error storing recovery key '%s'", keyID)
+			}
+
+			slog.With(slog.String("key", keyID)).Info("recovery key stored in key store")
+		}
 	}
 
 	rootToken := resp.RootToken
@@ -477,26 +649,15 @@ func (v *vault) Init() error {
 	if v.config.InitRootToken != "" {
 		slog.Info("setting up init root token, waiting for vault to be unsealed")
 
-		wait := time.Second * 2
-		for {
-			sealed, err := v.Sealed()
-			if !sealed {
-				break
-			}
-			if err == nil {
-				slog.Info("vault still sealed, wait for unsealing")
-			} else {
-				slog.Info(fmt.Sprintf("vault not reachable: %s", err.Error()))
-			}
-
-			time.Sleep(wait)
+		if err := v.waitForUnseal(ctx); err != nil {
+			return errors.Wrapf(err, "unable to setup requested root token, (temporary root token: '%s')", resp.RootToken)
 		}
 
 		// use temporary token
 		v.cl.SetToken(resp.RootToken)
 
 		// setup root token with provided key
-		_, err := v.cl.Auth().Token().CreateOrphan(&api.TokenCreateRequest{
+		_, err := v.cl.Auth().Token().CreateOrphanWithContext(ctx, &api.TokenCreateRequest{
 			ID:          v.config.InitRootToken,
 			Policies:    []string{"root"},
 			DisplayName: "root-token",
@@ -507,7 +668,7 @@ func (v *vault) Init() error {
 		}
 
 		// revoke the temporary token
-		err = v.cl.Auth().Token().RevokeSelf(resp.RootToken)
+		err = v.cl.Auth().Token().RevokeSelfWithContext(ctx, resp.RootToken)
 		if err != nil {
 			return errors.Wrap(err, "unable to revoke temporary root token")
 		}
@@ -516,10 +677,14 @@ func (v *vault) Init() error {
 	}
 
 	if v.config.StoreRootToken {
-		if err = v.keyStoreSet(keyRootToken, []byte(resp.RootToken)); err != nil {
+		if err = v.keyStoreSet(ctx, keyRootToken, []byte(resp.RootToken)); err != nil {
 			return errors.Wrapf(err, "error storing root token '%s' in key'%s'", rootToken, keyRootToken)
 		}
 		slog.With(slog.String("key", keyRootToken)).Info("root token stored in key store")
+	} else if v.config.EphemeralRootToken && v.config.InitRootToken == "" {
+		if err := v.revokeInitRootToken(ctx, resp); err != nil {
+			return err
+		}
 	} else if v.config.InitRootToken == "" {
 		slog.With(slog.String("root-token", resp.RootToken)).Warn("won't store root token in key store, this token grants full privileges to vault, so keep this secret")
 	}
@@ -527,9 +692,83 @@ func (v *vault) Init() error {
 	return nil
 }
 
+// waitForUnseal waits until Vault is unsealed
+func (v *vault) waitForUnseal(ctx context.Context) error {
+	wait := time.Second * 2
+	for {
+		sealed, err := v.Sealed()
+		if !sealed {
+			return nil
+		}
+		if err == nil {
+			slog.Info("vault still sealed, wait for unsealing")
+		} else {
+			slog.Info(fmt.Sprintf("vault not reachable: %s", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck
+		case <-time.After(wait):
+		}
+	}
+}
+
+// revokeInitRootToken revokes the initial root token, so no root token outlives the init. Vault has to be
+// unsealed for that: it's unsealed with the new unseal keys, unless they are PGP encrypted or Vault is
+// auto-unsealed, in that case it waits for Vault to be unsealed.
+func (v *vault) revokeInitRootToken(ctx context.Context, resp *api.InitResponse) error {
+	if len(v.config.InitPGPKeys) == 0 && len(resp.Keys) >= v.config.SecretThreshold {
+		slog.Info("unsealing vault with the new unseal keys to revoke the initial root token")
+
+		for _, key := range resp.Keys[:v.config.SecretThreshold] {
+			if _, err := v.cl.Sys().UnsealWithContext(ctx, key); err != nil {
+				return errors.Wrap(err, "error unsealing vault to revoke the initial root token")
+			}
+		}
+	}
+
+	slog.Info("waiting for vault to be unsealed to revoke the initial root token")
+	if err := v.waitForUnseal(ctx); err != nil {
+		return errors.Wrap(err, "unable to revoke the initial root token")
+	}
+
+	v.cl.SetToken(resp.RootToken)
+	defer v.cl.SetToken("")
+
+	if err := v.cl.Auth().Token().RevokeSelfWithContext(ctx, ""); err != nil {
+		return errors.Wrap(err, "unable to revoke the initial root token")
+	}
+
+	slog.Info("initial root token revoked, root tokens are generated for every configuration")
+
+	return nil
+}
+
 // RaftInitialized in our case Vault is initialized when root key is stored in the Cloud KMS
-func (v *vault) RaftInitialized() (bool, error) {
-	rootToken, err := v.keyStore.Get(keyRootToken)
+func (v *vault) RaftInitialized(ctx context.Context) (bool, error) {
+	// The root token isn't stored, so the first unseal or recovery key shows it instead
+	if v.config.EphemeralRootToken {
+		keyIDs := []string{keyUnsealForID(0), keyRecoveryForID(0)}
+		if len(v.config.InitPGPKeys) > 0 {
+			name := pgpKeyName(v.config.InitPGPKeys[0])
+			keyIDs = []string{name + "-" + keyUnsealForID(0), name + "-" + keyRecoveryForID(0)}
+		}
+
+		for _, keyID := range keyIDs {
+			notFound, err := v.keyStoreNotFound(ctx, keyID)
+			if err != nil {
+				return false, errors.Wrapf(err, "unable to get key '%s'", keyID)
+			}
+			if !notFound {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	rootToken, err := v.kvStore().GetContext(ctx, keyRootToken)
 	if err != nil {
 		if isNotFoundError(err) {
 			return false, nil
@@ -597,99 +836,152 @@ func (v *vault) RaftJoin(leaderAPIAddr string) error {
 	return errors.New("vault hasn't joined raft cluster")
 }
 
-func (v *vault) Configure(config map[string]interface{}) error {
+// decodeExternalConfig merges the configuration into a copy of the base configuration
+func decodeExternalConfig(base *externalConfig, config map[string]interface{}) (*externalConfig, error) {
+	// Deep copy current vault externalConfig
+	var loadedConfig externalConfig
+	if err := mapstructure.Decode(base, &loadedConfig); err != nil {
+		return nil, errors.Wrap(err, "error while copying externalConfig")
+	}
+
+	// Load and merge config from input
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		// ErrorUnused is used for safety to avoid mistakes like typos in the config keys, which could lead to deletion
+		// in Vault if the purge config is enabled.
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		Result:           &loadedConfig,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating externalConfig decoder")
+	}
+	if err = decoder.Decode(config); err != nil {
+		return nil, errors.Wrap(err, "error decoding externalConfig")
+	}
+
+	return &loadedConfig, nil
+}
+
+// generateRootToken generates a new root token with the generate-root process, by using the stored
+// unseal keys, or the recovery keys if Vault is auto-unsealed.
+func (v *vault) generateRootToken(ctx context.Context) ([]byte, error) {
 	var rootToken []byte
+	var OTP string
+	var nonce string
+	var encodedRootToken string
+	var OTPLength int
 
-	slog.Info("retrieving key from kms service...")
+	slog.Info("initiating generate-root token process...")
 
-	if v.config.StoreRootToken {
-		rootToken, err := v.keyStore.Get(keyRootToken)
-		if err != nil {
-			return errors.Wrapf(err, "unable to get key '%s'", keyRootToken)
-		}
-		v.cl.SetToken(string(rootToken))
-	} else {
-		var OTP string
-		var nonce string
-		var encodedRootToken string
-		var OTPLength int
+	// Cancel any inflight root token generation that is a remnant from a previous attempt
+	err := v.cl.Sys().GenerateRootCancelWithContext(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to cancel generate root token process")
+	}
+	response, err := v.cl.Sys().GenerateRootInitWithContext(ctx, "", "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to initiate generate-root token process")
+	}
+	OTP = response.OTP
+	nonce = response.Nonce
+	OTPLength = response.OTPLength
 
-		slog.Info("initiating generate-root token process...")
+	sealResp, err := v.cl.Sys().SealStatusWithContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting seal status")
+	}
 
-		// Cancel any inflight root token generation that is a remnant from a previous attempt
-		err := v.cl.Sys().GenerateRootCancel()
-		if err != nil {
-			return errors.Wrapf(err, "unable to cancel generate root token process")
+	// Iterate over existing unseal/recovery keys
+	for i := 0; i < response.Required; i++ {
+		var keyID string
+		if sealResp.RecoverySeal {
+			keyID = keyRecoveryForID(i)
+		} else {
+			keyID = keyUnsealForID(i)
 		}
-		response, err := v.cl.Sys().GenerateRootInit("", "")
+
+		slog.Info("retrieving key from kms service...")
+		k, err := v.kvStore().GetContext(ctx, keyID)
 		if err != nil {
-			return errors.Wrapf(err, "unable to initiate generate-root token process")
+			return nil, errors.Wrapf(err, "unable to get key '%s'", keyID)
 		}
-		OTP = response.OTP
-		nonce = response.Nonce
-		OTPLength = response.OTPLength
-
-		sealResp, err := v.cl.Sys().SealStatus()
+		res, err := v.cl.Sys().GenerateRootUpdateWithContext(ctx, string(k), nonce)
 		if err != nil {
-			return errors.Wrap(err, "error getting seal status")
+			return nil, errors.Wrapf(err, "unable to update generate-root token process with key %s", keyID)
 		}
 
-		// Iterate over existing unseal/recovery keys
-		for i := 0; i < response.Required; i++ {
-			var keyID string
-			if sealResp.RecoverySeal {
-				keyID = keyRecoveryForID(i)
-			} else {
-				keyID = keyUnsealForID(i)
-			}
+		if res.Complete {
+			encodedRootToken = res.EncodedRootToken
+			switch OTPLength {
+			case 0:
+				// Backwards compat
+				tokenBytes, err := XORBase64(encodedRootToken, OTP)
+				if err != nil {
+					return nil, errors.Wrapf(err, "error xoring encoded root token")
+				}
 
-			slog.Info("retrieving key from kms service...")
-			k, err := v.keyStore.Get(keyID)
-			if err != nil {
-				return errors.Wrapf(err, "unable to get key '%s'", keyID)
-			}
-			res, err := v.cl.Sys().GenerateRootUpdate(string(k), nonce)
-			if err != nil {
-				return errors.Wrapf(err, "unable to update generate-root token process with key %s", keyID)
-			}
+				uuidToken, err := uuid.FormatUUID(tokenBytes)
+				if err != nil {
+					return nil, errors.Wrapf(err, "error formatting base64 encoded root token")
+				}
+				rootToken = []byte(strings.TrimSpace(uuidToken))
 
-			if res.Complete {
-				encodedRootToken = res.EncodedRootToken
-				switch OTPLength {
-				case 0:
-					// Backwards compat
-					tokenBytes, err := XORBase64(encodedRootToken, OTP)
-					if err != nil {
-						return errors.Wrapf(err, "error xoring encoded root token")
-					}
-
-					uuidToken, err := uuid.FormatUUID(tokenBytes)
-					if err != nil {
-						return errors.Wrapf(err, "error formatting base64 encoded root token")
-					}
-					rootToken = []byte(strings.TrimSpace(uuidToken))
-
-				default:
-					tokenBytes, err := base64.RawStdEncoding.DecodeString(encodedRootToken)
-					if err != nil {
-						return errors.Wrapf(err, "error decoding base64 encoded root token")
-					}
-
-					tokenBytes, err = XORBytes(tokenBytes, []byte(OTP))
-					if err != nil {
-						return errors.Wrapf(err, "error xoring encoded root token")
-					}
-					rootToken = tokenBytes
+			default:
+				tokenBytes, err := base64.RawStdEncoding.DecodeString(encodedRootToken)
+				if err != nil {
+					return nil, errors.Wrapf(err, "error decoding base64 encoded root token")
 				}
-				v.cl.SetToken(string(rootToken))
-				break
-			} else if res.Complete && i == (response.Required-1) {
-				err = v.cl.Sys().GenerateRootCancel()
+
+				tokenBytes, err = XORBytes(tokenBytes, []byte(OTP))
 				if err != nil {
-					return errors.Wrapf(err, "unable to cancel generate root token process")
+					return nil, errors.Wrapf(err, "error xoring encoded root token")
 				}
-				return errors.Wrapf(err, "unable to generate root token, all unseal keys were exhausted")
+				rootToken = tokenBytes
+			}
+			break
+		} else if res.Complete && i == (response.Required-1) {
+			err = v.cl.Sys().GenerateRootCancelWithContext(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to cancel generate root token process")
 			}
+			return nil, errors.Wrapf(err, "unable to generate root token, all unseal keys were exhausted")
+		}
+	}
+
+	return rootToken, nil
+}
+
+func (v *vault) Configure(ctx context.Context, config map[string]interface{}) error {
+	return v.withConfigurationToken(ctx, func() error {
+		return v.configure(ctx, config)
+	})
+}
+
+// withConfigurationToken runs the function with the token the configuration is applied with
+func (v *vault) withConfigurationToken(ctx context.Context, fn func() error) (err error) {
+	var rootToken []byte
+
+	slog.Info("retrieving key from kms service...")
+
+	if v.config.StoreRootToken && v.config.BootstrapAuth.Method == "" {
+		rootToken, err = v.kvStore().GetContext(ctx, keyRootToken)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get key '%s'", keyRootToken)
+		}
+		v.cl.SetToken(string(rootToken))
+	} else {
+		rootToken, err = v.ephemeralToken(ctx)
+		if err != nil {
+			return err
+		}
+		v.cl.SetToken(string(rootToken))
+
+		// The token is only valid for this apply, even if it fails
+		if v.config.BootstrapAuth.revocable() {
+			token := rootToken
+			defer func() {
+				err = errors.Combine(err, v.revokeEphemeralToken(token))
+			}()
 		}
 	}
 
@@ -698,63 +990,72 @@ func (v *vault) Configure(config map[string]interface{}) error {
 	defer v.cl.SetToken("")
 	defer func() { rootToken = nil }()
 
-	// Deep copy current vault externalConfig
-	var loadedConfig externalConfig
-	if err := mapstructure.Decode(v.externalConfig, &loadedConfig); err != nil {
-		return errors.Wrap(err, "error while copying externalConfig")
-	}
+	return fn()
+}
 
-	// Load and merge config from input
-	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		// ErrorUnused is used for safety to avoid mistakes like typos in the config keys, which could lead to deletion
-		// in Vault if the purge config is enabled.
-		ErrorUnused:      true,
-		WeaklyTypedInput: true,
-		Result:           &loadedConfig,
-	})
+func (v *vault) configure(ctx context.Context, config map[string]interface{}) (err error) {
+	loadedConfig, err := decodeExternalConfig(v.externalConfig, config)
 	if err != nil {
-		return errors.Wrap(err, "error creating externalConfig decoder")
-	}
-	if err = decoder.Decode(config); err != nil {
-		return errors.Wrap(err, "error decoding externalConfig")
+		return err
 	}
 
 	// Update vault externalConfig with loaded data
-	v.externalConfig = &loadedConfig
+	v.externalConfig = loadedConfig
 
-	if err = v.configureAuditDevices(); err != nil {
-		return errors.Wrap(err, "error configuring audit devices for vault")
+	if err = v.loadOwnership(ctx); err != nil {
+		return errors.Wrap(err, "error loading the resources owned by the configuration")
 	}
 
-	if err = v.configureAuthMethods(); err != nil {
-		return errors.Wrap(err, "error configuring auth methods for vault")
-	}
+	// The resources created are recorded even if the configuration failed
+	defer func() {
+		err = errors.Combine(err, errors.Wrap(v.saveOwnership(context.WithoutCancel(ctx)), "error saving the resources owned by the configuration"))
+	}()
 
-	if err = v.configureIdentityGroups(); err != nil {
-		return errors.Wrap(err, "error writing groups configurations for vault")
-	}
+	// The lists of the Vault resources are only read once during a configuration pass
+	v.cache = &configCache{}
+	defer func() { v.cache = nil }()
 
-	if err = v.configurePlugins(); err != nil {
-		return errors.Wrap(err, "error configuring plugins for vault")
+	// The resources are applied in the order of their dependencies, so the references between
+	// them are resolved in a single apply, the independent ones are applied concurrently
+	nodes, err := v.configurationGraph(ctx).sorted()
+	if err != nil {
+		return errors.Wrap(err, "error resolving the order of the configuration")
 	}
 
-	if err = v.configurePolicies(); err != nil {
-		return errors.Wrap(err, "error configuring policies for vault")
+	if err = applyNodes(ctx, nodes, v.config.ConfigureWorkers); err != nil {
+		return err
 	}
 
-	if err = v.configureSecretsEngines(); err != nil {
-		return errors.Wrap(err, "error configuring secret engines for vault")
-	}
+	// The unmanaged resources are removed once all the managed ones are in place
+	purges := []struct {
+		purge   func(ctx context.Context) error
+		message string
+	}{
+		{v.purgeAuditDevices, "error purging audit devices for vault"},
+		{v.purgeAuthMethods, "error purging auth methods for vault"},
+		{v.purgeIdentityGroups, "error purging groups for vault"},
+		{v.purgeIdentityEntities, "error purging entities for vault"},
+		{v.purgeOIDCProvider, "error purging oidc provider for vault"},
+		{v.purgePlugins, "error purging plugins for vault"},
+		{v.purgePolicies, "error purging policies for vault"},
+		{v.purgeSecretsEngines, "error purging secret engines for vault"},
+	}
+
+	for _, step := range purges {
+		if err = ctx.Err(); err != nil {
+			return errors.Wrap(err, "configuration aborted")
+		}
 
-	if err = v.configureStartupSecrets(); err != nil {
-		return errors.Wrap(err, "error writing startup secrets to vault")
+		if err = step.purge(ctx); err != nil {
+			return errors.Wrap(err, step.message)
+		}
 	}
 
-	return err
+	return nil
 }
 
-func (v *vault) writeWithWarningCheck(path string, data map[string]interface{}) (*api.Secret, error) {
-	sec, err := v.cl.Logical().Write(path, data)
+func (v *vault) writeWithWarningCheck(ctx context.Context, path string, data map[string]interface{}) (*api.Secret, error) {
+	sec, err := v.cl.Logical().WriteWithContext(ctx, path, data)
 	if err != nil {
 		return nil, err
 	}
@@ -820,11 +1121,11 @@ func XORBase64(a, b string) ([]byte, error) {
 }
 
 func keyUnsealForID(i int) string {
-	return fmt.Sprint("vault-unseal-", i)
+	return fmt.Sprint(keyUnsealPrefix, i)
 }
 
 func keyRecoveryForID(i int) string {
-	return fmt.Sprint("vault-recovery-", i)
+	return fmt.Sprint(keyRecoveryPrefix, i)
 }
 
 const (
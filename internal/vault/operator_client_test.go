@@ -1,14 +1,64 @@
 package vault
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 
+	"emperror.dev/errors"
 	"github.com/hashicorp/vault/api"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/bank-vaults/pkg/kv"
+	"github.com/bank-vaults/bank-vaults/pkg/kv/split"
 )
 
+// newTestVault returns a vault with a client of a Vault server served by the handler, the handlers report
+// their errors with t.Errorf, as the test can't be stopped from the goroutine of the server
+func newTestVault(t *testing.T, handler http.Handler) *vault {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	cl, err := api.NewClient(config)
+	require.NoError(t, err)
+	cl.SetToken("")
+
+	return &vault{cl: cl, config: &Config{}, externalConfig: &externalConfig{}}
+}
+
+// recordWrites records the data written to Vault by their paths and serves the other requests with the handler,
+// the writes can be made concurrently by the configuration workers
+func recordWrites(t *testing.T, handler http.Handler) (http.Handler, map[string]map[string]interface{}) {
+	var mu sync.Mutex
+	writes := map[string]map[string]interface{}{}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut && r.Method != http.MethodPost {
+			handler.ServeHTTP(w, r)
+
+			return
+		}
+
+		var data map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			t.Errorf("invalid request body: %s", err)
+		}
+
+		mu.Lock()
+		writes[r.URL.Path] = data
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	}), writes
+}
+
 // mockKVService implements KVService interface for testing
 type mockKVService struct {
 	store map[string][]byte
@@ -66,7 +116,7 @@ func TestFinishRekey(t *testing.T) {
 	pgpKeys := []string{"test-user"}
 
 	// Test finishRekey
-	err := v.finishRekey(resp, pgpKeys)
+	err := v.finishRekey(context.Background(), resp, keyUnsealForID, pgpKeys)
 	require.NoError(t, err)
 
 	// Verify the key was stored
@@ -94,7 +144,7 @@ func TestKeyPGPSet(t *testing.T) {
 	encodedValue := base64.StdEncoding.EncodeToString(testValue)
 
 	// Test keyPGPSet
-	err := v.keyPGPSet("test-key", []byte(encodedValue))
+	err := v.keyPGPSet(context.Background(), "test-key", []byte(encodedValue))
 	require.NoError(t, err)
 
 	// Verify the stored value matches the original value
@@ -102,3 +152,131 @@ func TestKeyPGPSet(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, testValue, storedValue)
 }
+
+// unavailableMockKVService fails every request, like an unreachable key store
+type unavailableMockKVService struct{}
+
+func (unavailableMockKVService) Set(string, []byte) error {
+	return errors.New("connection refused")
+}
+
+func (unavailableMockKVService) Get(string) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestInitUnavailableKeyStore(t *testing.T) {
+	v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/sys/init" {
+			_, _ = w.Write([]byte(`{"initialized": false}`))
+
+			return
+		}
+
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+	}))
+
+	splitStore, err := split.New([]kv.Service{newMockKVService(), unavailableMockKVService{}}, map[int]int{0: 0, 1: 0})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		keyStore        KVService
+		preFlightChecks bool
+		err             string
+	}{
+		"unavailable key store": {
+			keyStore: unavailableMockKVService{},
+			err:      "error before init: checking key 'vault-root' failed: connection refused",
+		},
+		"unavailable split key store": {
+			keyStore: splitStore,
+			err:      "error before init: key store #1 is unavailable: connection refused",
+		},
+		"unavailable split key store with preflight checks": {
+			keyStore:        splitStore,
+			preFlightChecks: true,
+			err:             "error testing keystore before init: error testing key store #1: connection refused",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v.keyStore = test.keyStore
+			v.config = &Config{SecretShares: 2, SecretThreshold: 2, PreFlightChecks: test.preFlightChecks}
+
+			// Vault isn't initialized if a key store is unavailable, even if its shares are mapped elsewhere
+			assert.EqualError(t, v.Init(context.Background()), test.err)
+		})
+	}
+}
+
+func TestUnsealSplitKeyStore(t *testing.T) {
+	var unsealed []string
+	v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/v1/sys/unseal" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+
+			return
+		}
+
+		var body struct{ Key string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid request body: %s", err)
+		}
+		unsealed = append(unsealed, body.Key)
+
+		_ = json.NewEncoder(w).Encode(api.SealStatusResponse{Sealed: len(unsealed) < 2, Progress: len(unsealed) % 2})
+	}))
+
+	available := newMockKVService()
+	available.store[keyUnsealForID(0)] = []byte("key-0")
+
+	// The second share is in an unavailable key store
+	splitStore, err := split.New([]kv.Service{available, unavailableMockKVService{}}, map[int]int{0: 0, 1: 1, 2: 0})
+	require.NoError(t, err)
+
+	v.keyStore = splitStore
+	v.config = &Config{SecretShares: 3, SecretThreshold: 2}
+
+	err = v.Unseal(context.Background())
+	assert.EqualError(t, err, "not enough unseal keys to unseal vault: unable to get key 'vault-unseal-1': connection refused")
+
+	unsealed = nil
+	available.store[keyUnsealForID(2)] = []byte("key-2")
+
+	require.NoError(t, v.Unseal(context.Background()))
+	assert.Equal(t, []string{"key-0", "key-2"}, unsealed)
+
+	// The keys are only skipped if they are split across multiple key stores
+	unsealed = nil
+	v.keyStore = available
+	delete(available.store, keyUnsealForID(0))
+
+	err = v.Unseal(context.Background())
+	assert.EqualError(t, err, "unable to get key 'vault-unseal-0': key not found")
+	assert.Empty(t, unsealed)
+}
+
+func TestNewEphemeralRootToken(t *testing.T) {
+	config := Config{SecretShares: 1, SecretThreshold: 1, StoreRootToken: true, EphemeralRootToken: true}
+
+	v, err := New(newMockKVService(), nil, config)
+	require.NoError(t, err)
+
+	// An ephemeral root token is never stored
+	assert.False(t, v.(*vault).config.StoreRootToken)
+
+	devConfig := config
+	devConfig.DevMode = true
+	_, err = New(newMockKVService(), nil, devConfig)
+	assert.EqualError(t, err, "ephemeral root tokens need a bootstrap auth method in dev mode, the dev key store only holds the root token")
+
+	pgpConfig := config
+	pgpConfig.InitPGPKeys = []string{"keybase:alice"}
+	_, err = New(newMockKVService(), nil, pgpConfig)
+	assert.EqualError(t, err, "ephemeral root tokens need a bootstrap auth method with PGP encrypted shares, the unseal keys are not stored to generate a root token with")
+
+	// The tokens are obtained with the bootstrap auth method instead of the unseal keys
+	pgpConfig.BootstrapAuth.Method = "approle"
+	_, err = New(newMockKVService(), nil, pgpConfig)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,183 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"emperror.dev/errors"
+)
+
+// keyOwnershipPrefix prefixes the key of the record of the resources created by the configuration,
+// which is followed by the owner of the configuration
+const keyOwnershipPrefix = "vault-config-owned"
+
+// The kinds of the resources with ownership, the same as the keys of purgeUnmanagedConfig.exclude,
+// except for the OIDC provider resources, which have a kind per type, see oidcOwnedKind
+const (
+	ownedAudit         = "audit"
+	ownedAuth          = "auth"
+	ownedEntities      = "entities"
+	ownedEntityAliases = "entity-aliases"
+	ownedGroups        = "groups"
+	ownedGroupAliases  = "group-aliases"
+	ownedPlugins       = "plugins"
+	ownedPolicies      = "policies"
+	ownedSecrets       = "secrets"
+)
+
+// ownership holds the resources created by the configuration by their kinds, when the purge is limited to the
+// owned resources, only these are removed from Vault once they are removed from the configuration. The methods
+// are no-ops on a nil ownership, which means the ownership is not tracked.
+type ownership struct {
+	mu        sync.Mutex
+	resources map[string]map[string]bool
+	changed   bool
+}
+
+func (o *ownership) add(kind, name string) {
+	if o == nil {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.resources[kind] == nil {
+		o.resources[kind] = map[string]bool{}
+	}
+	if !o.resources[kind][name] {
+		o.resources[kind][name] = true
+		o.changed = true
+	}
+}
+
+func (o *ownership) remove(kind, name string) {
+	if o == nil {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.resources[kind][name] {
+		delete(o.resources[kind], name)
+		o.changed = true
+	}
+}
+
+func (o *ownership) owns(kind, name string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.resources[kind][name]
+}
+
+// filterOwned drops the resources not created by the configuration from the unmanaged resources,
+// if the ownership is tracked
+func filterOwned[T any](o *ownership, kind string, unmanaged map[string]T) map[string]T {
+	if o == nil {
+		return unmanaged
+	}
+
+	for name := range unmanaged {
+		if !o.owns(kind, name) {
+			slog.Debug(fmt.Sprintf("%s %s is not owned by the configuration, it won't be removed", kind, name))
+			delete(unmanaged, name)
+		}
+	}
+
+	return unmanaged
+}
+
+func (v *vault) ownershipKey() string {
+	if owner := v.externalConfig.PurgeUnmanagedConfig.Owner; owner != "" {
+		return keyOwnershipPrefix + "-" + owner
+	}
+
+	return keyOwnershipPrefix
+}
+
+// loadOwnership loads the resources created by the configuration from the key store, if the purge is
+// limited to the owned resources
+func (v *vault) loadOwnership(ctx context.Context) error {
+	v.ownership = nil
+
+	if !v.externalConfig.PurgeUnmanagedConfig.OwnedOnly {
+		if v.externalConfig.PurgeUnmanagedConfig.Enabled {
+			slog.Warn("purgeUnmanagedConfig is enabled without ownedOnly, the resources created by others which are not in the configuration are removed as well")
+		}
+
+		return nil
+	}
+
+	owned := &ownership{resources: map[string]map[string]bool{}}
+
+	key := v.ownershipKey()
+	data, err := v.kvStore().GetContext(ctx, key)
+	if err != nil && !isNotFoundError(err) {
+		return errors.Wrapf(err, "unable to get key '%s'", key)
+	}
+
+	if err == nil {
+		var resources map[string][]string
+		if err := json.Unmarshal(data, &resources); err != nil {
+			return errors.Wrapf(err, "invalid ownership record in key '%s'", key)
+		}
+
+		for kind, names := range resources {
+			owned.resources[kind] = make(map[string]bool, len(names))
+			for _, name := range names {
+				owned.resources[kind][name] = true
+			}
+		}
+	}
+
+	v.ownership = owned
+
+	return nil
+}
+
+// saveOwnership stores the resources created by the configuration in the key store, if they changed
+func (v *vault) saveOwnership(ctx context.Context) error {
+	if v.ownership == nil || !v.ownership.changed {
+		return nil
+	}
+
+	resources := map[string][]string{}
+	for kind, names := range v.ownership.resources {
+		if len(names) > 0 {
+			resources[kind] = sortedKeys(names)
+		}
+	}
+
+	data, err := json.Marshal(resources)
+	if err != nil {
+		return errors.Wrap(err, "error encoding ownership record")
+	}
+
+	key := v.ownershipKey()
+	if err := v.kvStore().SetContext(ctx, key, data); err != nil {
+		return errors.Wrapf(err, "unable to set key '%s'", key)
+	}
+
+	v.ownership.changed = false
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package vault
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnership(t *testing.T) {
+	mockStore := newMockKVService()
+
+	config := &externalConfig{}
+	config.PurgeUnmanagedConfig.OwnedOnly = true
+	config.PurgeUnmanagedConfig.Owner = "team-a"
+
+	v := &vault{keyStore: mockStore, externalConfig: config}
+
+	// Nothing is owned before the first configuration
+	require.NoError(t, v.loadOwnership(context.Background()))
+	require.NotNil(t, v.ownership)
+
+	v.ownership.add(ownedPolicies, "reader")
+	v.ownership.add(ownedPolicies, "writer")
+	v.ownership.add(ownedSecrets, "kv")
+	v.ownership.remove(ownedSecrets, "kv")
+
+	require.NoError(t, v.saveOwnership(context.Background()))
+	assert.JSONEq(t, `{"policies": ["reader", "writer"]}`, string(mockStore.store["vault-config-owned-team-a"]))
+
+	require.NoError(t, v.loadOwnership(context.Background()))
+
+	unmanaged := map[string]bool{"reader": true, "admin": true}
+	assert.Equal(t, map[string]bool{"reader": true}, filterOwned(v.ownership, ownedPolicies, unmanaged))
+}
+
+func TestOwnershipDisabled(t *testing.T) {
+	v := &vault{keyStore: newMockKVService(), externalConfig: &externalConfig{}}
+
+	require.NoError(t, v.loadOwnership(context.Background()))
+	assert.Nil(t, v.ownership)
+
+	// Everything unmanaged is removed without ownership
+	unmanaged := map[string]bool{"reader": true, "admin": true}
+	assert.Equal(t, map[string]bool{"reader": true, "admin": true}, filterOwned(v.ownership, ownedPolicies, unmanaged))
+
+	v.ownership.add(ownedPolicies, "reader")
+	require.NoError(t, v.saveOwnership(context.Background()))
+}
@@ -0,0 +1,230 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	corev1 "k8s.io/api/core/v1"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+	crconfig "sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const (
+	// kvDestinationPrefix marks a PGP share destination as a key in the key store instead of a file path
+	kvDestinationPrefix = "kv:"
+
+	// k8sPGPKeyPrefix marks a PGP public key as a Kubernetes Secret reference instead of a file path
+	k8sPGPKeyPrefix = "k8s:"
+)
+
+// secretReader reads the value of a key in a Kubernetes Secret
+type secretReader func(ctx context.Context, namespace, name, key string) ([]byte, error)
+
+// parsePGPKey validates an armored or binary PGP public key, which has to be able to encrypt,
+// and returns it base64-encoded as the Vault API expects it.
+func parsePGPKey(name string, data []byte) (string, error) {
+	var entityList openpgp.EntityList
+	var err error
+	if bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		entityList, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		entityList, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "error parsing PGP key %q", name)
+	}
+	if len(entityList) != 1 || entityList[0] == nil {
+		return "", errors.Errorf("PGP key %q must contain exactly one public key, found %d", name, len(entityList))
+	}
+
+	entity := entityList[0]
+	if _, ok := entity.EncryptionKey(time.Now()); !ok {
+		return "", errors.Errorf("PGP key %q has no valid encryption key, it might be expired or revoked", name)
+	}
+
+	serializedEntity := bytes.NewBuffer(nil)
+	if err := entity.Serialize(serializedEntity); err != nil {
+		return "", errors.Wrapf(err, "error serializing PGP key %q", name)
+	}
+
+	return base64.StdEncoding.EncodeToString(serializedEntity.Bytes()), nil
+}
+
+// pgpKeyName returns the stable name of a PGP public key, which prefixes the keys encrypted with it
+// in the key store: the Keybase user (keybase:<user>) as is, the Secret key (k8s:...) or the file
+// name without extension.
+func pgpKeyName(spec string) string {
+	if strings.HasPrefix(spec, kbPrefix) {
+		return spec
+	}
+
+	name := filepath.Base(strings.TrimPrefix(spec, k8sPGPKeyPrefix))
+
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// parseK8SPGPKeyRef parses a Kubernetes Secret reference in the k8s:[<namespace>/]<name>/<key> format,
+// the namespace defaults to the namespace of bank-vaults.
+func parseK8SPGPKeyRef(spec string) (string, string, string, error) {
+	parts := strings.Split(strings.TrimPrefix(spec, k8sPGPKeyPrefix), "/")
+	switch len(parts) {
+	case 2:
+		return os.Getenv("NAMESPACE"), parts[0], parts[1], nil
+	case 3:
+		return parts[0], parts[1], parts[2], nil
+	default:
+		return "", "", "", errors.Errorf("invalid Kubernetes Secret reference %q, the format is k8s:[<namespace>/]<name>/<key>", spec)
+	}
+}
+
+// loadPGPKeys loads and validates PGP public keys given as local armored or binary files,
+// Kubernetes Secret references (k8s:[<namespace>/]<name>/<key>) or Keybase users (keybase:<user>).
+// It returns the stable names and the base64-encoded keys in the order of the specs.
+func loadPGPKeys(ctx context.Context, specs []string, readSecret secretReader) ([]string, []string, error) {
+	names := make([]string, len(specs))
+	keys := make([]string, len(specs))
+
+	seen := map[string]bool{}
+	var keybaseUsers []string
+	var keybaseIndexes []int
+
+	for i, spec := range specs {
+		names[i] = pgpKeyName(spec)
+		if seen[names[i]] {
+			return nil, nil, errors.Errorf("PGP key name %q is not unique, the encrypted keys would overwrite each other", names[i])
+		}
+		seen[names[i]] = true
+
+		var data []byte
+		var err error
+
+		switch {
+		case strings.HasPrefix(spec, kbPrefix):
+			keybaseUsers = append(keybaseUsers, spec)
+			keybaseIndexes = append(keybaseIndexes, i)
+
+			continue
+		case strings.HasPrefix(spec, k8sPGPKeyPrefix):
+			namespace, name, key, perr := parseK8SPGPKeyRef(spec)
+			if perr != nil {
+				return nil, nil, perr
+			}
+
+			data, err = readSecret(ctx, namespace, name, key)
+		default:
+			data, err = os.ReadFile(spec)
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "error reading PGP key %s", spec)
+		}
+
+		keys[i], err = parsePGPKey(names[i], data)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(keybaseUsers) > 0 {
+		slog.Info("fetching Keybase PGP keys...")
+		keybaseKeys, err := FetchKeybasePubkeys(keybaseUsers)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to fetch Keybase PGP keys")
+		}
+
+		for i, index := range keybaseIndexes {
+			keys[index] = keybaseKeys[i]
+		}
+	}
+
+	return names, keys, nil
+}
+
+// readK8SSecretKey reads the value of a key in a Kubernetes Secret
+func readK8SSecretKey(ctx context.Context, namespace, name, key string) ([]byte, error) {
+	k8sCfg, err := crconfig.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating k8s config")
+	}
+
+	c, err := crclient.New(k8sCfg, crclient.Options{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating k8s client")
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, crclient.ObjectKey{Namespace: namespace, Name: name}, secret); err != nil {
+		return nil, errors.Wrapf(err, "error getting secret %s/%s", namespace, name)
+	}
+
+	value, ok := secret.Data[key]
+	if !ok {
+		return nil, errors.Errorf("key %q not found in secret %s/%s", key, namespace, name)
+	}
+
+	return value, nil
+}
+
+// storePGPShares stores the base64-encoded PGP encrypted shares in the key store under the
+// name of their key holders and delivers them to their destinations if configured.
+func (v *vault) storePGPShares(ctx context.Context, sharesB64 []string, keyForID func(int) string, pgpKeyNames []string) error {
+	for i, shareB64 := range sharesB64 {
+		share, err := base64.StdEncoding.DecodeString(shareB64)
+		if err != nil {
+			return errors.Wrap(err, "error decoding share")
+		}
+
+		keyID := pgpKeyNames[i] + "-" + keyForID(i)
+		if err := v.keyStoreSet(ctx, keyID, share); err != nil {
+			return errors.Wrapf(err, "error storing share '%s'", keyID)
+		}
+
+		slog.With(slog.String("key", keyID)).Info("PGP encrypted share stored in key store")
+
+		if len(v.config.InitPGPShareDestinations) > 0 {
+			destination := v.config.InitPGPShareDestinations[i]
+			if err := v.deliverPGPShare(ctx, destination, share); err != nil {
+				return errors.Wrapf(err, "error delivering share '%s'", keyID)
+			}
+
+			slog.With(slog.String("key", keyID), slog.String("destination", destination)).Info("PGP encrypted share delivered")
+		}
+	}
+
+	return nil
+}
+
+// deliverPGPShare delivers an encrypted share to its key holder, the destination is either
+// a file path or a key in the key store prefixed with "kv:".
+func (v *vault) deliverPGPShare(ctx context.Context, destination string, share []byte) error {
+	if key, ok := strings.CutPrefix(destination, kvDestinationPrefix); ok {
+		return v.keyStoreSet(ctx, key, share)
+	}
+
+	if err := os.WriteFile(destination, share, 0o600); err != nil {
+		return errors.Wrapf(err, "error writing share to %s", destination)
+	}
+
+	return nil
+}
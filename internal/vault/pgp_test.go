@@ -0,0 +1,103 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"emperror.dev/errors"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeArmoredPGPKey(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	require.NoError(t, err)
+
+	buf := bytes.NewBuffer(nil)
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	path := filepath.Join(dir, name+".asc")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	return path
+}
+
+func TestLoadPGPKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	// Binary key from a Kubernetes Secret
+	entity, err := openpgp.NewEntity("carol", "", "carol@example.com", nil)
+	require.NoError(t, err)
+	binaryKey := bytes.NewBuffer(nil)
+	require.NoError(t, entity.Serialize(binaryKey))
+
+	readSecret := func(_ context.Context, namespace, name, key string) ([]byte, error) {
+		if namespace == "vault" && name == "pgp-keys" && key == "carol.gpg" {
+			return binaryKey.Bytes(), nil
+		}
+
+		return nil, errors.New("secret not found")
+	}
+
+	names, keys, err := loadPGPKeys(context.Background(), []string{
+		writeArmoredPGPKey(t, dir, "alice"),
+		writeArmoredPGPKey(t, dir, "bob"),
+		"k8s:vault/pgp-keys/carol.gpg",
+	}, readSecret)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob", "carol"}, names)
+	assert.Len(t, keys, 3)
+
+	_, err = base64.StdEncoding.DecodeString(keys[2])
+	assert.NoError(t, err)
+
+	invalid := filepath.Join(dir, "invalid.asc")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a key"), 0o600))
+
+	_, _, err = loadPGPKeys(context.Background(), []string{invalid}, readSecret)
+	assert.Error(t, err)
+
+	_, _, err = loadPGPKeys(context.Background(), []string{"k8s:vault/pgp-keys/missing.asc"}, readSecret)
+	assert.Error(t, err)
+
+	_, _, err = loadPGPKeys(context.Background(), []string{filepath.Join(dir, "alice.asc"), "k8s:vault/pgp-keys/alice.asc"}, readSecret)
+	assert.Error(t, err, "names must be unique")
+}
+
+func TestStorePGPShares(t *testing.T) {
+	dir := t.TempDir()
+	store := newMockKVService()
+
+	v := &vault{
+		keyStore: store,
+		config: &Config{
+			InitPGPShareDestinations: []string{filepath.Join(dir, "alice.gpg"), "kv:bob-escrow"},
+		},
+	}
+
+	shares := []string{
+		base64.StdEncoding.EncodeToString([]byte("share-0")),
+		base64.StdEncoding.EncodeToString([]byte("share-1")),
+	}
+
+	require.NoError(t, v.storePGPShares(context.Background(), shares, keyUnsealForID, []string{"alice", "bob"}))
+
+	assert.Equal(t, []byte("share-0"), store.store["alice-vault-unseal-0"])
+	assert.Equal(t, []byte("share-1"), store.store["bob-vault-unseal-1"])
+	assert.Equal(t, []byte("share-1"), store.store["bob-escrow"])
+
+	delivered, err := os.ReadFile(filepath.Join(dir, "alice.gpg"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("share-0"), delivered)
+}
@@ -0,0 +1,965 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+)
+
+// PlanAction is the kind of change Configure would make
+type PlanAction string
+
+const (
+	PlanCreate PlanAction = "create"
+	PlanUpdate PlanAction = "update"
+	PlanDelete PlanAction = "delete"
+)
+
+// PlanChange is a single change Configure would make in Vault
+type PlanChange struct {
+	Action PlanAction `json:"action"`
+	// Kind is the kind of the changed object, eg. auth, auth-role, policy or secrets-engine
+	Kind string `json:"kind"`
+	// Name identifies the object, it's usually its path
+	Name string `json:"name"`
+	// Fields are the changed fields of an updated object
+	Fields []string `json:"fields,omitempty"`
+	// Reason explains a change which can't be determined exactly
+	Reason string `json:"reason,omitempty"`
+}
+
+// Plan holds the changes Configure would make in Vault to apply a configuration
+type Plan struct {
+	Changes []PlanChange `json:"changes"`
+}
+
+func (p *Plan) add(action PlanAction, kind, name string, fields ...string) {
+	p.Changes = append(p.Changes, PlanChange{Action: action, Kind: kind, Name: name, Fields: fields})
+}
+
+// addUnknown adds an update whose necessity can't be determined from the current state
+func (p *Plan) addUnknown(kind, name, reason string) {
+	p.Changes = append(p.Changes, PlanChange{Action: PlanUpdate, Kind: kind, Name: name, Reason: reason})
+}
+
+// Count returns the number of changes with the given action
+func (p *Plan) Count(action PlanAction) int {
+	count := 0
+	for _, change := range p.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+
+	return count
+}
+
+// String renders the plan in a human readable format
+func (p *Plan) String() string {
+	symbols := map[PlanAction]string{PlanCreate: "+", PlanUpdate: "~", PlanDelete: "-"}
+
+	var text strings.Builder
+	for _, change := range p.Changes {
+		fmt.Fprintf(&text, "%s %s %s %s", symbols[change.Action], change.Action, change.Kind, change.Name)
+		if len(change.Fields) > 0 {
+			fmt.Fprintf(&text, " (%s)", strings.Join(change.Fields, ", "))
+		}
+		if change.Reason != "" {
+			fmt.Fprintf(&text, " (%s)", change.Reason)
+		}
+		text.WriteString("\n")
+	}
+
+	fmt.Fprintf(&text, "Plan: %d to create, %d to update, %d to delete.\n", p.Count(PlanCreate), p.Count(PlanUpdate), p.Count(PlanDelete))
+
+	return text.String()
+}
+
+// Plan reads the current state of Vault and returns the changes Configure would make to apply
+// the configuration, without writing anything to Vault. It needs a stored root token or a bootstrap
+// auth method to read the state of Vault with.
+func (v *vault) Plan(ctx context.Context, config map[string]interface{}) (*Plan, error) {
+	// Reading the state of Vault must not use the unseal keys to generate a root token
+	if !v.config.PlansWithoutRootGeneration() {
+		return nil, errors.New("planning needs a stored root token or a bootstrap auth method, the generate-root process is never run to plan")
+	}
+
+	plan := &Plan{}
+
+	err := v.withConfigurationToken(ctx, func() error {
+		loadedConfig, err := decodeExternalConfig(v.externalConfig, config)
+		if err != nil {
+			return err
+		}
+
+		// The configurations are merged, just like in Configure
+		v.externalConfig = loadedConfig
+
+		// Only the owned resources would be removed
+		if err := v.loadOwnership(ctx); err != nil {
+			return errors.Wrap(err, "error loading the resources owned by the configuration")
+		}
+
+		steps := []struct {
+			plan    func(ctx context.Context, plan *Plan) error
+			message string
+		}{
+			{v.planAuditDevices, "error planning audit devices"},
+			{v.planAuthMethods, "error planning auth methods"},
+			{v.planIdentityGroups, "error planning groups"},
+			{v.planIdentityEntities, "error planning entities"},
+			{v.planOIDCProvider, "error planning oidc provider"},
+			{v.planPlugins, "error planning plugins"},
+			{v.planPolicies, "error planning policies"},
+			{v.planSecretsEngines, "error planning secret engines"},
+			{v.planStartupSecrets, "error planning startup secrets"},
+		}
+
+		for _, step := range steps {
+			if err := ctx.Err(); err != nil {
+				return errors.Wrap(err, "planning aborted")
+			}
+
+			if err := step.plan(ctx, plan); err != nil {
+				return errors.Wrap(err, step.message)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func (v *vault) purges(excluded bool) bool {
+	return v.externalConfig.PurgeUnmanagedConfig.Enabled && !excluded
+}
+
+func (v *vault) planAuditDevices(ctx context.Context, plan *Plan) error {
+	managedAudits := initAuditConfig(v.externalConfig.Audit)
+
+	existingAudits, err := v.getExistingAudits()
+	if err != nil {
+		return err
+	}
+
+	for _, audit := range managedAudits {
+		// Existing audit devices are never updated
+		if !existingAudits[audit.Path] {
+			plan.add(PlanCreate, "audit", audit.Path)
+		}
+	}
+
+	if v.purges(v.externalConfig.PurgeUnmanagedConfig.Exclude.Audit) {
+		for _, path := range sortedKeys(v.getUnmanagedAudits(managedAudits)) {
+			plan.add(PlanDelete, "audit", path)
+		}
+	}
+
+	return nil
+}
+
+// authRoleSubPaths holds the path of the roles below the auth method of the types which have roles
+var authRoleSubPaths = map[string]string{
+	"kubernetes": "role", "aws": "role", "gcp": "role", "oci": "role", "approle": "role",
+	"jwt": "role", "oidc": "role", "azure": "role", "token": "roles", "cert": "certs",
+}
+
+func (v *vault) planAuthMethods(ctx context.Context, plan *Plan) error {
+	managedAuths := initAuthConfig(v.externalConfig.Auth)
+
+	existingAuths, err := v.getExistingAuthMethods(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, authMethod := range managedAuths {
+		existing := existingAuths[authMethod.Path]
+		if existing == nil {
+			plan.add(PlanCreate, "auth", authMethod.Path)
+		} else if authMethod.Options != nil {
+			fields, err := diffMountConfig(authMethod.Options, existing.Config)
+			if err != nil {
+				return errors.Wrapf(err, "error comparing auth method %s options", authMethod.Path)
+			}
+			if len(fields) > 0 {
+				plan.add(PlanUpdate, "auth", authMethod.Path, fields...)
+			}
+		}
+
+		writes, err := authWrites(authMethod)
+		if err != nil {
+			return err
+		}
+
+		for _, write := range writes {
+			switch {
+			case existing == nil:
+				plan.add(PlanCreate, write.kind, write.path)
+			case write.reason != "":
+				plan.addUnknown(write.kind, write.path, write.reason)
+			default:
+				if err := v.planWrite(ctx, plan, write.kind, write.path, write.data); err != nil {
+					return err
+				}
+			}
+		}
+
+		roleSubPath, ok := authRoleSubPaths[authMethod.Type]
+		if !ok {
+			continue
+		}
+
+		path := authMethod.Path
+		if authMethod.Type == "token" {
+			path = "token"
+		}
+
+		for _, roleRaw := range authMethod.Roles {
+			role, err := cast.ToStringMapE(roleRaw)
+			if err != nil {
+				return errors.Wrapf(err, "error converting roles for %s", authMethod.Type)
+			}
+
+			rolePath := fmt.Sprintf("auth/%s/%s/%s", path, roleSubPath, role["name"])
+			if existing == nil {
+				plan.add(PlanCreate, "auth-role", rolePath)
+
+				continue
+			}
+
+			if err := v.planWrite(ctx, plan, "auth-role", rolePath, role); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, authMethod := range managedAuths {
+		// The items of the auth methods to be created don't exist yet
+		if existingAuths[authMethod.Path] == nil || !v.prunesAuthMethod(authMethod) {
+			continue
+		}
+
+		unmanagedItems, err := v.getUnmanagedAuthItems(ctx, authMethod)
+		if err != nil {
+			return errors.Wrapf(err, "error listing the items of %s auth method", authMethod.Path)
+		}
+		for _, item := range unmanagedItems {
+			plan.add(PlanDelete, item.kind, item.path)
+		}
+	}
+
+	if v.purges(v.externalConfig.PurgeUnmanagedConfig.Exclude.Auth) {
+		for _, path := range sortedKeys(v.getUnmanagedAuthMethods(ctx, managedAuths)) {
+			plan.add(PlanDelete, "auth", path)
+		}
+	}
+
+	return nil
+}
+
+// authWrite is a write of the configuration of an auth method besides its roles
+type authWrite struct {
+	kind string
+	path string
+	data map[string]interface{}
+	// reason is set if the written data can't be compared with the current state
+	reason string
+}
+
+// authWrites returns the writes addAdditionalAuthConfig makes to configure the auth method, except for
+// the roles, which are planned separately
+func authWrites(authMethod auth) ([]authWrite, error) {
+	var writes []authWrite
+
+	switch authMethod.Type {
+	case "kubernetes":
+		write := authWrite{kind: "auth-config", path: fmt.Sprintf("auth/%s/config", authMethod.Path), data: authMethod.Config}
+		if _, ok := authMethod.Config["kubernetes_host"]; !ok {
+			write.reason = "the default config is read from the service account of the pod"
+		}
+		writes = append(writes, write)
+	case "github", "gcp", "oci", "jwt", "oidc", "cert", "ldap", "okta", "azure":
+		writes = append(writes, authWrite{kind: "auth-config", path: fmt.Sprintf("auth/%s/config", authMethod.Path), data: authMethod.Config})
+	case "aws":
+		writes = append(writes, authWrite{kind: "auth-config", path: fmt.Sprintf("auth/%s/config/client", authMethod.Path), data: authMethod.Config})
+	}
+
+	switch authMethod.Type {
+	case "aws":
+		for _, roleRaw := range authMethod.Crossaccountrole {
+			role, err := cast.ToStringMapE(roleRaw)
+			if err != nil {
+				return nil, errors.Wrap(err, "error converting cross account aws roles for aws")
+			}
+			writes = append(writes, authWrite{kind: "auth-crossaccountrole", path: fmt.Sprintf("auth/%s/config/sts/%s", authMethod.Path, role["sts_account"]), data: role})
+		}
+	case "github":
+		mappings, err := cast.ToStringMapE(authMethod.Map)
+		if err != nil {
+			return nil, errors.Wrap(err, "error finding map block for github")
+		}
+		for _, mappingType := range sortedKeys(mappings) {
+			mapping, err := cast.ToStringMapStringE(mappings[mappingType])
+			if err != nil {
+				return nil, errors.Wrap(err, "error converting mapping for github")
+			}
+			for _, userOrTeam := range sortedKeys(mapping) {
+				path := fmt.Sprintf("auth/%s/map/%s/%s", authMethod.Path, mappingType, userOrTeam)
+				writes = append(writes, authWrite{kind: "auth-mapping", path: path, data: map[string]interface{}{"value": mapping[userOrTeam]}})
+			}
+		}
+	case "ldap", "okta":
+		users, err := toStringMapOrEmpty(authMethod.Users)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error finding users block for %s", authMethod.Type)
+		}
+		for _, mappings := range []struct {
+			kind        string
+			mappingType string
+			mappings    map[string]interface{}
+		}{{"auth-user", "users", users}, {"auth-group", "groups", authMethod.Groups}} {
+			for _, userOrGroup := range sortedKeys(mappings.mappings) {
+				mapping, err := cast.ToStringMapE(mappings.mappings[userOrGroup])
+				if err != nil {
+					return nil, errors.Wrapf(err, "error converting mapping for %s", authMethod.Type)
+				}
+				writes = append(writes, authWrite{kind: mappings.kind, path: fmt.Sprintf("auth/%s/%s/%s", authMethod.Path, mappings.mappingType, userOrGroup), data: mapping})
+			}
+		}
+	case "userpass":
+		usersAsserted, _ := authMethod.Users.([]interface{})
+		for _, userRaw := range usersAsserted {
+			user, err := cast.ToStringMapE(userRaw)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error converting user for userpass")
+			}
+			writes = append(writes, authWrite{kind: "auth-user", path: fmt.Sprintf("auth/%s/users/%s", authMethod.Path, user["username"]), data: user})
+		}
+	}
+
+	return writes, nil
+}
+
+func (v *vault) planIdentityGroups(ctx context.Context, plan *Plan) error {
+	for _, group := range v.externalConfig.Groups {
+		existing, err := readVaultGroup(group.Name, v.cl)
+		if err != nil {
+			return errors.Wrap(err, "error reading group")
+		}
+
+		config := map[string]interface{}{
+			"type":     group.Type,
+			"policies": group.Policies,
+			"metadata": group.Metadata,
+		}
+
+		if group.Type != "external" {
+			config["type"] = "internal"
+			if config["member_entity_ids"], err = v.planIdentityIDs(ctx, "entity", group.MemberEntities); err != nil {
+				return err
+			}
+			if config["member_group_ids"], err = v.planIdentityIDs(ctx, "group", group.MemberGroups); err != nil {
+				return err
+			}
+		}
+
+		if existing == nil {
+			plan.add(PlanCreate, "group", group.Name)
+		} else if fields := diffData(config, existing.Data); len(fields) > 0 {
+			plan.add(PlanUpdate, "group", group.Name, fields...)
+		}
+	}
+
+	existingGroupAliases, err := v.listAliases(ctx, "group")
+	if err != nil {
+		return errors.Wrap(err, "error listing group-aliases")
+	}
+
+	for _, groupAlias := range v.externalConfig.GroupAliases {
+		name := aliasName(groupAlias.Name, groupAlias.MountPath)
+
+		accessor, err := v.authMountAccessor(ctx, groupAlias.MountPath)
+		if err != nil {
+			// The auth method is created by this configuration
+			plan.add(PlanCreate, "group-alias", name)
+
+			continue
+		}
+
+		alias, ok := existingGroupAliases[aliasKey(groupAlias.Name, accessor)]
+		if !ok {
+			plan.add(PlanCreate, "group-alias", name)
+
+			continue
+		}
+
+		id, err := v.groupID(ctx, groupAlias.Group)
+		if err != nil {
+			return errors.Wrap(err, "error reading group")
+		}
+
+		if id == "" || id != alias.canonicalID {
+			plan.add(PlanUpdate, "group-alias", name, "canonical_id")
+		}
+	}
+
+	if v.purges(v.externalConfig.PurgeUnmanagedConfig.Exclude.Groups) {
+		existingGroups, err := v.getExistingGroups()
+		if err != nil {
+			return errors.Wrap(err, "failed to get existing groups from vault")
+		}
+
+		for _, name := range sortedKeys(filterOwned(v.ownership, ownedGroups, getUnmanagedGroups(existingGroups, v.externalConfig.Groups))) {
+			plan.add(PlanDelete, "group", name)
+		}
+	}
+
+	if v.purges(v.externalConfig.PurgeUnmanagedConfig.Exclude.GroupAliases) {
+		unmanagedGroupAliases, err := v.getUnmanagedGroupAliases(ctx, v.externalConfig.GroupAliases)
+		if err != nil {
+			return errors.Wrap(err, "failed to get existing group-alias from vault")
+		}
+
+		for _, name := range sortedKeys(filterOwned(v.ownership, ownedGroupAliases, unmanagedGroupAliases)) {
+			plan.add(PlanDelete, "group-alias", name)
+		}
+	}
+
+	return nil
+}
+
+// planIdentityIDs resolves the names of the groups or entities to their IDs, the ones which don't exist yet
+// are left as names, which never match the IDs in Vault, so the field referencing them is reported as changed
+func (v *vault) planIdentityIDs(ctx context.Context, kind string, names []string) ([]string, error) {
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		id, err := v.identityID(ctx, kind, name)
+		if err != nil {
+			return nil, err
+		}
+		if id == "" {
+			id = name
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (v *vault) planIdentityEntities(ctx context.Context, plan *Plan) error {
+	for _, entity := range v.externalConfig.Entities {
+		existing, err := v.cl.Logical().ReadWithContext(ctx, "identity/entity/name/"+entity.Name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read entity %s by name", entity.Name)
+		}
+
+		config := map[string]interface{}{
+			"policies": entity.Policies,
+			"metadata": entity.Metadata,
+			"disabled": entity.Disabled,
+		}
+
+		if existing == nil {
+			plan.add(PlanCreate, "entity", entity.Name)
+		} else if fields := diffData(config, existing.Data); len(fields) > 0 {
+			plan.add(PlanUpdate, "entity", entity.Name, fields...)
+		}
+	}
+
+	existingAliases, err := v.listAliases(ctx, "entity")
+	if err != nil {
+		return errors.Wrap(err, "error listing entity-aliases")
+	}
+
+	for _, entityAlias := range v.externalConfig.EntityAliases {
+		name := aliasName(entityAlias.Name, entityAlias.MountPath)
+
+		accessor, err := v.authMountAccessor(ctx, entityAlias.MountPath)
+		if err != nil {
+			// The auth method is created by this configuration
+			plan.add(PlanCreate, "entity-alias", name)
+
+			continue
+		}
+
+		alias, ok := existingAliases[aliasKey(entityAlias.Name, accessor)]
+		if !ok {
+			plan.add(PlanCreate, "entity-alias", name)
+
+			continue
+		}
+
+		id, err := v.entityID(ctx, entityAlias.Entity)
+		if err != nil {
+			return errors.Wrap(err, "error reading entity")
+		}
+
+		if id == "" || id != alias.canonicalID {
+			plan.add(PlanUpdate, "entity-alias", name, "canonical_id")
+		}
+	}
+
+	if v.purgesEntities(v.externalConfig.PurgeUnmanagedConfig.Exclude.EntityAliases) {
+		unmanagedAliases, err := v.getUnmanagedEntityAliases(ctx, v.externalConfig.EntityAliases)
+		if err != nil {
+			return errors.Wrap(err, "failed to get existing entity-alias from vault")
+		}
+
+		for _, name := range sortedKeys(filterOwned(v.ownership, ownedEntityAliases, unmanagedAliases)) {
+			plan.add(PlanDelete, "entity-alias", name)
+		}
+	}
+
+	if v.purgesEntities(v.externalConfig.PurgeUnmanagedConfig.Exclude.Entities) {
+		existingEntities, err := v.getExistingEntities(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to get existing entities from vault")
+		}
+
+		for _, name := range sortedKeys(filterOwned(v.ownership, ownedEntities, getUnmanagedEntities(existingEntities, v.externalConfig.Entities))) {
+			plan.add(PlanDelete, "entity", name)
+		}
+	}
+
+	return nil
+}
+
+func (v *vault) planOIDCProvider(ctx context.Context, plan *Plan) error {
+	for _, resource := range v.oidcResources() {
+		kind := "oidc-" + resource.kind
+		existing, err := v.cl.Logical().ReadWithContext(ctx, resource.path())
+		if err != nil {
+			return errors.Wrapf(err, "failed to read oidc %s %s", resource.kind, resource.name)
+		}
+
+		if existing == nil {
+			plan.add(PlanCreate, kind, resource.name)
+
+			continue
+		}
+
+		config, err := resource.data(ctx, false)
+		if err != nil {
+			return err
+		}
+
+		// Vault returns the issuer of the providers with their paths
+		if issuer, ok := config["issuer"]; ok && strings.HasPrefix(cast.ToString(existing.Data["issuer"]), cast.ToString(issuer)) {
+			delete(config, "issuer")
+		}
+
+		if fields := diffData(config, existing.Data); len(fields) > 0 {
+			plan.add(PlanUpdate, kind, resource.name, fields...)
+		}
+	}
+
+	if v.purgesOIDCProvider() {
+		unmanaged, err := v.getUnmanagedOIDCResources(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to get existing oidc provider resources from vault")
+		}
+
+		for _, kind := range oidcKinds {
+			for _, name := range sortedKeys(unmanaged[kind]) {
+				plan.add(PlanDelete, "oidc-"+kind, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *vault) planPlugins(ctx context.Context, plan *Plan) error {
+	for _, plugin := range v.externalConfig.Plugins {
+		name := plugin.Type + "/" + plugin.Name
+
+		pluginType, err := api.ParsePluginType(plugin.Type)
+		if err != nil {
+			return errors.Wrap(err, "error parsing type for plugin")
+		}
+
+		existing, err := v.cl.Sys().GetPluginWithContext(ctx, &api.GetPluginInput{Name: plugin.Name, Type: pluginType})
+		if isResponseStatus(err, http.StatusNotFound) || (err == nil && existing == nil) {
+			plan.add(PlanCreate, "plugin", name)
+
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to retrieve plugin %s", name)
+		}
+
+		var fields []string
+		if existing.Command != plugin.Command {
+			fields = append(fields, "command")
+		}
+		if existing.SHA256 != plugin.SHA256 {
+			fields = append(fields, "sha256")
+		}
+		if len(fields) > 0 {
+			plan.add(PlanUpdate, "plugin", name, fields...)
+		}
+	}
+
+	if v.purges(v.externalConfig.PurgeUnmanagedConfig.Exclude.Plugins) {
+		existingPlugins, err := v.getExistingPlugins()
+		if err != nil {
+			return err
+		}
+
+		unmanagedPlugins := filterOwnedPlugins(v.ownership, getUnmanagedPlugins(existingPlugins, v.externalConfig.Plugins))
+		for _, pluginType := range sortedKeys(unmanagedPlugins) {
+			for _, name := range sortedKeys(unmanagedPlugins[pluginType]) {
+				plan.add(PlanDelete, "plugin", pluginType+"/"+name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *vault) planPolicies(ctx context.Context, plan *Plan) error {
+	auths, err := v.cl.Sys().ListAuthWithContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error while getting list of auth engines")
+	}
+
+	managedPolicies, err := initPoliciesConfig(v.externalConfig.Policies, auths)
+	if err != nil {
+		return errors.Wrap(err, "error while initializing policies config")
+	}
+
+	for _, policy := range managedPolicies {
+		existing, err := v.cl.Sys().GetPolicyWithContext(ctx, policy.Name)
+		if err != nil {
+			return errors.Wrapf(err, "error reading %s policy", policy.Name)
+		}
+
+		if existing == "" {
+			plan.add(PlanCreate, "policy", policy.Name)
+		} else if strings.TrimSpace(existing) != strings.TrimSpace(policy.RulesFormatted) {
+			plan.add(PlanUpdate, "policy", policy.Name, "rules")
+		}
+	}
+
+	if v.purges(v.externalConfig.PurgeUnmanagedConfig.Exclude.Policies) {
+		for _, name := range sortedKeys(v.getUnmanagedPolicies(managedPolicies)) {
+			plan.add(PlanDelete, "policy", name)
+		}
+	}
+
+	return nil
+}
+
+func (v *vault) planSecretsEngines(ctx context.Context, plan *Plan) error {
+	managedSecretsEngines := initSecretsEnginesConfig(v.externalConfig.Secrets)
+
+	existingMounts, err := v.cl.Sys().ListMountsWithContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error reading mounts from vault")
+	}
+
+	for _, secretEngine := range managedSecretsEngines {
+		existing := existingMounts[secretEngine.Path+"/"]
+		if existing == nil {
+			plan.add(PlanCreate, "secrets-engine", secretEngine.Path)
+		} else {
+			fields, err := diffMountConfig(secretEngine.Config, existing.Config)
+			if err != nil {
+				return errors.Wrapf(err, "error comparing secret engine %s config", secretEngine.Path)
+			}
+			for _, key := range sortedKeys(secretEngine.Options) {
+				if existing.Options[key] != secretEngine.Options[key] {
+					fields = append(fields, "options."+key)
+				}
+			}
+			if len(fields) > 0 {
+				plan.add(PlanUpdate, "secrets-engine", secretEngine.Path, fields...)
+			}
+		}
+
+		for _, configOption := range sortedKeys(secretEngine.Configuration) {
+			configData, err := cast.ToSliceE(secretEngine.Configuration[configOption])
+			if err != nil {
+				return errors.Wrap(err, "error converting config data for secret engine")
+			}
+
+			for _, subConfigData := range configData {
+				subConfigData, err := cast.ToStringMapE(subConfigData)
+				if err != nil {
+					return errors.Wrap(err, "error converting sub config data for secret engine")
+				}
+
+				configPath := fmt.Sprintf("%s/%s", secretEngine.Path, configOption)
+				if name, ok := subConfigData["name"]; ok {
+					configPath = fmt.Sprintf("%s/%v", configPath, name)
+				}
+
+				if existing == nil {
+					plan.add(PlanCreate, "secrets-engine-config", configPath)
+
+					continue
+				}
+
+				data := map[string]interface{}{}
+				for key, value := range subConfigData {
+					data[key] = value
+				}
+				createOnly := cast.ToBool(data["create_only"]) || cast.ToBool(data["rotate"])
+				delete(data, "create_only")
+				delete(data, "rotate")
+				delete(data, "save_to")
+
+				if configOption == "root/generate" {
+					if err := v.planPKIRootGenerate(ctx, plan, secretEngine.Path, configPath, createOnly); err != nil {
+						return err
+					}
+
+					continue
+				}
+
+				if createOnly {
+					secret, err := v.cl.Logical().ReadWithContext(ctx, configPath)
+					if err == nil && secret != nil && secret.Data != nil {
+						continue
+					}
+				}
+
+				if err := v.planWrite(ctx, plan, "secrets-engine-config", configPath, data); err != nil {
+					return err
+				}
+			}
+		}
+
+		if existing != nil && v.prunesSecretsEngine(secretEngine) {
+			unmanagedConfigs, err := v.getUnmanagedSecretsEngineConfigs(ctx, secretEngine)
+			if err != nil {
+				return errors.Wrapf(err, "error listing the configuration of %s secret engine", secretEngine.Path)
+			}
+			for _, configPath := range unmanagedConfigs {
+				plan.add(PlanDelete, "secrets-engine-config", configPath)
+			}
+		}
+	}
+
+	if v.purges(v.externalConfig.PurgeUnmanagedConfig.Exclude.Secrets) {
+		for _, path := range sortedKeys(v.getUnmanagedSecretsEngines(ctx, managedSecretsEngines)) {
+			plan.add(PlanDelete, "secrets-engine", path)
+		}
+	}
+
+	return nil
+}
+
+// planPKIRootGenerate plans the generation of a PKI root CA, which is regenerated on every apply if it's not create-only
+func (v *vault) planPKIRootGenerate(ctx context.Context, plan *Plan, path, configPath string, createOnly bool) error {
+	req := v.cl.NewRequest(http.MethodGet, fmt.Sprintf("/v1/%s/ca", path))
+	resp, err := v.cl.RawRequestWithContext(ctx, req) //nolint:staticcheck
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil && !isResponseStatus(err, http.StatusNotFound, http.StatusNoContent) {
+		return errors.Wrapf(err, "failed to check pki CA")
+	}
+
+	switch {
+	case resp == nil || resp.StatusCode != http.StatusOK:
+		plan.add(PlanCreate, "secrets-engine-config", configPath)
+	case !createOnly:
+		// A new root CA is generated on every apply, which isn't a drift from the current one
+		plan.addUnknown("secrets-engine-config", configPath, "the root CA is regenerated on every apply unless create_only is set")
+	}
+
+	return nil
+}
+
+func (v *vault) planStartupSecrets(ctx context.Context, plan *Plan) error {
+	for _, startupSecret := range v.externalConfig.StartupSecrets {
+		if startupSecret.Type != "kv" {
+			plan.addUnknown("startup-secret", startupSecret.Path, fmt.Sprintf("'%s' startup secrets are always written", startupSecret.Type))
+
+			continue
+		}
+
+		path, data, err := readStartupSecret(startupSecret, v.externalConfig.Secrets)
+		if err != nil {
+			return errors.Wrap(err, "unable to read 'kv' startup secret")
+		}
+
+		if err := v.planWrite(ctx, plan, "startup-secret", path, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// planWrite plans writing the data to the path by comparing it with the current data
+func (v *vault) planWrite(ctx context.Context, plan *Plan, kind, path string, data map[string]interface{}) error {
+	secret, err := v.cl.Logical().ReadWithContext(ctx, path)
+	if err != nil {
+		if isResponseStatus(err, http.StatusMethodNotAllowed, http.StatusUnsupportedMediaType, http.StatusForbidden) {
+			plan.addUnknown(kind, path, "current state can't be read")
+
+			return nil
+		}
+
+		return errors.Wrapf(err, "error reading %s", path)
+	}
+
+	if secret == nil || secret.Data == nil {
+		plan.add(PlanCreate, kind, path)
+	} else if fields := diffData(data, secret.Data); len(fields) > 0 {
+		plan.add(PlanUpdate, kind, path, fields...)
+	}
+
+	return nil
+}
+
+// diffMountConfig returns the mount config fields which differ from the current config
+func diffMountConfig(desired map[string]interface{}, current api.MountConfigOutput) ([]string, error) {
+	currentConfig := map[string]interface{}{}
+	if err := mapstructure.Decode(current, &currentConfig); err != nil {
+		return nil, errors.Wrap(err, "error decoding mount config")
+	}
+
+	return diffData(desired, currentConfig), nil
+}
+
+// diffData returns the keys of the desired data which differ from the current data, the keys which
+// are not returned by Vault (eg. passwords) can't be compared, so they are ignored.
+func diffData(desired, current map[string]interface{}) []string {
+	var fields []string
+	for _, key := range sortedKeys(desired) {
+		currentValue, ok := current[key]
+		if !ok {
+			continue
+		}
+
+		if !valuesEqual(desired[key], currentValue) {
+			fields = append(fields, key)
+		}
+	}
+
+	return fields
+}
+
+// valuesEqual compares a configured value with the value returned by Vault, which might be in a
+// different format: durations in seconds, lists instead of comma separated strings or nested maps.
+func valuesEqual(desired, current interface{}) bool {
+	if desired == nil {
+		if current == nil {
+			return true
+		}
+
+		switch value := reflect.ValueOf(current); value.Kind() {
+		case reflect.Slice, reflect.Map, reflect.String:
+			return value.Len() == 0
+		default:
+			return false
+		}
+	}
+
+	switch current := current.(type) {
+	case map[string]interface{}:
+		desiredMap, err := cast.ToStringMapE(desired)
+		if err != nil {
+			return false
+		}
+		if len(desiredMap) != len(current) {
+			return false
+		}
+
+		return len(diffData(desiredMap, current)) == 0
+	case []interface{}, []string:
+		desiredSlice := toStringSlice(desired)
+		currentSlice := cast.ToStringSlice(current)
+		sort.Strings(desiredSlice)
+		sort.Strings(currentSlice)
+
+		return reflect.DeepEqual(desiredSlice, currentSlice) || (len(desiredSlice) == 0 && len(currentSlice) == 0)
+	}
+
+	desiredString := cast.ToString(desired)
+	currentString := cast.ToString(current)
+	if desiredString == currentString {
+		return true
+	}
+
+	// Durations are returned in seconds
+	if duration, err := time.ParseDuration(desiredString); err == nil {
+		if seconds, err := cast.ToInt64E(current); err == nil {
+			return int64(duration.Seconds()) == seconds
+		}
+	}
+
+	return false
+}
+
+// toStringSlice converts a list or a comma separated string to a string slice
+func toStringSlice(value interface{}) []string {
+	if value, ok := value.(string); ok {
+		var values []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+
+		return values
+	}
+
+	return cast.ToStringSlice(value)
+}
+
+func isResponseStatus(err error, statusCodes ...int) bool {
+	var respErr *api.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+
+	for _, statusCode := range statusCodes {
+		if respErr.StatusCode == statusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,161 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuesEqual(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired interface{}
+		current interface{}
+		equal   bool
+	}{
+		{name: "same string", desired: "value", current: "value", equal: true},
+		{name: "different string", desired: "value", current: "other", equal: false},
+		{name: "number and string", desired: 10, current: "10", equal: true},
+		{name: "bool", desired: true, current: true, equal: true},
+		{name: "duration in seconds", desired: "1h", current: 3600, equal: true},
+		{name: "different duration", desired: "1h", current: 60, equal: false},
+		{name: "comma separated list", desired: "a, b", current: []interface{}{"b", "a"}, equal: true},
+		{name: "list", desired: []interface{}{"a"}, current: []interface{}{"a", "b"}, equal: false},
+		{name: "nil and empty list", desired: nil, current: []interface{}{}, equal: true},
+		{name: "map", desired: map[string]interface{}{"key": "value"}, current: map[string]interface{}{"key": "value"}, equal: true},
+		{name: "map with extra key", desired: map[string]interface{}{"key": "value"}, current: map[string]interface{}{"key": "value", "other": "value"}, equal: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.equal, valuesEqual(test.desired, test.current))
+		})
+	}
+}
+
+func TestDiffMountConfig(t *testing.T) {
+	desired := map[string]interface{}{
+		"default_lease_ttl":           "1h",
+		"max_lease_ttl":               "24h",
+		"audit_non_hmac_request_keys": []interface{}{"role"},
+	}
+	current := api.MountConfigOutput{
+		DefaultLeaseTTL: 3600,
+		MaxLeaseTTL:     3600,
+	}
+
+	fields, err := diffMountConfig(desired, current)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"audit_non_hmac_request_keys", "max_lease_ttl"}, fields)
+}
+
+func TestPlanString(t *testing.T) {
+	plan := &Plan{}
+	plan.add(PlanCreate, "auth", "kubernetes")
+	plan.add(PlanUpdate, "policy", "admin", "rules")
+	plan.addUnknown("startup-secret", "pki/issue/default", "'pki' startup secrets are always written")
+	plan.add(PlanDelete, "secrets-engine", "secret")
+
+	expected := `+ create auth kubernetes
+~ update policy admin (rules)
+~ update startup-secret pki/issue/default ('pki' startup secrets are always written)
+- delete secrets-engine secret
+Plan: 1 to create, 2 to update, 1 to delete.
+`
+
+	assert.Equal(t, expected, plan.String())
+}
+
+func TestPlanAuthMethods(t *testing.T) {
+	v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/sys/auth":
+			_, _ = w.Write([]byte(`{"data": {"userpass/": {"type": "userpass"}, "github/": {"type": "github"}}}`))
+		case "/v1/auth/github/config":
+			_, _ = w.Write([]byte(`{"data": {"organization": "acme", "base_url": ""}}`))
+		case "/v1/auth/github/map/teams/dev":
+			_, _ = w.Write([]byte(`{"data": {"key": "dev", "value": "dev"}}`))
+		case "/v1/auth/userpass/users/alice":
+			_, _ = w.Write([]byte(`{"data": {"token_policies": ["default"]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	externalConfig, err := decodeExternalConfig(&externalConfig{}, map[string]interface{}{
+		"auth": []interface{}{
+			map[string]interface{}{
+				"type":   "github",
+				"config": map[string]interface{}{"organization": "bank-vaults"},
+				"map": map[string]interface{}{
+					"teams": map[string]interface{}{"dev": "dev", "ops": "ops"},
+				},
+			},
+			map[string]interface{}{
+				"type": "userpass",
+				"users": []interface{}{
+					map[string]interface{}{"username": "alice", "password": "secret", "token_policies": "default"},
+					map[string]interface{}{"username": "bob", "password": "secret"},
+				},
+			},
+			map[string]interface{}{
+				"type":   "ldap",
+				"config": map[string]interface{}{"url": "ldap://ldap.example.com"},
+				"groups": map[string]interface{}{"admins": map[string]interface{}{"policies": "admin"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	v.externalConfig = externalConfig
+
+	plan := &Plan{}
+	require.NoError(t, v.planAuthMethods(context.Background(), plan))
+
+	assert.Equal(t, []PlanChange{
+		{Action: PlanUpdate, Kind: "auth-config", Name: "auth/github/config", Fields: []string{"organization"}},
+		{Action: PlanCreate, Kind: "auth-mapping", Name: "auth/github/map/teams/ops"},
+		{Action: PlanCreate, Kind: "auth-user", Name: "auth/userpass/users/bob"},
+		{Action: PlanCreate, Kind: "auth", Name: "ldap"},
+		{Action: PlanCreate, Kind: "auth-config", Name: "auth/ldap/config"},
+		{Action: PlanCreate, Kind: "auth-group", Name: "auth/ldap/groups/admins"},
+	}, plan.Changes)
+
+	// The generate-root process is never run to plan
+	_, err = v.Plan(context.Background(), map[string]interface{}{})
+	assert.EqualError(t, err, "planning needs a stored root token or a bootstrap auth method, the generate-root process is never run to plan")
+}
+
+func TestPlanPKIRootGenerate(t *testing.T) {
+	v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/pki/ca":
+			_, _ = w.Write([]byte("-----BEGIN CERTIFICATE-----"))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	plan := &Plan{}
+	require.NoError(t, v.planPKIRootGenerate(context.Background(), plan, "pki", "pki/root/generate/internal", true))
+	require.NoError(t, v.planPKIRootGenerate(context.Background(), plan, "other-pki", "other-pki/root/generate/internal", false))
+	require.NoError(t, v.planPKIRootGenerate(context.Background(), plan, "pki", "pki/root/generate/internal", false))
+
+	// The regenerated root CA has a reason, so it isn't reported as a drift
+	assert.Equal(t, []PlanChange{
+		{Action: PlanCreate, Kind: "secrets-engine-config", Name: "other-pki/root/generate/internal"},
+		{Action: PlanUpdate, Kind: "secrets-engine-config", Name: "pki/root/generate/internal", Reason: "the root CA is regenerated on every apply unless create_only is set"},
+	}, plan.Changes)
+}
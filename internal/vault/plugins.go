@@ -15,8 +15,10 @@
 package vault
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
 
 	"emperror.dev/errors"
 	"github.com/hashicorp/vault/api"
@@ -105,13 +107,42 @@ func getUnmanagedPlugins(
 	return existingPlugins
 }
 
-func (v *vault) addManagedPlugins(managedPlugins []plugin) error {
+// filterOwnedPlugins drops the plugins not registered by the configuration from the unmanaged plugins,
+// if the ownership is tracked
+func filterOwnedPlugins(o *ownership, unmanagedPlugins map[string]map[string]bool) map[string]map[string]bool {
+	if o == nil {
+		return unmanagedPlugins
+	}
+
+	for pluginType, pluginNames := range unmanagedPlugins {
+		for pluginName := range pluginNames {
+			if !o.owns(ownedPlugins, pluginType+"/"+pluginName) {
+				delete(pluginNames, pluginName)
+			}
+		}
+	}
+
+	return unmanagedPlugins
+}
+
+func (v *vault) addManagedPlugins(ctx context.Context, managedPlugins []plugin) error {
 	for _, plugin := range managedPlugins {
 		pluginType, err := api.ParsePluginType(plugin.Type)
 		if err != nil {
 			return errors.Wrap(err, "error parsing type for plugin")
 		}
 
+		// Plugins are registered the same way whether they exist or not, so the ones created
+		// have to be looked up if the ownership is tracked
+		created := false
+		if v.ownership != nil {
+			existing, err := v.cl.Sys().GetPluginWithContext(ctx, &api.GetPluginInput{Name: plugin.Name, Type: pluginType})
+			if err != nil && !isResponseStatus(err, http.StatusNotFound) {
+				return errors.Wrapf(err, "error reading plugin %s/%s from vault", plugin.Type, plugin.Name)
+			}
+			created = existing == nil || err != nil
+		}
+
 		input := api.RegisterPluginInput{
 			Name:    plugin.Name,
 			Command: plugin.Command,
@@ -121,22 +152,26 @@ func (v *vault) addManagedPlugins(managedPlugins []plugin) error {
 
 		slog.Info(fmt.Sprintf("adding plugin %s (%s)", plugin.Name, plugin.Type))
 		slog.Debug(fmt.Sprintf("plugin input %#v", input))
-		if err = v.cl.Sys().RegisterPlugin(&input); err != nil {
+		if err = v.cl.Sys().RegisterPluginWithContext(ctx, &input); err != nil {
 			return errors.Wrapf(err, "error adding plugin %s/%s in vault", plugin.Type, plugin.Name)
 		}
+
+		if created {
+			v.ownership.add(ownedPlugins, plugin.Type+"/"+plugin.Name)
+		}
 	}
 
 	return nil
 }
 
-func (v *vault) removeUnmanagedPlugins(managedPlugins []plugin) error {
+func (v *vault) removeUnmanagedPlugins(ctx context.Context, managedPlugins []plugin) error {
 	if !v.externalConfig.PurgeUnmanagedConfig.Enabled || v.externalConfig.PurgeUnmanagedConfig.Exclude.Plugins {
 		slog.Debug("purge config is disabled, no unmanaged plugins will be removed")
 		return nil
 	}
 
 	existingPlugins, _ := v.getExistingPlugins()
-	unmanagedPlugins := getUnmanagedPlugins(existingPlugins, managedPlugins)
+	unmanagedPlugins := filterOwnedPlugins(v.ownership, getUnmanagedPlugins(existingPlugins, managedPlugins))
 
 	for existingPluginType, existingPluginNames := range unmanagedPlugins {
 		for existingPluginName := range existingPluginNames {
@@ -151,23 +186,20 @@ func (v *vault) removeUnmanagedPlugins(managedPlugins []plugin) error {
 			}
 
 			slog.Info(fmt.Sprintf("removing plugin %s (%s)", existingPluginName, existingPluginType))
-			if err := v.cl.Sys().DeregisterPlugin(&input); err != nil {
+			if err := v.cl.Sys().DeregisterPluginWithContext(ctx, &input); err != nil {
 				return errors.Wrapf(err, "error removing plugin %s/%s in vault", existingPluginType, existingPluginName)
 			}
+			v.ownership.remove(ownedPlugins, existingPluginType+"/"+existingPluginName)
 		}
 	}
 
 	return nil
 }
 
-func (v *vault) configurePlugins() error {
+func (v *vault) purgePlugins(ctx context.Context) error {
 	managedPlugins := v.externalConfig.Plugins
 
-	if err := v.addManagedPlugins(managedPlugins); err != nil {
-		return errors.Wrap(err, "error while adding plugins")
-	}
-
-	if err := v.removeUnmanagedPlugins(managedPlugins); err != nil {
+	if err := v.removeUnmanagedPlugins(ctx, managedPlugins); err != nil {
 		return errors.Wrap(err, "error while removing plugins")
 	}
 
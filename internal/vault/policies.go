@@ -15,6 +15,7 @@
 package vault
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -56,12 +57,27 @@ func initPoliciesConfig(policiesConfig []policy, mounts map[string]*api.MountOut
 	return policiesConfig, nil
 }
 
-func (v *vault) addManagedPolicies(managedPolicies []policy) error {
+func (v *vault) addManagedPolicies(ctx context.Context, managedPolicies []policy) error {
 	for _, policy := range managedPolicies {
+		// Policies are written the same way whether they exist or not, so the ones created
+		// have to be looked up if the ownership is tracked
+		created := false
+		if v.ownership != nil {
+			rules, err := v.cl.Sys().GetPolicyWithContext(ctx, policy.Name)
+			if err != nil {
+				return errors.Wrapf(err, "error reading %s policy from vault", policy.Name)
+			}
+			created = rules == ""
+		}
+
 		slog.Info(fmt.Sprintf("adding policy %s", policy.Name))
-		if err := v.cl.Sys().PutPolicy(policy.Name, policy.RulesFormatted); err != nil {
+		if err := v.cl.Sys().PutPolicyWithContext(ctx, policy.Name, policy.RulesFormatted); err != nil {
 			return errors.Wrapf(err, "error putting %s policy into vault", policy.Name)
 		}
+
+		if created {
+			v.ownership.add(ownedPolicies, policy.Name)
+		}
 	}
 
 	return nil
@@ -97,10 +113,10 @@ func (v *vault) getUnmanagedPolicies(managedPolicies []policy) map[string]bool {
 		delete(unmanagedPolicies, managedPolicy.Name)
 	}
 
-	return unmanagedPolicies
+	return filterOwned(v.ownership, ownedPolicies, unmanagedPolicies)
 }
 
-func (v *vault) removeUnmanagedPolicies(managedPolicies []policy) error {
+func (v *vault) removeUnmanagedPolicies(ctx context.Context, managedPolicies []policy) error {
 	if !v.externalConfig.PurgeUnmanagedConfig.Enabled || v.externalConfig.PurgeUnmanagedConfig.Exclude.Policies {
 		slog.Debug("purge config is disabled, no unmanaged policies will be removed")
 		return nil
@@ -109,28 +125,34 @@ func (v *vault) removeUnmanagedPolicies(managedPolicies []policy) error {
 	unmanagedPolicies := v.getUnmanagedPolicies(managedPolicies)
 	for policyName := range unmanagedPolicies {
 		slog.Info(fmt.Sprintf("removing policy %s", policyName))
-		if err := v.cl.Sys().DeletePolicy(policyName); err != nil {
+		if err := v.cl.Sys().DeletePolicyWithContext(ctx, policyName); err != nil {
 			return errors.Wrapf(err, "error deleting %s policy from vault", policyName)
 		}
+		v.ownership.remove(ownedPolicies, policyName)
 	}
 	return nil
 }
 
-func (v *vault) configurePolicies() error {
-	auths, err := v.cl.Sys().ListAuth()
+// addManagedPolicy writes the policy, after resolving the accessors of the auth methods referenced in it
+func (v *vault) addManagedPolicy(ctx context.Context, managedPolicy policy) error {
+	auths, err := v.listAuth(ctx)
 	if err != nil {
 		return errors.Wrap(err, "error while getting list of auth engines")
 	}
-	managedPolicies, err := initPoliciesConfig(v.externalConfig.Policies, auths)
+	managedPolicies, err := initPoliciesConfig([]policy{managedPolicy}, auths)
 	if err != nil {
 		return errors.Wrap(err, "error while initializing policies config")
 	}
 
-	if err := v.addManagedPolicies(managedPolicies); err != nil {
+	if err := v.addManagedPolicies(ctx, managedPolicies); err != nil {
 		return errors.Wrap(err, "error while adding policies")
 	}
 
-	if err := v.removeUnmanagedPolicies(managedPolicies); err != nil {
+	return nil
+}
+
+func (v *vault) purgePolicies(ctx context.Context) error {
+	if err := v.removeUnmanagedPolicies(ctx, v.externalConfig.Policies); err != nil {
 		return errors.Wrap(err, "error while removing policies")
 	}
 
@@ -0,0 +1,318 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/spf13/cast"
+)
+
+// policyCapabilities is the canonical order of the capabilities in a generated policy
+var policyCapabilities = []string{"create", "read", "update", "delete", "list", "sudo"}
+
+// policyRules holds the capabilities needed on Vault API paths
+type policyRules map[string]map[string]bool
+
+func (r policyRules) add(path string, capabilities ...string) {
+	if r[path] == nil {
+		r[path] = map[string]bool{}
+	}
+
+	for _, capability := range capabilities {
+		r[path][capability] = true
+	}
+}
+
+// hcl renders the rules as an HCL policy, sorted by path
+func (r policyRules) hcl() string {
+	paths := make([]string, 0, len(r))
+	for path := range r {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var policy strings.Builder
+	for i, path := range paths {
+		var capabilities []string
+		for _, capability := range policyCapabilities {
+			if r[path][capability] {
+				capabilities = append(capabilities, fmt.Sprintf("%q", capability))
+			}
+		}
+
+		if i > 0 {
+			policy.WriteString("\n")
+		}
+		fmt.Fprintf(&policy, "path %q {\n  capabilities = [%s]\n}\n", path, strings.Join(capabilities, ", "))
+	}
+
+	return policy.String()
+}
+
+// GeneratePolicy generates the minimal HCL policy which is needed to apply the configurations
+// with Configure, in the order they are applied. It covers the mounts, auth methods, policies and
+// identity endpoints the configurations touch, and the deletions of the purge config.
+func GeneratePolicy(configs ...map[string]interface{}) (string, error) {
+	rules, err := generatePolicyRules(configs...)
+	if err != nil {
+		return "", err
+	}
+
+	return rules.hcl(), nil
+}
+
+func generatePolicyRules(configs ...map[string]interface{}) (policyRules, error) {
+	rules := policyRules{}
+
+	loadedConfig := &externalConfig{}
+	for _, config := range configs {
+		var err error
+		loadedConfig, err = decodeExternalConfig(loadedConfig, config)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := addPolicyRules(rules, loadedConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	return rules, nil
+}
+
+func addPolicyRules(rules policyRules, config *externalConfig) error {
+	purge := config.PurgeUnmanagedConfig
+	purges := func(excluded bool) bool {
+		return purge.Enabled && !excluded
+	}
+
+	// Audit devices
+	rules.add("sys/audit", "read", "sudo")
+	for _, audit := range initAuditConfig(config.Audit) {
+		rules.add("sys/audit/"+audit.Path, "create", "update", "sudo")
+	}
+	if purges(purge.Exclude.Audit) {
+		rules.add("sys/audit/*", "delete", "sudo")
+	}
+
+	// Auth methods
+	rules.add("sys/auth", "read")
+	for _, auth := range initAuthConfig(config.Auth) {
+		rules.add("sys/auth/"+auth.Path, "create", "update", "sudo")
+		if auth.Options != nil {
+			rules.add("sys/mounts/auth/"+auth.Path+"/tune", "update")
+		}
+
+		path := auth.Path
+		if auth.Type == "token" {
+			path = "token"
+		}
+		rules.add("auth/"+path+"/*", "create", "update")
+
+		// The roles, users, groups and mappings which are not in the configuration are listed and removed
+		if purges(purge.Exclude.Auth) && !auth.KeepUnmanaged {
+			rules.add("auth/"+path+"/*", "list", "delete")
+		}
+	}
+	if purges(purge.Exclude.Auth) {
+		rules.add("sys/auth/*", "delete", "sudo")
+	}
+
+	// Entities and entity aliases, the existing entities are looked up in the list of the entities
+	if len(config.Entities) > 0 || len(config.EntityAliases) > 0 {
+		rules.add("identity/entity/id", "list")
+	}
+	for _, entity := range config.Entities {
+		rules.add("identity/entity", "create", "update")
+		rules.add("identity/entity/name/"+entity.Name, "create", "update")
+	}
+	if len(config.EntityAliases) > 0 {
+		rules.add("identity/entity-alias", "create", "update")
+		rules.add("identity/entity-alias/id", "list")
+		rules.add("identity/entity-alias/id/*", "update")
+	}
+	// Entities are only removed if they are owned by the configuration, as Vault creates them on logins
+	if purge.OwnedOnly && purges(purge.Exclude.Entities) {
+		rules.add("identity/entity/name", "list")
+		rules.add("identity/entity/name/*", "delete")
+	}
+	if purge.OwnedOnly && purges(purge.Exclude.EntityAliases) {
+		rules.add("identity/entity-alias/id", "list")
+		rules.add("identity/entity-alias/id/*", "delete")
+	}
+
+	// Groups and group aliases, the existing groups are looked up in the list of the groups
+	if len(config.Groups) > 0 || len(config.GroupAliases) > 0 {
+		rules.add("identity/group/id", "list")
+	}
+	for _, group := range config.Groups {
+		rules.add("identity/group", "create", "update")
+		rules.add("identity/group/name/"+group.Name, "read", "create", "update")
+		// The members of internal groups are looked up in the list of the entities
+		if len(group.MemberEntities) > 0 {
+			rules.add("identity/entity/id", "list")
+		}
+	}
+	if len(config.GroupAliases) > 0 {
+		rules.add("identity/group-alias", "create", "update")
+		rules.add("identity/group-alias/id", "list")
+		rules.add("identity/group-alias/id/*", "update")
+		for _, groupAlias := range config.GroupAliases {
+			rules.add("identity/group/name/"+groupAlias.Group, "read")
+		}
+	}
+	if purges(purge.Exclude.Groups) {
+		rules.add("identity/group/name", "list")
+		rules.add("identity/group/name/*", "delete")
+	}
+	if purges(purge.Exclude.GroupAliases) {
+		rules.add("identity/group-alias/id", "list")
+		rules.add("identity/group-alias/id/*", "delete")
+	}
+
+	// OIDC provider, the resources are read to tell apart the new ones and to check the references,
+	// only their names and references are needed here
+	for _, resource := range (&vault{externalConfig: config}).oidcResources() {
+		rules.add(resource.path(), "read", "create", "update")
+		for _, reference := range resource.references {
+			switch {
+			case reference.kind == "entity" || reference.kind == "group":
+				rules.add("identity/"+reference.kind+"/id", "list")
+			case oidcBuiltins[reference.kind][reference.name]:
+				continue
+			default:
+				rules.add(oidcPath(reference.kind, reference.name), "read")
+			}
+		}
+	}
+	if purges(purge.Exclude.OIDCProvider) && config.OIDCProvider != nil {
+		for _, kind := range oidcKinds {
+			rules.add("identity/oidc/"+kind, "list")
+			rules.add("identity/oidc/"+kind+"/*", "delete")
+		}
+	}
+
+	// Plugins
+	for _, plugin := range config.Plugins {
+		rules.add(fmt.Sprintf("sys/plugins/catalog/%s/%s", plugin.Type, plugin.Name), "create", "update", "sudo")
+		// The plugins registered by the configuration are looked up to track their ownership
+		if purge.OwnedOnly {
+			rules.add(fmt.Sprintf("sys/plugins/catalog/%s/%s", plugin.Type, plugin.Name), "read")
+		}
+	}
+	if purges(purge.Exclude.Plugins) {
+		rules.add("sys/plugins/catalog", "read")
+		rules.add("sys/plugins/catalog/*", "read", "delete", "sudo")
+	}
+
+	// Policies
+	for _, policy := range config.Policies {
+		rules.add("sys/policies/acl/"+policy.Name, "create", "update")
+		// The policies written by the configuration are looked up to track their ownership
+		if purge.OwnedOnly {
+			rules.add("sys/policies/acl/"+policy.Name, "read")
+		}
+	}
+	if purges(purge.Exclude.Policies) {
+		rules.add("sys/policies/acl", "list")
+		rules.add("sys/policies/acl/*", "delete")
+	}
+
+	// Secrets engines
+	rules.add("sys/mounts", "read")
+	for _, secretEngine := range initSecretsEnginesConfig(config.Secrets) {
+		rules.add("sys/mounts/"+secretEngine.Path, "create", "update")
+		rules.add("sys/mounts/"+secretEngine.Path+"/tune", "update")
+
+		if err := addSecretEngineConfigurationRules(rules, secretEngine); err != nil {
+			return err
+		}
+
+		// The entries of the configuration options which are not in the configuration are listed and removed
+		if purges(purge.Exclude.Secrets) && secretEngine.PurgeUnmanaged {
+			configOptions, err := prunableConfigOptions(secretEngine)
+			if err != nil {
+				return err
+			}
+			for configOption := range configOptions {
+				rules.add(fmt.Sprintf("%s/%s", secretEngine.Path, configOption), "list")
+				rules.add(fmt.Sprintf("%s/%s/*", secretEngine.Path, configOption), "delete")
+			}
+		}
+	}
+	if purges(purge.Exclude.Secrets) {
+		rules.add("sys/mounts/*", "delete")
+	}
+
+	// Startup secrets
+	for _, startupSecret := range config.StartupSecrets {
+		rules.add(startupSecret.Path, "create", "update")
+	}
+
+	return nil
+}
+
+// addSecretEngineConfigurationRules adds the paths written by the generic secret engine configuration
+func addSecretEngineConfigurationRules(rules policyRules, secretEngine secretEngine) error {
+	for configOption, configData := range secretEngine.Configuration {
+		configData, err := cast.ToSliceE(configData)
+		if err != nil {
+			return errors.Wrap(err, "error converting config data for secret engine")
+		}
+
+		for _, subConfigData := range configData {
+			subConfigData, err := cast.ToStringMapE(subConfigData)
+			if err != nil {
+				return errors.Wrap(err, "error converting sub config data for secret engine")
+			}
+
+			configPath := fmt.Sprintf("%s/%s", secretEngine.Path, configOption)
+			name, hasName := subConfigData["name"]
+			if hasName {
+				configPath = fmt.Sprintf("%s/%v", configPath, name)
+			}
+
+			rules.add(configPath, "create", "update")
+
+			rotate := cast.ToBool(subConfigData["rotate"])
+			if cast.ToBool(subConfigData["create_only"]) || rotate {
+				if configOption == "root/generate" {
+					rules.add(secretEngine.Path+"/ca", "read")
+				} else {
+					rules.add(configPath, "read")
+				}
+			}
+
+			if saveTo := cast.ToString(subConfigData["save_to"]); saveTo != "" {
+				rules.add(saveTo, "create", "update")
+			}
+
+			if rotate {
+				switch {
+				case secretEngine.Type == "database" && configOption == "config":
+					rules.add(fmt.Sprintf("%s/rotate-root/%v", secretEngine.Path, name), "update")
+				case secretEngine.Type == "aws" && configOption == "config/root":
+					rules.add(secretEngine.Path+"/config/rotate-root", "update")
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,297 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"emperror.dev/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGeneratePolicy(t *testing.T) {
+	config := map[string]interface{}{
+		"purgeUnmanagedConfig": map[string]interface{}{
+			"enabled": true,
+			"exclude": map[string]interface{}{
+				"audit": true, "auth": true, "groups": true, "group-aliases": true, "plugins": true,
+				"secrets": true,
+			},
+		},
+		"auth": []interface{}{
+			map[string]interface{}{
+				"type":  "kubernetes",
+				"roles": []interface{}{map[string]interface{}{"name": "default"}},
+			},
+		},
+		"policies": []interface{}{
+			map[string]interface{}{"name": "allow_secrets", "rules": `path "secret/*" { capabilities = ["read"] }`},
+		},
+		"secrets": []interface{}{
+			map[string]interface{}{
+				"path": "database",
+				"type": "database",
+				"configuration": map[string]interface{}{
+					"config": []interface{}{
+						map[string]interface{}{"name": "mysql", "rotate": true},
+					},
+				},
+			},
+		},
+	}
+
+	policy, err := GeneratePolicy(config)
+	require.NoError(t, err)
+
+	expected := `path "auth/kubernetes/*" {
+  capabilities = ["create", "update"]
+}
+
+path "database/config/mysql" {
+  capabilities = ["create", "read", "update"]
+}
+
+path "database/rotate-root/mysql" {
+  capabilities = ["update"]
+}
+
+path "sys/audit" {
+  capabilities = ["read", "sudo"]
+}
+
+path "sys/auth" {
+  capabilities = ["read"]
+}
+
+path "sys/auth/kubernetes" {
+  capabilities = ["create", "update", "sudo"]
+}
+
+path "sys/mounts" {
+  capabilities = ["read"]
+}
+
+path "sys/mounts/database" {
+  capabilities = ["create", "update"]
+}
+
+path "sys/mounts/database/tune" {
+  capabilities = ["update"]
+}
+
+path "sys/policies/acl" {
+  capabilities = ["list"]
+}
+
+path "sys/policies/acl/*" {
+  capabilities = ["delete"]
+}
+
+path "sys/policies/acl/allow_secrets" {
+  capabilities = ["create", "update"]
+}
+`
+	assert.Equal(t, expected, policy)
+}
+
+func TestGeneratePolicyInvalidConfig(t *testing.T) {
+	_, err := GeneratePolicy(map[string]interface{}{"polices": []interface{}{}})
+	assert.Error(t, err)
+}
+
+// recordingVault is an in-memory Vault which stores the written data by path and records the
+// capabilities of the requests it serves
+type recordingVault struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	data     map[string]map[string]interface{}
+	ids      int
+	requests policyRules
+}
+
+func (rv *recordingVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+
+	var data map[string]interface{}
+	if r.Method == http.MethodPut || r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+			rv.t.Errorf("invalid request body: %s", err)
+		}
+	}
+
+	switch {
+	case r.Method == "LIST" || r.URL.Query().Get("list") == "true":
+		rv.requests.add(path, "list")
+		rv.list(w, path)
+	case r.Method == http.MethodGet:
+		rv.requests.add(path, "read")
+		rv.read(w, path)
+	case r.Method == http.MethodPut || r.Method == http.MethodPost:
+		if _, ok := rv.data[path]; ok {
+			rv.requests.add(path, "update")
+		} else {
+			rv.requests.add(path, "create")
+		}
+		rv.write(w, path, data)
+	case r.Method == http.MethodDelete:
+		rv.requests.add(path, "delete")
+		delete(rv.data, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		rv.t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+	}
+}
+
+func (rv *recordingVault) respond(w http.ResponseWriter, data interface{}) {
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"data": data}); err != nil {
+		rv.t.Errorf("error encoding response: %s", err)
+	}
+}
+
+func (rv *recordingVault) list(w http.ResponseWriter, path string) {
+	keys := map[string]bool{}
+	keyInfo := map[string]interface{}{}
+	for dataPath, data := range rv.data {
+		name, ok := strings.CutPrefix(dataPath, path+"/")
+		if !ok {
+			continue
+		}
+		if before, _, nested := strings.Cut(name, "/"); nested {
+			keys[before+"/"] = true
+		} else {
+			keys[name] = true
+			keyInfo[name] = data
+		}
+	}
+
+	if len(keys) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	rv.respond(w, map[string]interface{}{"keys": sortedKeys(keys), "key_info": keyInfo})
+}
+
+func (rv *recordingVault) read(w http.ResponseWriter, path string) {
+	// The mounts are listed by their paths with a trailing slash
+	if mountsPath, ok := map[string]string{"sys/auth": "sys/auth/", "sys/mounts": "sys/mounts/", "sys/audit": "sys/audit/"}[path]; ok {
+		mounts := map[string]interface{}{}
+		for dataPath, data := range rv.data {
+			if name, ok := strings.CutPrefix(dataPath, mountsPath); ok && !strings.Contains(name, "/") {
+				mounts[name+"/"] = map[string]interface{}{"type": data["type"], "accessor": data["accessor"]}
+			}
+		}
+		rv.respond(w, mounts)
+
+		return
+	}
+
+	data, ok := rv.data[path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	rv.respond(w, data)
+}
+
+func (rv *recordingVault) write(w http.ResponseWriter, path string, data map[string]interface{}) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	switch path {
+	// The identity resources are created with generated IDs, and can be read by their names
+	case "identity/entity", "identity/group", "identity/entity-alias", "identity/group-alias":
+		rv.ids++
+		id := fmt.Sprintf("id-%d", rv.ids)
+		data["id"] = id
+		rv.data[path+"/id/"+id] = data
+		if name, ok := data["name"].(string); ok && !strings.HasSuffix(path, "-alias") {
+			rv.data[path+"/name/"+name] = data
+		}
+		rv.respond(w, map[string]interface{}{"id": id})
+
+		return
+	}
+
+	// The mounts have accessors, like the mounts of Vault
+	if name, ok := strings.CutPrefix(path, "sys/auth/"); ok {
+		data["accessor"] = "auth_" + name
+	}
+
+	rv.data[path] = data
+	rv.respond(w, data)
+}
+
+// allows tells whether the rules grant the capability on the path, the paths of the rules
+// ending with a * match every path with their prefix
+func (r policyRules) allows(path, capability string) bool {
+	for rulePath, capabilities := range r {
+		prefix, glob := strings.CutSuffix(rulePath, "*")
+		if capabilities[capability] && (rulePath == path || glob && strings.HasPrefix(path, prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestGeneratePolicyCoversConfigure(t *testing.T) {
+	data, err := os.ReadFile("../../vault-config.yml")
+	require.NoError(t, err)
+
+	var config map[string]interface{}
+	require.NoError(t, yaml.Unmarshal(data, &config))
+	config["purgeUnmanagedConfig"] = map[string]interface{}{"enabled": true}
+
+	// The default kubernetes auth config is only read in a pod
+	kubernetes := config["auth"].([]interface{})[0].(map[string]interface{})
+	kubernetes["config"] = map[string]interface{}{"kubernetes_host": "https://kubernetes.default.svc"}
+
+	for _, secretEngine := range config["secrets"].([]interface{}) {
+		secretEngine.(map[string]interface{})["purgeUnmanaged"] = true
+	}
+
+	recorder := &recordingVault{t: t, data: map[string]map[string]interface{}{}, requests: policyRules{}}
+	v := newTestVault(t, recorder)
+
+	// The unmanaged resources of every kind are purged
+	for _, path := range []string{
+		"sys/audit/legacy", "sys/auth/legacy", "sys/mounts/legacy", "sys/policies/acl/legacy",
+		"identity/group/name/legacy", "identity/group-alias/id/legacy", "database/roles/legacy",
+	} {
+		recorder.data[path] = map[string]interface{}{"type": "legacy", "name": "legacy"}
+	}
+
+	// The policy is generated first, as applying the configuration changes it
+	rules, err := generatePolicyRules(config)
+	require.NoError(t, err)
+
+	require.NoError(t, v.configure(context.Background(), config))
+
+	for _, path := range sortedKeys(recorder.requests) {
+		for _, capability := range policyCapabilities {
+			if !recorder.requests[path][capability] {
+				continue
+			}
+
+			// The endpoints without existence checks need update for new paths as well
+			allowed := rules.allows(path, capability) || capability == "create" && rules.allows(path, "update")
+			assert.Truef(t, allowed, "the generated policy doesn't allow %s on %s", capability, path)
+		}
+	}
+}
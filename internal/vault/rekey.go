@@ -0,0 +1,105 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"emperror.dev/errors"
+	"github.com/hashicorp/vault/api"
+)
+
+// rekeyTarget holds the Vault API endpoints and the key store names of the keys being rekeyed,
+// which are the unseal keys (sys/rekey) or the recovery keys of auto-unsealed Vaults (sys/rekey-recovery-key).
+type rekeyTarget struct {
+	// kind is the kind of the keys for logging, "unseal" or "recovery"
+	kind     string
+	keyForID func(int) string
+
+	status             func(ctx context.Context) (*api.RekeyStatusResponse, error)
+	init               func(ctx context.Context, config *api.RekeyInitRequest) (*api.RekeyStatusResponse, error)
+	update             func(ctx context.Context, shard, nonce string) (*api.RekeyUpdateResponse, error)
+	cancel             func(ctx context.Context) error
+	verificationStatus func(ctx context.Context) (*api.RekeyVerificationStatusResponse, error)
+	verificationUpdate func(ctx context.Context, shard, nonce string) (*api.RekeyVerificationUpdateResponse, error)
+}
+
+func (v *vault) unsealRekeyTarget() rekeyTarget {
+	sys := v.cl.Sys()
+
+	return rekeyTarget{
+		kind:               "unseal",
+		keyForID:           keyUnsealForID,
+		status:             sys.RekeyStatusWithContext,
+		init:               sys.RekeyInitWithContext,
+		update:             sys.RekeyUpdateWithContext,
+		cancel:             sys.RekeyCancelWithContext,
+		verificationStatus: sys.RekeyVerificationStatusWithContext,
+		verificationUpdate: sys.RekeyVerificationUpdateWithContext,
+	}
+}
+
+func (v *vault) recoveryRekeyTarget() rekeyTarget {
+	sys := v.cl.Sys()
+
+	return rekeyTarget{
+		kind:               "recovery",
+		keyForID:           keyRecoveryForID,
+		status:             sys.RekeyRecoveryKeyStatusWithContext,
+		init:               sys.RekeyRecoveryKeyInitWithContext,
+		update:             sys.RekeyRecoveryKeyUpdateWithContext,
+		cancel:             sys.RekeyRecoveryKeyCancelWithContext,
+		verificationStatus: sys.RekeyRecoveryKeyVerificationStatusWithContext,
+		verificationUpdate: sys.RekeyRecoveryKeyVerificationUpdateWithContext,
+	}
+}
+
+// rekeyTarget returns the keys to rekey: the recovery keys if Vault is auto-unsealed
+// (it has a recovery seal, just like at Init), the unseal keys otherwise.
+func (v *vault) rekeyTarget(ctx context.Context) (rekeyTarget, error) {
+	sealResp, err := v.cl.Sys().SealStatusWithContext(ctx)
+	if err != nil {
+		return rekeyTarget{}, errors.Wrap(err, "error getting seal status")
+	}
+
+	if sealResp.RecoverySeal {
+		return v.recoveryRekeyTarget(), nil
+	}
+
+	return v.unsealRekeyTarget(), nil
+}
+
+// cancelRekey cancels the rekey operation after an error to avoid leaving it in an inconsistent state,
+// the passed context might be already canceled so it can't be used here.
+func (t rekeyTarget) cancelRekey() {
+	if err := t.cancel(context.Background()); err != nil {
+		slog.Error("failed to cancel rekey operation after error", slog.String("error", err.Error()))
+	}
+}
+
+// currentVerification returns the status of the rekey verification, Vault responds with an error
+// instead of a status if no rekey operation is in progress, which is returned as not started.
+func (t rekeyTarget) currentVerification(ctx context.Context) (*api.RekeyVerificationStatusResponse, error) {
+	status, err := t.verificationStatus(ctx)
+
+	var respErr *api.ResponseError
+	if errors.As(err, &respErr) && respErr.StatusCode == http.StatusBadRequest {
+		return &api.RekeyVerificationStatusResponse{}, nil
+	}
+
+	return status, err
+}
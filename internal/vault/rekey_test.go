@@ -0,0 +1,39 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRekeyTarget(t *testing.T) {
+	tests := []struct {
+		recoverySeal bool
+		kind         string
+		key          string
+	}{
+		{recoverySeal: false, kind: "unseal", key: "vault-unseal-0"},
+		{recoverySeal: true, kind: "recovery", key: "vault-recovery-0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.kind, func(t *testing.T) {
+			v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v1/sys/seal-status", r.URL.Path)
+				fmt.Fprintf(w, `{"sealed": false, "recovery_seal": %t}`, test.recoverySeal)
+			}))
+
+			v.keyStore = newMockKVService()
+
+			target, err := v.rekeyTarget(context.Background())
+			require.NoError(t, err)
+
+			assert.Equal(t, test.kind, target.kind)
+			assert.Equal(t, test.key, target.keyForID(0))
+		})
+	}
+}
@@ -0,0 +1,448 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/hashicorp/vault/api"
+
+	"github.com/bank-vaults/bank-vaults/pkg/kv"
+)
+
+const (
+	// keyStagedPrefix prefixes the new keys of a rotation until they are verified, the share
+	// number stays at the end of the key, so split key stores keep them with the old share.
+	keyStagedPrefix = "staged-"
+	// keyRotationVerifying marks that the last verification share of the staged keys is being sent, so the staged
+	// keys might be the operational keys of Vault already, they are never removed once it is set. It holds the
+	// number of the staged keys.
+	keyRotationVerifying = "vault-rotation-verifying"
+	// keyRootTokenRevoke holds the replaced root token until it is revoked
+	keyRootTokenRevoke = "vault-root-revoke"
+	// keyLastRotation holds the time of the last successful rotation in RFC 3339 format
+	keyLastRotation = "vault-last-rotation"
+)
+
+// Rotate rotates the unseal (or recovery) keys and the stored root token, then records the time of the
+// rotation in the key store. The keys are rotated first, so the new root token is generated with the new keys.
+func (v *vault) Rotate(ctx context.Context) error {
+	if err := v.RotateKeys(ctx); err != nil {
+		return errors.Wrap(err, "error rotating keys")
+	}
+
+	if v.config.StoreRootToken {
+		if err := v.rotateRootToken(ctx); err != nil {
+			return errors.Wrap(err, "error rotating root token")
+		}
+	} else {
+		slog.Info("root token is not stored in the key store, skipping root token rotation")
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if err := v.kvStore().SetContext(ctx, keyLastRotation, []byte(now)); err != nil {
+		return errors.Wrap(err, "error recording rotation time")
+	}
+
+	return nil
+}
+
+// LastRotation returns the time of the last successful rotation, or the zero time if Vault was never rotated
+func (v *vault) LastRotation(ctx context.Context) (time.Time, error) {
+	lastRotation, err := v.kvStore().GetContext(ctx, keyLastRotation)
+	if isNotFoundError(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "unable to get key '%s'", keyLastRotation)
+	}
+
+	t, err := time.Parse(time.RFC3339, string(lastRotation))
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "invalid last rotation time")
+	}
+
+	return t, nil
+}
+
+// rotateRootToken generates a new root token with the generate-root process, replaces the stored root
+// token with it and revokes the old one. The new token is staged and the old one is kept until it is
+// revoked, so an interrupted rotation can be resumed without leaking root tokens.
+func (v *vault) rotateRootToken(ctx context.Context) error {
+	defer runtime.GC()
+	defer v.cl.SetToken(v.cl.Token())
+
+	stagedKey := keyStagedPrefix + keyRootToken
+
+	newToken, err := v.kvStore().GetContext(ctx, stagedKey)
+	if isNotFoundError(err) {
+		newToken, err = v.generateRootToken(ctx)
+		if err != nil {
+			return err
+		}
+		if len(newToken) == 0 {
+			return errors.New("generate-root process didn't return a root token")
+		}
+
+		if err := v.kvStore().SetContext(ctx, stagedKey, newToken); err != nil {
+			return errors.Wrap(err, "error staging root token")
+		}
+	} else if err != nil {
+		return errors.Wrapf(err, "unable to get key '%s'", stagedKey)
+	} else {
+		slog.Info("resuming root token rotation with the staged root token")
+	}
+
+	// Keep the old token until it is revoked, unless a previous attempt kept it already
+	oldToken, err := v.kvStore().GetContext(ctx, keyRootTokenRevoke)
+	if isNotFoundError(err) {
+		oldToken, err = v.kvStore().GetContext(ctx, keyRootToken)
+		if err != nil && !isNotFoundError(err) {
+			return errors.Wrapf(err, "unable to get key '%s'", keyRootToken)
+		}
+
+		if err == nil && string(oldToken) != string(newToken) {
+			if err := v.kvStore().SetContext(ctx, keyRootTokenRevoke, oldToken); err != nil {
+				return errors.Wrap(err, "error storing the old root token for revocation")
+			}
+		} else {
+			oldToken = nil
+		}
+	} else if err != nil {
+		return errors.Wrapf(err, "unable to get key '%s'", keyRootTokenRevoke)
+	}
+
+	if err := v.kvStore().SetContext(ctx, keyRootToken, newToken); err != nil {
+		return errors.Wrap(err, "error storing the new root token")
+	}
+
+	slog.With(slog.String("key", keyRootToken)).Info("root token replaced in key store")
+
+	if len(oldToken) > 0 {
+		v.cl.SetToken(string(newToken))
+
+		if err := v.revokeToken(ctx, string(oldToken)); err != nil {
+			return errors.Wrap(err, "error revoking the old root token")
+		}
+
+		if err := kv.Delete(ctx, v.keyStore, keyRootTokenRevoke); err != nil {
+			return errors.Wrap(err, "error removing the old root token")
+		}
+
+		slog.Info("old root token revoked")
+	}
+
+	return errors.Wrap(kv.Delete(ctx, v.keyStore, stagedKey), "error removing staged root token")
+}
+
+// revokeToken revokes a token and its children, a token which is already invalid is skipped
+func (v *vault) revokeToken(ctx context.Context, token string) error {
+	if _, err := v.cl.Auth().Token().LookupWithContext(ctx, token); err != nil {
+		var respErr *api.ResponseError
+		if errors.As(err, &respErr) && (respErr.StatusCode == http.StatusBadRequest || respErr.StatusCode == http.StatusForbidden) {
+			slog.Info("token is already revoked")
+
+			return nil
+		}
+
+		return errors.Wrap(err, "error looking up token")
+	}
+
+	return errors.Wrap(v.cl.Auth().Token().RevokeTreeWithContext(ctx, token), "error revoking token")
+}
+
+// RotateKeys rekeys Vault without PGP keys and replaces the unseal keys, or the recovery keys if Vault is
+// auto-unsealed, in the key store. The new keys are stored under staging names first, they are verified
+// with sys/rekey/verify (sys/rekey-recovery-key/verify), then they replace the old keys, which makes it
+// possible to resume an interrupted rotation.
+func (v *vault) RotateKeys(ctx context.Context) error {
+	defer runtime.GC()
+
+	if _, ok := v.keyStore.(kv.ManagedService); !ok {
+		return errors.Errorf("key store %T doesn't support deleting keys, which is required to rotate the keys", v.keyStore)
+	}
+
+	target, err := v.rekeyTarget(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The previous rotation might have been verified, but the keys weren't swapped
+	marked, err := v.kvStore().GetContext(ctx, keyRotationVerifying)
+	if err != nil && !isNotFoundError(err) {
+		return errors.Wrapf(err, "error checking previous %s key rotation", target.kind)
+	}
+	if err == nil {
+		shares, err := strconv.Atoi(string(marked))
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s key rotation verification mark", target.kind)
+		}
+
+		verificationStatus, err := target.currentVerification(ctx)
+		if err != nil {
+			return errors.Wrap(err, "unable to check rekey verification status")
+		}
+
+		// The last verification share didn't reach Vault, the old keys are still the operational ones
+		if verificationStatus.Started {
+			slog.Info("resuming rekey verification", slog.String("nonce", verificationStatus.Nonce))
+
+			if err := v.verifyStagedKeys(ctx, target, verificationStatus.Nonce); err != nil {
+				return err
+			}
+		} else {
+			status, err := target.status(ctx)
+			if err != nil {
+				return errors.Wrap(err, "unable to check rekey status")
+			}
+			if status.Started {
+				return errors.Errorf("a rekey operation is in progress, but the staged %s keys of a previous rotation might be the operational ones, it has to be canceled first", target.kind)
+			}
+
+			// Without a rekey or verification in progress Vault accepts the staged keys only
+			slog.Info(fmt.Sprintf("resuming verified %s key rotation", target.kind))
+		}
+
+		return v.swapStagedKeys(ctx, target.keyForID, shares)
+	}
+
+	verificationNonce, err := v.stageRotatedKeys(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	if err := v.verifyStagedKeys(ctx, target, verificationNonce); err != nil {
+		return err
+	}
+
+	shares, err := v.countKeys(ctx, stagedKeyForID(target.keyForID))
+	if err != nil {
+		return errors.Wrapf(err, "error counting staged %s keys", target.kind)
+	}
+
+	return v.swapStagedKeys(ctx, target.keyForID, shares)
+}
+
+// stageRotatedKeys starts or resumes a rekey operation which requires verification, and stores
+// the new keys under staging names. It returns the verification nonce.
+func (v *vault) stageRotatedKeys(ctx context.Context, target rekeyTarget) (string, error) {
+	verificationStatus, err := target.currentVerification(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to check rekey verification status")
+	}
+
+	if verificationStatus.Started {
+		staged, err := v.countKeys(ctx, stagedKeyForID(target.keyForID))
+		if err != nil {
+			return "", errors.Wrapf(err, "error counting staged %s keys", target.kind)
+		}
+
+		// The new keys are already staged, only the verification is missing
+		if staged >= verificationStatus.T {
+			slog.Info("resuming rekey verification", slog.String("nonce", verificationStatus.Nonce))
+
+			return verificationStatus.Nonce, nil
+		}
+
+		// The new keys were lost before they were staged, the old keys are still valid
+		slog.Info(fmt.Sprintf("new %s keys weren't staged, canceling rekey operation", target.kind))
+		if err := target.cancel(ctx); err != nil {
+			return "", errors.Wrap(err, "unable to cancel rekey operation")
+		}
+	}
+
+	status, err := target.status(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to check rekey status")
+	}
+
+	var nonce string
+	if status.Started {
+		if len(status.PGPFingerprints) > 0 || !status.VerificationRequired {
+			return "", errors.New("a rekey operation with PGP keys or without verification is in progress, it has to be finished or canceled first")
+		}
+
+		nonce = status.Nonce
+		slog.Info("resuming existing rekey operation", slog.String("nonce", nonce))
+	} else {
+		// Drop the leftovers of an interrupted rotation, the new keys of it were never verified
+		if err := v.deleteKeysFrom(ctx, stagedKeyForID(target.keyForID), 0); err != nil {
+			return "", errors.Wrapf(err, "error removing stale staged %s keys", target.kind)
+		}
+
+		rekeyRequest := api.RekeyInitRequest{
+			SecretShares:        v.config.SecretShares,
+			SecretThreshold:     v.config.SecretThreshold,
+			RequireVerification: true,
+		}
+
+		slog.Info(fmt.Sprintf("initializing %s key rekey operation with verification...", target.kind),
+			slog.Int("shares", v.config.SecretShares),
+			slog.Int("threshold", v.config.SecretThreshold))
+
+		resp, err := target.init(ctx, &rekeyRequest)
+		if err != nil {
+			return "", errors.Wrap(err, "unable to start rekey init process")
+		}
+		if resp.Nonce == "" {
+			return "", errors.New("failed to init rekey operation: empty nonce returned. Vault auth token may be incorrect")
+		}
+
+		nonce = resp.Nonce
+	}
+
+	resp, err := v.sendRekeyUpdates(ctx, target, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	stagedKey := stagedKeyForID(target.keyForID)
+	for i, k := range resp.Keys {
+		keyID := stagedKey(i)
+		if err := v.kvStore().SetContext(ctx, keyID, []byte(k)); err != nil {
+			// The new keys are lost, the whole rekey operation has to be restarted
+			target.cancelRekey()
+
+			return "", errors.Wrapf(err, "error staging %s key '%s'", target.kind, keyID)
+		}
+
+		slog.With(slog.String("key", keyID)).Info(fmt.Sprintf("new %s key staged in key store", target.kind))
+	}
+
+	return resp.VerificationNonce, nil
+}
+
+// verifyStagedKeys proves to Vault that the staged keys were stored successfully, the new keys
+// become the operational ones when the verification completes. The rotation is marked before the
+// last share is sent, so the staged keys are kept even if the process dies right after it.
+func (v *vault) verifyStagedKeys(ctx context.Context, target rekeyTarget, nonce string) error {
+	stagedKey := stagedKeyForID(target.keyForID)
+
+	verificationStatus, err := target.currentVerification(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to check rekey verification status")
+	}
+
+	shares, err := v.countKeys(ctx, stagedKey)
+	if err != nil {
+		return errors.Wrapf(err, "error counting staged %s keys", target.kind)
+	}
+
+	// The shares accepted by a previous attempt are not sent again
+	for i := verificationStatus.Progress; ; i++ {
+		keyID := stagedKey(i)
+
+		k, err := v.kvStore().GetContext(ctx, keyID)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get staged key '%s'", keyID)
+		}
+
+		if i >= verificationStatus.T-1 {
+			if err := v.kvStore().SetContext(ctx, keyRotationVerifying, []byte(strconv.Itoa(shares))); err != nil {
+				return errors.Wrapf(err, "error marking %s key rotation verifying", target.kind)
+			}
+		}
+
+		slog.Info("sending rekey verification request to vault...")
+		resp, err := target.verificationUpdate(ctx, string(k), nonce)
+		if err != nil {
+			return errors.Wrap(err, "failed to send rekey verification request to vault")
+		}
+
+		if resp.Complete {
+			slog.Info("rekey verification completed successfully")
+
+			return nil
+		}
+	}
+}
+
+// swapStagedKeys replaces the keys with the staged ones, then removes the old keys which are
+// not replaced (if the number of shares decreased), the staged keys and the rotation mark.
+// The keys are removed backwards, so an interrupted swap can be resumed.
+func (v *vault) swapStagedKeys(ctx context.Context, keyForID func(int) string, shares int) error {
+	stagedKey := stagedKeyForID(keyForID)
+
+	for i := 0; i < shares; i++ {
+		k, err := v.kvStore().GetContext(ctx, stagedKey(i))
+		if isNotFoundError(err) {
+			// The staged keys are only removed after every key is replaced
+			slog.Info("staged keys are already swapped")
+
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to get staged key '%s'", stagedKey(i))
+		}
+
+		if err := v.kvStore().SetContext(ctx, keyForID(i), k); err != nil {
+			return errors.Wrapf(err, "error replacing key '%s'", keyForID(i))
+		}
+
+		slog.With(slog.String("key", keyForID(i))).Info("key replaced in key store")
+	}
+
+	if err := v.deleteKeysFrom(ctx, keyForID, shares); err != nil {
+		return errors.Wrap(err, "error removing old keys")
+	}
+
+	if err := v.deleteKeysFrom(ctx, stagedKey, 0); err != nil {
+		return errors.Wrap(err, "error removing staged keys")
+	}
+
+	return errors.Wrap(kv.Delete(ctx, v.keyStore, keyRotationVerifying), "error removing rotation mark")
+}
+
+// countKeys returns the number of keys from the first one until the first missing one
+func (v *vault) countKeys(ctx context.Context, keyForID func(int) string) (int, error) {
+	for i := 0; ; i++ {
+		notFound, err := v.keyStoreNotFound(ctx, keyForID(i))
+		if notFound {
+			return i, nil
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "error checking key '%s'", keyForID(i))
+		}
+	}
+}
+
+// deleteKeysFrom deletes the keys from the given index until the first missing one, backwards
+func (v *vault) deleteKeysFrom(ctx context.Context, keyForID func(int) string, from int) error {
+	count, err := v.countKeys(ctx, func(i int) string { return keyForID(from + i) })
+	if err != nil {
+		return err
+	}
+
+	for i := from + count - 1; i >= from; i-- {
+		if err := kv.Delete(ctx, v.keyStore, keyForID(i)); err != nil {
+			return errors.Wrapf(err, "error removing key '%s'", keyForID(i))
+		}
+	}
+
+	return nil
+}
+
+func stagedKeyForID(keyForID func(int) string) func(int) string {
+	return func(i int) string {
+		return keyStagedPrefix + keyForID(i)
+	}
+}
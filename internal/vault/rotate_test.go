@@ -0,0 +1,281 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/bank-vaults/pkg/kv"
+)
+
+// managedMockKVService implements kv.ManagedService for testing
+type managedMockKVService struct {
+	*mockKVService
+}
+
+func (m managedMockKVService) SetContext(_ context.Context, key string, value []byte) error {
+	return m.Set(key, value)
+}
+
+func (m managedMockKVService) GetContext(_ context.Context, key string) ([]byte, error) {
+	return m.Get(key)
+}
+
+func (m managedMockKVService) ListContext(_ context.Context, prefix string) ([]string, error) {
+	keys := make([]string, 0, len(m.store))
+	for key := range m.store {
+		keys = append(keys, key)
+	}
+
+	return kv.FilterKeys(keys, prefix), nil
+}
+
+func (m managedMockKVService) DeleteContext(_ context.Context, key string) error {
+	delete(m.store, key)
+	return nil
+}
+
+// failingMockKVService fails the first write of a key
+type failingMockKVService struct {
+	managedMockKVService
+	failKey string
+}
+
+func (m *failingMockKVService) SetContext(ctx context.Context, key string, value []byte) error {
+	if key == m.failKey {
+		m.failKey = ""
+		return fmt.Errorf("error writing key '%s'", key)
+	}
+
+	return m.managedMockKVService.SetContext(ctx, key, value)
+}
+
+// fakeRekeyVault serves the rekey and rekey verification endpoints of a Vault with 3 key shares and
+// a threshold of 2, it accepts the operational keys only, which are replaced when a verification completes
+type fakeRekeyVault struct {
+	t            *testing.T
+	prefix       string
+	recoverySeal bool
+	keys         []string
+	generation   int
+
+	rekeyStarted   bool
+	rekeyProgress  int
+	newKeys        []string
+	verifying      bool
+	verifyProgress int
+}
+
+func (f *fakeRekeyVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct{ Key string }
+	if r.Method == http.MethodPut {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Errorf("invalid request body: %s", err)
+		}
+	}
+
+	reply := func(resp interface{}) {
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			f.t.Errorf("error encoding response: %s", err)
+		}
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /v1/sys/seal-status":
+		reply(api.SealStatusResponse{RecoverySeal: f.recoverySeal})
+	case "GET /v1/sys/" + f.prefix + "/init":
+		reply(api.RekeyStatusResponse{Started: f.rekeyStarted, Nonce: "rekey", T: 2, N: 3, Progress: f.rekeyProgress, VerificationRequired: true})
+	case "PUT /v1/sys/" + f.prefix + "/init":
+		f.rekeyStarted, f.rekeyProgress, f.verifying = true, 0, false
+		reply(api.RekeyStatusResponse{Started: true, Nonce: "rekey", T: 2, N: 3, VerificationRequired: true})
+	case "PUT /v1/sys/" + f.prefix + "/update":
+		if !f.rekeyStarted || !assert.Contains(f.t, f.keys, body.Key) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if f.rekeyProgress++; f.rekeyProgress < 2 {
+			reply(api.RekeyUpdateResponse{Nonce: "rekey"})
+			return
+		}
+
+		f.generation++
+		f.newKeys = nil
+		for i := 0; i < 3; i++ {
+			f.newKeys = append(f.newKeys, fmt.Sprintf("key-%d-%d", f.generation, i))
+		}
+		f.rekeyStarted, f.verifying, f.verifyProgress = false, true, 0
+		reply(api.RekeyUpdateResponse{Nonce: "rekey", Complete: true, Keys: f.newKeys, VerificationRequired: true, VerificationNonce: "verify"})
+	case "GET /v1/sys/" + f.prefix + "/verify":
+		if !f.rekeyStarted && !f.verifying {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors": ["no rekey configuration found"]}`))
+			return
+		}
+
+		reply(api.RekeyVerificationStatusResponse{Started: f.verifying, Nonce: "verify", T: 2, N: 3, Progress: f.verifyProgress})
+	case "PUT /v1/sys/" + f.prefix + "/verify":
+		if !f.verifying || !assert.Contains(f.t, f.newKeys, body.Key) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if f.verifyProgress++; f.verifyProgress < 2 {
+			reply(api.RekeyVerificationUpdateResponse{Nonce: "verify"})
+			return
+		}
+
+		f.keys, f.verifying = f.newKeys, false
+		reply(api.RekeyVerificationUpdateResponse{Nonce: "verify", Complete: true})
+	default:
+		f.t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+	}
+}
+
+func TestRotateKeysResume(t *testing.T) {
+	targets := map[string]struct {
+		prefix       string
+		recoverySeal bool
+		keyForID     func(int) string
+	}{
+		"unseal":   {prefix: "rekey", keyForID: keyUnsealForID},
+		"recovery": {prefix: "rekey-recovery-key", recoverySeal: true, keyForID: keyRecoveryForID},
+	}
+
+	for name, target := range targets {
+		failures := map[string]string{
+			// The rotation fails before the last verification share is sent
+			"mark": keyRotationVerifying,
+			// The rotation fails after the verification, the staged keys are the operational ones
+			"swap": target.keyForID(0),
+		}
+
+		for failure, failKey := range failures {
+			t.Run(name+"/"+failure, func(t *testing.T) {
+				fake := &fakeRekeyVault{t: t, prefix: target.prefix, recoverySeal: target.recoverySeal}
+
+				store := &failingMockKVService{managedMockKVService: managedMockKVService{newMockKVService()}, failKey: failKey}
+				for i := 0; i < 3; i++ {
+					fake.keys = append(fake.keys, fmt.Sprintf("key-0-%d", i))
+					store.store[target.keyForID(i)] = []byte(fake.keys[i])
+				}
+
+				v := newTestVault(t, fake)
+				v.keyStore = store
+				v.config = &Config{SecretShares: 3, SecretThreshold: 2}
+
+				require.Error(t, v.RotateKeys(context.Background()))
+				assert.Contains(t, store.store, keyStagedPrefix+target.keyForID(0))
+
+				require.NoError(t, v.RotateKeys(context.Background()))
+
+				// The keys were rotated once, without losing the verified keys
+				assert.Equal(t, 1, fake.generation)
+				assert.Equal(t, map[string][]byte{
+					target.keyForID(0): []byte("key-1-0"),
+					target.keyForID(1): []byte("key-1-1"),
+					target.keyForID(2): []byte("key-1-2"),
+				}, store.store)
+			})
+		}
+	}
+}
+
+func TestSwapStagedKeys(t *testing.T) {
+	store := managedMockKVService{newMockKVService()}
+	for i := 0; i < 5; i++ {
+		store.store[keyUnsealForID(i)] = []byte("old")
+	}
+	for i := 0; i < 3; i++ {
+		store.store[keyStagedPrefix+keyUnsealForID(i)] = []byte("new")
+	}
+	store.store[keyRotationVerifying] = []byte("3")
+
+	v := &vault{keyStore: store, config: &Config{}}
+
+	require.NoError(t, v.swapStagedKeys(context.Background(), keyUnsealForID, 3))
+
+	assert.Equal(t, map[string][]byte{
+		keyUnsealForID(0): []byte("new"),
+		keyUnsealForID(1): []byte("new"),
+		keyUnsealForID(2): []byte("new"),
+	}, store.store)
+}
+
+func TestSwapStagedKeysResume(t *testing.T) {
+	// The swap was interrupted while removing the staged keys
+	store := managedMockKVService{newMockKVService()}
+	for i := 0; i < 3; i++ {
+		store.store[keyUnsealForID(i)] = []byte("new")
+	}
+	store.store[keyStagedPrefix+keyUnsealForID(0)] = []byte("new")
+	store.store[keyRotationVerifying] = []byte("3")
+
+	v := &vault{keyStore: store, config: &Config{}}
+
+	require.NoError(t, v.swapStagedKeys(context.Background(), keyUnsealForID, 3))
+
+	assert.Len(t, store.store, 3)
+	assert.Equal(t, []byte("new"), store.store[keyUnsealForID(2)])
+}
+
+func TestRotateKeysUnmanagedStore(t *testing.T) {
+	v := &vault{keyStore: newMockKVService(), config: &Config{}}
+
+	assert.Error(t, v.RotateKeys(context.Background()))
+}
+
+func TestLastRotation(t *testing.T) {
+	store := newMockKVService()
+	v := &vault{keyStore: store, config: &Config{}}
+
+	lastRotation, err := v.LastRotation(context.Background())
+	require.NoError(t, err)
+	assert.True(t, lastRotation.IsZero())
+
+	store.store[keyLastRotation] = []byte("2024-05-01T10:00:00Z")
+
+	lastRotation, err = v.LastRotation(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), lastRotation)
+}
+
+func TestRotateRootTokenResume(t *testing.T) {
+	var revoked []string
+	v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "new-token", r.Header.Get("X-Vault-Token"))
+
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup":
+			_, _ = w.Write([]byte(`{"data": {}}`))
+		case "/v1/auth/token/revoke":
+			var body struct{ Token string }
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			revoked = append(revoked, body.Token)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+		}
+	}))
+
+	// The new root token was staged, but the rotation was interrupted
+	store := managedMockKVService{newMockKVService()}
+	store.store[keyRootToken] = []byte("old-token")
+	store.store[keyStagedPrefix+keyRootToken] = []byte("new-token")
+
+	v.keyStore = store
+
+	require.NoError(t, v.rotateRootToken(context.Background()))
+
+	assert.Equal(t, []string{"old-token"}, revoked)
+	assert.Equal(t, map[string][]byte{keyRootToken: []byte("new-token")}, store.store)
+	assert.Empty(t, v.cl.Token())
+}
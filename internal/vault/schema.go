@@ -0,0 +1,405 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is the subset of JSON Schema the configuration is described with
+type JSONSchema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is a type name or a list of type names, any value is allowed if it's nil
+	Type       interface{}            `json:"type,omitempty"`
+	Enum       []string               `json:"enum,omitempty"`
+	Properties map[string]*JSONSchema `json:"properties,omitempty"`
+	// AdditionalProperties is a *JSONSchema or false
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema `json:"items,omitempty"`
+	Required             []string    `json:"required,omitempty"`
+}
+
+// scalarSchema allows the scalar values the configuration decoder converts to strings
+var scalarSchema = &JSONSchema{Type: []string{"string", "number", "boolean"}}
+
+func objectSchema(required ...string) *JSONSchema {
+	return &JSONSchema{Type: "object", Required: required}
+}
+
+// schemaRequired holds the required fields of the configuration structs
+var schemaRequired = map[reflect.Type][]string{
+	reflect.TypeOf(audit{}):          {"type"},
+	reflect.TypeOf(auth{}):           {"type"},
+	reflect.TypeOf(entity{}):         {"name"},
+	reflect.TypeOf(entityAlias{}):    {"name", "mountpath", "entity"},
+	reflect.TypeOf(group{}):          {"name"},
+	reflect.TypeOf(groupAlias{}):     {"name", "mountpath", "group"},
+	reflect.TypeOf(oidcAssignment{}): {"name"},
+	reflect.TypeOf(oidcClient{}):     {"name"},
+	reflect.TypeOf(oidcKey{}):        {"name"},
+	reflect.TypeOf(oidcProvider{}):   {"name"},
+	reflect.TypeOf(oidcRole{}):       {"name", "key"},
+	reflect.TypeOf(oidcScope{}):      {"name"},
+	reflect.TypeOf(plugin{}):         {"plugin_name", "type", "command", "sha256"},
+	reflect.TypeOf(policy{}):         {"name", "rules"},
+	reflect.TypeOf(secretEngine{}):   {"type"},
+	reflect.TypeOf(startupSecret{}):  {"type", "path"},
+}
+
+// schemaFields holds the schema of the configuration struct fields which can't be derived from their
+// Go type, because they are passed to the Vault API as they are
+var schemaFields = map[reflect.Type]map[string]*JSONSchema{
+	reflect.TypeOf(auth{}): {
+		"roles":            {Type: "array", Items: objectSchema("name")},
+		"crossaccountrole": {Type: "array", Items: objectSchema("sts_account")},
+	},
+	reflect.TypeOf(oidcClient{}): {
+		"client_type": {Type: "string", Enum: []string{"confidential", "public"}},
+	},
+	reflect.TypeOf(oidcKey{}): {
+		"algorithm": {Type: "string", Enum: []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}},
+	},
+	reflect.TypeOf(plugin{}): {
+		"type": {Type: "string", Enum: []string{"auth", "database", "secret"}},
+	},
+	reflect.TypeOf(secretEngine{}): {
+		"configuration": {Type: "object", AdditionalProperties: &JSONSchema{Type: "array", Items: objectSchema()}},
+		"options":       {Type: "object", AdditionalProperties: scalarSchema},
+	},
+	reflect.TypeOf(startupSecret{}): {
+		"type": {Type: "string", Enum: []string{"kv", "pki"}},
+	},
+}
+
+// ConfigSchema returns the JSON Schema of the configuration files of Configure, generated from the
+// configuration structs.
+func ConfigSchema() *JSONSchema {
+	schema := schemaForType(reflect.TypeOf(externalConfig{}))
+	schema.Schema = jsonSchemaDraft
+	schema.Description = "Bank-Vaults external configuration of Vault"
+
+	return schema
+}
+
+func schemaForType(t reflect.Type) *JSONSchema {
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaForType(t.Elem())}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.String {
+			return &JSONSchema{Type: "object", AdditionalProperties: scalarSchema}
+		}
+
+		return &JSONSchema{Type: "object", AdditionalProperties: schemaForType(t.Elem())}
+	case reflect.Ptr:
+		return schemaForType(t.Elem())
+	case reflect.Struct:
+		schema := &JSONSchema{
+			Type:                 "object",
+			Properties:           map[string]*JSONSchema{},
+			AdditionalProperties: false,
+			Required:             schemaRequired[t],
+		}
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			// Fields without a tag are not part of the configuration
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if name == "" {
+				continue
+			}
+
+			if fieldSchema, ok := schemaFields[t][name]; ok {
+				schema.Properties[name] = fieldSchema
+			} else {
+				schema.Properties[name] = schemaForType(field.Type)
+			}
+		}
+
+		return schema
+	default:
+		// Any value
+		return &JSONSchema{}
+	}
+}
+
+// ValidationError is an error in a configuration file at the position of the invalid value
+type ValidationError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	}
+
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+// ValidateConfig validates a YAML/JSON configuration file of Configure against its schema and returns
+// all the errors found, with their positions in the file.
+func ValidateConfig(data []byte) ([]ValidationError, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, errors.Wrap(err, "error parsing vault config")
+	}
+
+	// Empty file
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	validator := schemaValidator{}
+	validator.validate(ConfigSchema(), document.Content[0], "")
+
+	sort.SliceStable(validator.errors, func(i, j int) bool {
+		if validator.errors[i].Line != validator.errors[j].Line {
+			return validator.errors[i].Line < validator.errors[j].Line
+		}
+
+		return validator.errors[i].Column < validator.errors[j].Column
+	})
+
+	return validator.errors, nil
+}
+
+type schemaValidator struct {
+	errors []ValidationError
+}
+
+func (s *schemaValidator) addError(node *yaml.Node, path string, format string, args ...interface{}) {
+	s.errors = append(s.errors, ValidationError{
+		Line:    node.Line,
+		Column:  node.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (s *schemaValidator) validate(schema *JSONSchema, node *yaml.Node, path string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	// A null value is the same as a missing value for the configuration decoder
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	if !schemaTypeMatches(schema.Type, node) {
+		s.addError(node, path, "expected %s, got %s", schemaTypeNames(schema.Type), yamlTypeName(node))
+		return
+	}
+
+	if len(schema.Enum) > 0 && !stringInSlice(schema.Enum, node.Value) {
+		s.addError(node, path, "must be one of %s, got %q", strings.Join(schema.Enum, ", "), node.Value)
+	}
+
+	switch node.Kind {
+	case yaml.SequenceNode:
+		if schema.Items == nil {
+			return
+		}
+
+		for i, item := range node.Content {
+			s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case yaml.MappingNode:
+		keys := map[string]bool{}
+
+		for _, pair := range mappingPairs(node) {
+			key, value := pair[0], pair[1]
+			keys[key.Value] = true
+
+			keyPath := key.Value
+			if path != "" {
+				keyPath = path + "." + key.Value
+			}
+
+			if propertySchema, ok := schema.Properties[key.Value]; ok {
+				s.validate(propertySchema, value, keyPath)
+
+				continue
+			}
+
+			switch additionalProperties := schema.AdditionalProperties.(type) {
+			case bool:
+				if !additionalProperties {
+					s.addError(key, keyPath, "unknown field %q", key.Value)
+				}
+			case *JSONSchema:
+				s.validate(additionalProperties, value, keyPath)
+			}
+		}
+
+		for _, required := range schema.Required {
+			if !keys[required] {
+				s.addError(node, path, "missing required field %q", required)
+			}
+		}
+	}
+}
+
+// mappingPairs returns the key-value pairs of a mapping, including the pairs of the merged mappings
+func mappingPairs(node *yaml.Node) [][2]*yaml.Node {
+	var pairs [][2]*yaml.Node
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		if key.Tag == "!!merge" {
+			if value.Kind == yaml.AliasNode {
+				value = value.Alias
+			}
+
+			merged := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				merged = value.Content
+			}
+
+			for _, mergedNode := range merged {
+				if mergedNode.Kind == yaml.AliasNode {
+					mergedNode = mergedNode.Alias
+				}
+				if mergedNode.Kind == yaml.MappingNode {
+					pairs = append(pairs, mappingPairs(mergedNode)...)
+				}
+			}
+
+			continue
+		}
+
+		pairs = append(pairs, [2]*yaml.Node{key, value})
+	}
+
+	return pairs
+}
+
+func schemaTypeMatches(schemaType interface{}, node *yaml.Node) bool {
+	switch schemaType := schemaType.(type) {
+	case nil:
+		return true
+	case string:
+		return yamlTypeMatches(schemaType, node)
+	case []string:
+		for _, t := range schemaType {
+			if yamlTypeMatches(t, node) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// yamlTypeMatches tells whether the node can be decoded as the type, the configuration is decoded with weakly
+// typed input, so the scalars are converted to the type of the field like the configuration decoder does
+func yamlTypeMatches(schemaType string, node *yaml.Node) bool {
+	switch schemaType {
+	case "object":
+		return node.Kind == yaml.MappingNode
+	case "array":
+		return node.Kind == yaml.SequenceNode
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return false
+	}
+
+	switch node.Tag {
+	case "!!int", "!!float", "!!bool":
+		return schemaType == "string" || schemaType == "boolean" || schemaType == "integer" || schemaType == "number"
+	case "!!str":
+		var err error
+		switch schemaType {
+		case "string":
+			return true
+		case "boolean":
+			_, err = strconv.ParseBool(node.Value)
+		case "integer":
+			_, err = strconv.ParseInt(node.Value, 0, 64)
+		case "number":
+			_, err = strconv.ParseFloat(node.Value, 64)
+		default:
+			return false
+		}
+
+		// Empty strings are decoded as zero values
+		return node.Value == "" || err == nil
+	}
+
+	return false
+}
+
+func schemaTypeNames(schemaType interface{}) string {
+	if types, ok := schemaType.([]string); ok {
+		return strings.Join(types, " or ")
+	}
+
+	return fmt.Sprint(schemaType)
+}
+
+func yamlTypeName(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+
+	switch node.Tag {
+	case "!!bool":
+		return "boolean"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+func stringInSlice(list []string, match string) bool {
+	for _, item := range list {
+		if item == match {
+			return true
+		}
+	}
+
+	return false
+}
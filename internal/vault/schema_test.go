@@ -0,0 +1,78 @@
+package vault
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfigExample(t *testing.T) {
+	data, err := os.ReadFile("../../vault-config.yml")
+	require.NoError(t, err)
+
+	validationErrors, err := ValidateConfig(data)
+	require.NoError(t, err)
+
+	assert.Empty(t, validationErrors)
+}
+
+func TestValidateConfig(t *testing.T) {
+	config := `
+purgeUnmanagedConfig:
+  enabled: yes please
+auth:
+  - type: kubernetes
+    rolez: []
+    roles:
+      - bound_service_account_names: default
+  - path: approle
+secrets:
+  - type: kv
+    options:
+      version: 2
+startupSecrets:
+  - type: generic
+    path: secret/data/test
+`
+
+	validationErrors, err := ValidateConfig([]byte(config))
+	require.NoError(t, err)
+
+	expected := []ValidationError{
+		{Line: 3, Column: 12, Path: "purgeUnmanagedConfig.enabled", Message: "expected boolean, got string"},
+		{Line: 6, Column: 5, Path: "auth[0].rolez", Message: `unknown field "rolez"`},
+		{Line: 8, Column: 9, Path: "auth[0].roles[0]", Message: `missing required field "name"`},
+		{Line: 9, Column: 5, Path: "auth[1]", Message: `missing required field "type"`},
+		{Line: 15, Column: 11, Path: "startupSecrets[0].type", Message: `must be one of kv, pki, got "generic"`},
+	}
+	assert.Equal(t, expected, validationErrors)
+}
+
+func TestValidateConfigInvalidYAML(t *testing.T) {
+	_, err := ValidateConfig([]byte("auth: [\n"))
+
+	assert.Error(t, err)
+}
+
+func TestValidateConfigWeaklyTyped(t *testing.T) {
+	// The scalars are converted to the types of the fields like the configuration decoder does
+	config := `
+purgeUnmanagedConfig:
+  enabled: "true"
+  exclude:
+    auth: 1
+policies:
+  - name: 123
+    rules: path "secret/*" { capabilities = ["read"] }
+entities:
+  - name: true
+    disabled: ""
+`
+
+	validationErrors, err := ValidateConfig([]byte(config))
+	require.NoError(t, err)
+
+	assert.Empty(t, validationErrors)
+}
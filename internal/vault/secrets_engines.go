@@ -55,6 +55,38 @@ type secretEngine struct {
 	PluginName    string                 `mapstructure:"plugin_name"`
 	Local         bool                   `mapstructure:"local"`
 	SealWrap      bool                   `mapstructure:"seal_wrap"`
+	// PurgeUnmanaged removes the named entries of the configuration options, like the database connections
+	// and roles, which are not in the configuration, if the unmanaged configuration is purged
+	PurgeUnmanaged bool `mapstructure:"purgeUnmanaged"`
+}
+
+// destructiveConfigOptions holds the configuration options of the secret engine types which are never purged,
+// because removing their entries destroys keys or certificates which can't be recovered
+var destructiveConfigOptions = map[string]map[string]bool{
+	"gcpkms": {"keys": true},
+	"pki": {
+		"issuer": true, "issuers": true, "key": true, "keys": true,
+		"root/generate": true, "intermediate/generate": true,
+	},
+	"totp":    {"keys": true},
+	"transit": {"keys": true},
+}
+
+// listableConfigOptions holds the configuration options of the secret engine types which have named entries
+// that can be listed
+var listableConfigOptions = map[string][]string{
+	"aws":        {"roles"},
+	"azure":      {"roles"},
+	"consul":     {"roles"},
+	"database":   {"config", "roles", "static-roles"},
+	"gcpkms":     {"keys"},
+	"kubernetes": {"roles"},
+	"ldap":       {"role", "static-role"},
+	"nomad":      {"role"},
+	"pki":        {"issuers", "keys", "roles"},
+	"ssh":        {"roles"},
+	"totp":       {"keys"},
+	"transit":    {"keys"},
 }
 
 func initSecretsEnginesConfig(configs []secretEngine) []secretEngine {
@@ -84,8 +116,8 @@ func (se *secretEngine) getMountConfigInput() (api.MountConfigInput, error) {
 	return mountConfigInput, nil
 }
 
-func (v *vault) mountExists(path string) (bool, error) {
-	mounts, err := v.cl.Sys().ListMounts()
+func (v *vault) mountExists(ctx context.Context, path string) (bool, error) {
+	mounts, err := v.listMounts(ctx)
 	if err != nil {
 		return false, errors.Wrap(err, "error reading mounts from vault")
 	}
@@ -94,7 +126,7 @@ func (v *vault) mountExists(path string) (bool, error) {
 	return mounts[path+"/"] != nil, nil
 }
 
-func (v *vault) rotateSecretEngineCredentials(secretEngineType, path, name, configPath string) error {
+func (v *vault) rotateSecretEngineCredentials(ctx context.Context, secretEngineType, path, name, configPath string) error {
 	var rotatePath string
 	switch secretEngineType {
 	case "aws":
@@ -107,10 +139,14 @@ func (v *vault) rotateSecretEngineCredentials(secretEngineType, path, name, conf
 		return errors.Errorf("secret engine type '%s' doesn't support credential rotation", secretEngineType)
 	}
 
+	// The secret engines are configured concurrently
+	v.rotateMu.Lock()
+	defer v.rotateMu.Unlock()
+
 	if _, ok := v.rotateCache[rotatePath]; !ok {
 		slog.Info(fmt.Sprintf("doing credential rotation at %s", rotatePath))
 
-		_, err := v.writeWithWarningCheck(rotatePath, nil)
+		_, err := v.writeWithWarningCheck(ctx, rotatePath, nil)
 		if err != nil {
 			return errors.Wrapf(err, "error rotating credentials for '%s' config in vault", configPath)
 		}
@@ -129,10 +165,10 @@ func (v *vault) rotateSecretEngineCredentials(secretEngineType, path, name, conf
 // since probably they will be the same for all config types.
 
 // getExistingSecretsEngines gets all secrets engines that are already in Vault.
-func (v *vault) getExistingSecretsEngines() (map[string]bool, error) {
+func (v *vault) getExistingSecretsEngines(ctx context.Context) (map[string]bool, error) {
 	existingSecretsEngines := make(map[string]bool)
 
-	existingSecretsEnginesList, err := v.cl.Sys().ListMounts()
+	existingSecretsEnginesList, err := v.listMounts(ctx)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to list existing secrets engines")
 	}
@@ -146,8 +182,8 @@ func (v *vault) getExistingSecretsEngines() (map[string]bool, error) {
 
 // getUnmanagedSecretsEngines gets unmanaged secrets engines by comparing what's already in Vault
 // and what's in the externalConfig.
-func (v *vault) getUnmanagedSecretsEngines(managedSecretsEngines []secretEngine) map[string]bool {
-	unmanagedSecretsEngines, _ := v.getExistingSecretsEngines()
+func (v *vault) getUnmanagedSecretsEngines(ctx context.Context, managedSecretsEngines []secretEngine) map[string]bool {
+	unmanagedSecretsEngines, _ := v.getExistingSecretsEngines(ctx)
 
 	// Ignore system mounts.
 	delete(unmanagedSecretsEngines, "sys")
@@ -159,7 +195,7 @@ func (v *vault) getUnmanagedSecretsEngines(managedSecretsEngines []secretEngine)
 		delete(unmanagedSecretsEngines, managedSecretEngine.Path)
 	}
 
-	return unmanagedSecretsEngines
+	return filterOwned(v.ownership, ownedSecrets, unmanagedSecretsEngines)
 }
 
 func configNeedsNoName(secretEngineType string, configOption string) bool {
@@ -179,7 +215,7 @@ func configNeedsNoName(secretEngineType string, configOption string) bool {
 	return false
 }
 
-func (v *vault) addManagedSecretsEngines(managedSecretsEngines []secretEngine) error {
+func (v *vault) addManagedSecretsEngines(ctx context.Context, managedSecretsEngines []secretEngine) error {
 	b := &backoff.Backoff{
 		Min:    500 * time.Millisecond,
 		Max:    60 * time.Second,
@@ -188,7 +224,7 @@ func (v *vault) addManagedSecretsEngines(managedSecretsEngines []secretEngine) e
 	}
 
 	for _, secretEngine := range managedSecretsEngines {
-		mountExists, err := v.mountExists(secretEngine.Path)
+		mountExists, err := v.mountExists(ctx, secretEngine.Path)
 		if err != nil {
 			return err
 		}
@@ -213,7 +249,8 @@ func (v *vault) addManagedSecretsEngines(managedSecretsEngines []secretEngine) e
 			slog.Info(fmt.Sprintf("adding secret engine %s (%s)", secretEngine.Path, secretEngine.Type))
 			slog.Debug(fmt.Sprintf("secret engine input %#v", mountInput))
 			for {
-				err = v.cl.Sys().Mount(secretEngine.Path, &mountInput)
+				err = v.cl.Sys().MountWithContext(ctx, secretEngine.Path, &mountInput)
+				v.cache.invalidateMounts()
 
 				if err != nil {
 					d := b.Duration()
@@ -223,17 +260,22 @@ func (v *vault) addManagedSecretsEngines(managedSecretsEngines []secretEngine) e
 						// Stop retrying after reaching the max backoff time
 						return errors.Wrapf(err, "error mounting %s into vault after several attempts", secretEngine.Path)
 					}
-					time.Sleep(d)
+					select {
+					case <-ctx.Done():
+						return errors.Wrapf(ctx.Err(), "error mounting %s into vault", secretEngine.Path)
+					case <-time.After(d):
+					}
 					continue
 				}
 				b.Reset()
 				break // if successful, break out of the loop
 			}
+			v.ownership.add(ownedSecrets, secretEngine.Path)
 		} else {
 			// If the secret engine is already mounted, only update its config in place.
 			slog.Info(fmt.Sprintf("tuning already existing secret engine %s/", secretEngine.Path))
 			for {
-				err = v.cl.Sys().TuneMount(secretEngine.Path, mountConfigInput)
+				err = v.cl.Sys().TuneMountWithContext(ctx, secretEngine.Path, mountConfigInput)
 				if err != nil {
 					d := b.Duration()
 					slog.Info(fmt.Sprintf("error tuning %s: %s, waiting %s before trying again...", secretEngine.Path, err.Error(), d))
@@ -242,7 +284,11 @@ func (v *vault) addManagedSecretsEngines(managedSecretsEngines []secretEngine) e
 						// Stop retrying after reaching the max backoff time
 						return errors.Wrapf(err, "error mounting %s into vault after several attempts", secretEngine.Path)
 					}
-					time.Sleep(d)
+					select {
+					case <-ctx.Done():
+						return errors.Wrapf(ctx.Err(), "error tuning %s", secretEngine.Path)
+					case <-time.After(d):
+					}
 					continue
 				}
 				b.Reset()
@@ -302,7 +348,7 @@ func (v *vault) addManagedSecretsEngines(managedSecretsEngines []secretEngine) e
 					secretExists := false
 					if configOption == "root/generate" { // the pki generate call is a different beast
 						req := v.cl.NewRequest("GET", fmt.Sprintf("/v1/%s/ca", secretEngine.Path))
-						resp, err := v.cl.RawRequestWithContext(context.Background(), req) //nolint
+						resp, err := v.cl.RawRequestWithContext(ctx, req) //nolint
 						if resp != nil {
 							defer resp.Body.Close()
 						}
@@ -313,7 +359,7 @@ func (v *vault) addManagedSecretsEngines(managedSecretsEngines []secretEngine) e
 							secretExists = true
 						}
 					} else {
-						secret, err := v.cl.Logical().Read(configPath)
+						secret, err := v.cl.Logical().ReadWithContext(ctx, configPath)
 						if err != nil {
 							return errors.Wrapf(err, "error reading configPath %s", configPath)
 						}
@@ -333,7 +379,7 @@ func (v *vault) addManagedSecretsEngines(managedSecretsEngines []secretEngine) e
 				}
 
 				if shouldUpdate {
-					sec, err := v.writeWithWarningCheck(configPath, subConfigData)
+					sec, err := v.writeWithWarningCheck(ctx, configPath, subConfigData)
 					if err != nil {
 						if isOverwriteProhibitedError(err) {
 							slog.Info(fmt.Sprintf("can't reconfigure %s, please delete it manually", configPath))
@@ -344,7 +390,7 @@ func (v *vault) addManagedSecretsEngines(managedSecretsEngines []secretEngine) e
 					}
 
 					if saveTo != "" {
-						_, err = v.writeWithWarningCheck(saveTo, vaultpkg.NewData(0, sec.Data))
+						_, err = v.writeWithWarningCheck(ctx, saveTo, vaultpkg.NewData(0, sec.Data))
 						if err != nil {
 							return errors.Wrapf(err, "error saving secret in vault to %s", saveTo)
 						}
@@ -363,7 +409,7 @@ func (v *vault) addManagedSecretsEngines(managedSecretsEngines []secretEngine) e
 					if name != nil {
 						nameStr = name.(string)
 					}
-					err = v.rotateSecretEngineCredentials(secretEngine.Type, secretEngine.Path, nameStr, configPath)
+					err = v.rotateSecretEngineCredentials(ctx, secretEngine.Type, secretEngine.Path, nameStr, configPath)
 					if err != nil {
 						return errors.Wrapf(err, "error rotating credentials for '%s' config in vault", configPath)
 					}
@@ -375,7 +421,121 @@ func (v *vault) addManagedSecretsEngines(managedSecretsEngines []secretEngine) e
 	return nil
 }
 
-func (v *vault) removeUnmanagedSecretsEngines(unmanagedSecretsEngines map[string]bool) error {
+// prunableConfigOptions returns the configuration options of the secret engine which can be listed and are not
+// destructive, with the names of their entries in the configuration. The options are pruned even if they are no
+// longer in the configuration, so all of their entries are removed when the last one is dropped from the file.
+func prunableConfigOptions(secretEngine secretEngine) (map[string]map[string]bool, error) {
+	configOptions := map[string]map[string]bool{}
+
+	for _, configOption := range listableConfigOptions[secretEngine.Type] {
+		if destructiveConfigOptions[secretEngine.Type][configOption] {
+			continue
+		}
+
+		names := map[string]bool{}
+
+		rawConfigData, ok := secretEngine.Configuration[configOption]
+		if !ok {
+			configOptions[configOption] = names
+			continue
+		}
+
+		configData, err := cast.ToSliceE(rawConfigData)
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting config data for secret engine")
+		}
+
+		for _, subConfigData := range configData {
+			subConfigData, err := cast.ToStringMapE(subConfigData)
+			if err != nil {
+				return nil, errors.Wrap(err, "error converting sub config data for secret engine")
+			}
+
+			name, ok := subConfigData["name"]
+			if !ok {
+				break
+			}
+			names[cast.ToString(name)] = true
+		}
+
+		// The managed entries must be known to prune the others
+		if len(names) == len(configData) {
+			configOptions[configOption] = names
+		}
+	}
+
+	return configOptions, nil
+}
+
+// prunesSecretsEngine tells whether the entries of the configuration options of the secret engine which are not
+// in the configuration are removed
+func (v *vault) prunesSecretsEngine(secretEngine secretEngine) bool {
+	purge := v.externalConfig.PurgeUnmanagedConfig
+	if !purge.Enabled || purge.Exclude.Secrets || !secretEngine.PurgeUnmanaged {
+		return false
+	}
+
+	// Only the entries of the secret engines created by the configuration are removed if the ownership is tracked
+	return v.ownership == nil || v.ownership.owns(ownedSecrets, secretEngine.Path)
+}
+
+// getUnmanagedSecretsEngineConfigs gets the paths of the entries of the configuration options of the secret
+// engine, like database connections and roles, which are in Vault but not in the configuration
+func (v *vault) getUnmanagedSecretsEngineConfigs(ctx context.Context, secretEngine secretEngine) ([]string, error) {
+	configOptions, err := prunableConfigOptions(secretEngine)
+	if err != nil {
+		return nil, err
+	}
+
+	var unmanagedConfigs []string
+	for _, configOption := range sortedKeys(configOptions) {
+		listPath := fmt.Sprintf("%s/%s", secretEngine.Path, configOption)
+
+		existingNames, err := v.listKeys(ctx, listPath)
+		if isResponseStatus(err, http.StatusMethodNotAllowed) {
+			slog.Debug(fmt.Sprintf("%s can't be listed, its entries won't be removed", listPath))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range existingNames {
+			// Nested paths are not entries of the option
+			if !configOptions[configOption][name] && !strings.HasSuffix(name, "/") {
+				unmanagedConfigs = append(unmanagedConfigs, listPath+"/"+name)
+			}
+		}
+	}
+
+	return unmanagedConfigs, nil
+}
+
+// removeUnmanagedSecretsEngineConfigs removes the entries of the configuration options of the managed secret
+// engines which are not in the configuration
+func (v *vault) removeUnmanagedSecretsEngineConfigs(ctx context.Context, managedSecretsEngines []secretEngine) error {
+	for _, secretEngine := range managedSecretsEngines {
+		if !v.prunesSecretsEngine(secretEngine) {
+			continue
+		}
+
+		unmanagedConfigs, err := v.getUnmanagedSecretsEngineConfigs(ctx, secretEngine)
+		if err != nil {
+			return errors.Wrapf(err, "error listing the configuration of %s secret engine", secretEngine.Path)
+		}
+
+		for _, configPath := range unmanagedConfigs {
+			slog.Info(fmt.Sprintf("removing secret engine config %s", configPath))
+			if _, err := v.cl.Logical().DeleteWithContext(ctx, configPath); err != nil {
+				return errors.Wrapf(err, "error removing %s config from vault", configPath)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *vault) removeUnmanagedSecretsEngines(ctx context.Context, unmanagedSecretsEngines map[string]bool) error {
 	if len(unmanagedSecretsEngines) == 0 || !v.externalConfig.PurgeUnmanagedConfig.Enabled ||
 		v.externalConfig.PurgeUnmanagedConfig.Exclude.Secrets {
 		return nil
@@ -383,23 +543,27 @@ func (v *vault) removeUnmanagedSecretsEngines(unmanagedSecretsEngines map[string
 
 	for secretEnginePath := range unmanagedSecretsEngines {
 		slog.Info(fmt.Sprintf("removing secret engine path %s ", secretEnginePath))
-		if err := v.cl.Sys().Unmount(secretEnginePath); err != nil {
+		err := v.cl.Sys().UnmountWithContext(ctx, secretEnginePath)
+		v.cache.invalidateMounts()
+		if err != nil {
 			return errors.Wrapf(err, "error unmounting %s secret engine from vault", secretEnginePath)
 		}
+		v.ownership.remove(ownedSecrets, secretEnginePath)
 	}
 
 	return nil
 }
 
-func (v *vault) configureSecretsEngines() error {
+func (v *vault) purgeSecretsEngines(ctx context.Context) error {
 	managedSecretsEngines := initSecretsEnginesConfig(v.externalConfig.Secrets)
-	unmanagedSecretsEngines := v.getUnmanagedSecretsEngines(managedSecretsEngines)
 
-	if err := v.addManagedSecretsEngines(managedSecretsEngines); err != nil {
-		return errors.Wrap(err, "error adding secrets engines")
+	if err := v.removeUnmanagedSecretsEngineConfigs(ctx, managedSecretsEngines); err != nil {
+		return errors.Wrap(err, "error removing unmanaged secrets engine configs")
 	}
 
-	if err := v.removeUnmanagedSecretsEngines(unmanagedSecretsEngines); err != nil {
+	unmanagedSecretsEngines := v.getUnmanagedSecretsEngines(ctx, managedSecretsEngines)
+
+	if err := v.removeUnmanagedSecretsEngines(ctx, unmanagedSecretsEngines); err != nil {
 		return errors.Wrap(err, "error removing secrets engines")
 	}
 
@@ -0,0 +1,114 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUnmanagedSecretsEngineConfigs(t *testing.T) {
+	v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/database/config":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["mysql", "postgres"]}}`))
+		case "/v1/database/roles":
+			_, _ = w.Write([]byte(`{"data": {"keys": ["app", "legacy", "nested/"]}}`))
+		case "/v1/database/static-roles":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	database := secretEngine{
+		Path: "database",
+		Type: "database",
+		Configuration: map[string]interface{}{
+			"config": []interface{}{map[string]interface{}{"name": "mysql"}},
+			"roles":  []interface{}{map[string]interface{}{"name": "app"}},
+		},
+	}
+
+	unmanagedConfigs, err := v.getUnmanagedSecretsEngineConfigs(context.Background(), database)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"database/config/postgres", "database/roles/legacy"}, unmanagedConfigs)
+}
+
+func TestPrunableConfigOptions(t *testing.T) {
+	pki := secretEngine{
+		Path: "pki",
+		Type: "pki",
+		Configuration: map[string]interface{}{
+			"roles":         []interface{}{map[string]interface{}{"name": "server"}},
+			"root/generate": []interface{}{map[string]interface{}{"name": "internal"}},
+			"config/urls":   []interface{}{map[string]interface{}{"issuing_certificates": "https://vault/v1/pki/ca"}},
+		},
+	}
+
+	configOptions, err := prunableConfigOptions(pki)
+	require.NoError(t, err)
+
+	// The CA is never removed and the options without names can't be listed
+	assert.Equal(t, map[string]map[string]bool{"roles": {"server": true}}, configOptions)
+
+	transit := secretEngine{
+		Path: "transit",
+		Type: "transit",
+		Configuration: map[string]interface{}{
+			"keys": []interface{}{map[string]interface{}{"name": "app"}},
+		},
+	}
+
+	configOptions, err = prunableConfigOptions(transit)
+	require.NoError(t, err)
+
+	assert.Empty(t, configOptions)
+
+	// The options which are no longer in the configuration are pruned entirely
+	database := secretEngine{
+		Path: "database",
+		Type: "database",
+		Configuration: map[string]interface{}{
+			"config": []interface{}{map[string]interface{}{"name": "mysql"}},
+		},
+	}
+
+	configOptions, err = prunableConfigOptions(database)
+	require.NoError(t, err)
+
+	expected := map[string]map[string]bool{
+		"config":       {"mysql": true},
+		"roles":        {},
+		"static-roles": {},
+	}
+	assert.Equal(t, expected, configOptions)
+}
+
+func TestAddManagedSecretsEnginesCanceled(t *testing.T) {
+	v := newTestVault(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sys/mounts":
+			_, _ = w.Write([]byte(`{"data": {}}`))
+		case "/v1/sys/mounts/database":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	v.cache = &configCache{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The mount is not retried once the configuration is canceled
+	err := v.addManagedSecretsEngines(ctx, []secretEngine{{Path: "database", Type: "database"}})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
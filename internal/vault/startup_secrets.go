@@ -124,44 +124,41 @@ func generateCertPayload(data interface{}) (map[string]interface{}, error) {
 	return map[string]interface{}{"pem_bundle": strings.Join(pkiSlice, "\n")}, nil
 }
 
-func (v *vault) configureStartupSecrets() error {
-	managedStartupSecrets := v.externalConfig.StartupSecrets
-	for _, startupSecret := range managedStartupSecrets {
-		switch startupSecret.Type {
-		case "kv":
-			path, data, err := readStartupSecret(startupSecret, v.externalConfig.Secrets)
-			if err != nil {
-				return errors.Wrap(err, "unable to read 'kv' startup secret")
-			}
-
-			if len(startupSecret.Data.Options) > 0 {
-				data["options"] = startupSecret.Data.Options
-			}
-
-			_, err = v.writeWithWarningCheck(path, data)
-			if err != nil {
-				return errors.Wrapf(err, "error writing data for startup 'kv' secret '%s'", path)
-			}
-
-		case "pki":
-			path, data, err := readStartupSecret(startupSecret, v.externalConfig.Secrets)
-			if err != nil {
-				return errors.Wrap(err, "unable to read 'pki' startup secret")
-			}
-
-			certData, err := generateCertPayload(data["data"])
-			if err != nil {
-				return errors.Wrap(err, "error generating 'pki' startup secret")
-			}
-
-			_, err = v.writeWithWarningCheck(path, certData)
-			if err != nil {
-				return errors.Wrapf(err, "error writing data for startup 'pki' secret '%s'", path)
-			}
-
-		default:
-			return errors.Errorf("'%s' startup secret type is not supported, only 'kv' or 'pki'", startupSecret.Type)
+func (v *vault) addStartupSecret(ctx context.Context, startupSecret startupSecret) error {
+	switch startupSecret.Type {
+	case "kv":
+		path, data, err := readStartupSecret(startupSecret, v.externalConfig.Secrets)
+		if err != nil {
+			return errors.Wrap(err, "unable to read 'kv' startup secret")
+		}
+
+		if len(startupSecret.Data.Options) > 0 {
+			data["options"] = startupSecret.Data.Options
+		}
+
+		_, err = v.writeWithWarningCheck(ctx, path, data)
+		if err != nil {
+			return errors.Wrapf(err, "error writing data for startup 'kv' secret '%s'", path)
+		}
+
+	case "pki":
+		path, data, err := readStartupSecret(startupSecret, v.externalConfig.Secrets)
+		if err != nil {
+			return errors.Wrap(err, "unable to read 'pki' startup secret")
+		}
+
+		certData, err := generateCertPayload(data["data"])
+		if err != nil {
+			return errors.Wrap(err, "error generating 'pki' startup secret")
 		}
+
+		_, err = v.writeWithWarningCheck(ctx, path, certData)
+		if err != nil {
+			return errors.Wrapf(err, "error writing data for startup 'pki' secret '%s'", path)
+		}
+
+	default:
+		return errors.Errorf("'%s' startup secret type is not supported, only 'kv' or 'pki'", startupSecret.Type)
 	}
 
 	return nil
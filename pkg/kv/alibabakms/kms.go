@@ -15,6 +15,8 @@
 package alibabakms
 
 import (
+	"context"
+
 	"emperror.dev/errors"
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/kms"
@@ -29,7 +31,10 @@ type alibabaKMS struct {
 	kmsID string
 }
 
-var _ kv.Service = &alibabaKMS{}
+var (
+	_ kv.Service        = &alibabaKMS{}
+	_ kv.ManagedService = &alibabaKMS{}
+)
 
 // New creates a new kv.Service encrypted by Alibaba KMS
 func New(regionID, accessKeyID, accessKeySecret, kmsID string, store kv.Service) (kv.Service, error) {
@@ -43,7 +48,12 @@ func New(regionID, accessKeyID, accessKeySecret, kmsID string, store kv.Service)
 	return &alibabaKMS{store: store, kmsClient: client, kmsID: kmsID}, nil
 }
 
-func (a *alibabaKMS) decrypt(cipherText []byte) ([]byte, error) {
+// The Alibaba KMS SDK doesn't accept a context, so it is checked before the call.
+func (a *alibabaKMS) decrypt(ctx context.Context, cipherText []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WrapIf(err, "failed to decrypt with KMS client")
+	}
+
 	request := kms.CreateDecryptRequest()
 	request.CiphertextBlob = string(cipherText)
 	response, err := a.kmsClient.Decrypt(request)
@@ -55,15 +65,23 @@ func (a *alibabaKMS) decrypt(cipherText []byte) ([]byte, error) {
 }
 
 func (a *alibabaKMS) Get(key string) ([]byte, error) {
-	cipherText, err := a.store.Get(key)
+	return a.GetContext(context.Background(), key)
+}
+
+func (a *alibabaKMS) GetContext(ctx context.Context, key string) ([]byte, error) {
+	cipherText, err := kv.WithContext(a.store).GetContext(ctx, key)
 	if err != nil {
 		return nil, errors.WrapIf(err, "failed to get first with KMS client")
 	}
 
-	return a.decrypt(cipherText)
+	return a.decrypt(ctx, cipherText)
 }
 
-func (a *alibabaKMS) encrypt(plainText []byte) ([]byte, error) {
+func (a *alibabaKMS) encrypt(ctx context.Context, plainText []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WrapIf(err, "failed to encrypt with KMS client")
+	}
+
 	request := kms.CreateEncryptRequest()
 	request.KeyId = a.kmsID
 	request.Plaintext = string(plainText)
@@ -76,10 +94,22 @@ func (a *alibabaKMS) encrypt(plainText []byte) ([]byte, error) {
 }
 
 func (a *alibabaKMS) Set(key string, val []byte) error {
-	cipherText, err := a.encrypt(val)
+	return a.SetContext(context.Background(), key, val)
+}
+
+func (a *alibabaKMS) SetContext(ctx context.Context, key string, val []byte) error {
+	cipherText, err := a.encrypt(ctx, val)
 	if err != nil {
 		return err
 	}
 
-	return a.store.Set(key, cipherText)
+	return kv.WithContext(a.store).SetContext(ctx, key, cipherText)
+}
+
+func (a *alibabaKMS) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	return kv.List(ctx, a.store, prefix)
+}
+
+func (a *alibabaKMS) DeleteContext(ctx context.Context, key string) error {
+	return kv.Delete(ctx, a.store, key)
 }
@@ -16,8 +16,10 @@ package alibabaoss
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"strings"
 
 	"emperror.dev/errors"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
@@ -31,6 +33,8 @@ type ossStorage struct {
 	prefix string
 }
 
+var _ kv.ManagedService = &ossStorage{}
+
 // New creates a new kv.Service backed by AWS S3
 func New(endpoint, accessKeyID, accessKeySecret, bucket, prefix string) (kv.Service, error) {
 	client, err := oss.New(endpoint, accessKeyID, accessKeySecret)
@@ -42,6 +46,10 @@ func New(endpoint, accessKeyID, accessKeySecret, bucket, prefix string) (kv.Serv
 }
 
 func (o *ossStorage) Set(key string, val []byte) error {
+	return o.SetContext(context.Background(), key, val)
+}
+
+func (o *ossStorage) SetContext(ctx context.Context, key string, val []byte) error {
 	objectKey := objectNameWithPrefix(o.prefix, key)
 
 	bucket, err := o.client.Bucket(o.bucket)
@@ -49,7 +57,7 @@ func (o *ossStorage) Set(key string, val []byte) error {
 		return err
 	}
 
-	if err := bucket.PutObject(objectKey, bytes.NewReader(val)); err != nil {
+	if err := bucket.PutObject(objectKey, bytes.NewReader(val), oss.WithContext(ctx)); err != nil {
 		return errors.Wrapf(err, "error writing key '%s' to OSS bucket '%s'", objectKey, o.bucket)
 	}
 
@@ -57,6 +65,10 @@ func (o *ossStorage) Set(key string, val []byte) error {
 }
 
 func (o *ossStorage) Get(key string) ([]byte, error) {
+	return o.GetContext(context.Background(), key)
+}
+
+func (o *ossStorage) GetContext(ctx context.Context, key string) ([]byte, error) {
 	objectKey := objectNameWithPrefix(o.prefix, key)
 
 	bucket, err := o.client.Bucket(o.bucket)
@@ -64,7 +76,7 @@ func (o *ossStorage) Get(key string) ([]byte, error) {
 		return nil, err
 	}
 
-	body, err := bucket.GetObject(objectKey)
+	body, err := bucket.GetObject(objectKey, oss.WithContext(ctx))
 	if err != nil {
 		var serviceErr oss.ServiceError
 		if errors.As(err, &serviceErr) && serviceErr.StatusCode == 404 && serviceErr.Code == "NoSuchKey" {
@@ -84,6 +96,53 @@ func (o *ossStorage) Get(key string) ([]byte, error) {
 	return b, nil
 }
 
+func (o *ossStorage) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	bucket, err := o.client.Bucket(o.bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	marker := ""
+	for {
+		result, err := bucket.ListObjects(
+			oss.Prefix(objectNameWithPrefix(o.prefix, prefix)),
+			oss.Marker(marker),
+			oss.WithContext(ctx),
+		)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error listing objects with prefix '%s' in OSS bucket '%s'", prefix, o.bucket)
+		}
+
+		for _, object := range result.Objects {
+			keys = append(keys, strings.TrimPrefix(object.Key, o.prefix))
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+		marker = result.NextMarker
+	}
+
+	return kv.FilterKeys(keys, prefix), nil
+}
+
+func (o *ossStorage) DeleteContext(ctx context.Context, key string) error {
+	objectKey := objectNameWithPrefix(o.prefix, key)
+
+	bucket, err := o.client.Bucket(o.bucket)
+	if err != nil {
+		return err
+	}
+
+	// OSS doesn't report an error for missing objects
+	if err := bucket.DeleteObject(objectKey, oss.WithContext(ctx)); err != nil {
+		return errors.Wrapf(err, "error deleting key '%s' from OSS bucket '%s'", objectKey, o.bucket)
+	}
+
+	return nil
+}
+
 func objectNameWithPrefix(prefix, key string) string {
 	return fmt.Sprintf("%s%s", prefix, key)
 }
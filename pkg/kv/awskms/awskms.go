@@ -15,6 +15,7 @@
 package awskms
 
 import (
+	"context"
 	"strings"
 
 	"emperror.dev/errors"
@@ -40,7 +41,10 @@ type awsKMS struct {
 	encryptionContext map[string]*string
 }
 
-var _ kv.Service = &awsKMS{}
+var (
+	_ kv.Service        = &awsKMS{}
+	_ kv.ManagedService = &awsKMS{}
+)
 
 // NewWithSession creates a new kv.Service encrypted by AWS KMS with and existing AWS Session
 func NewWithSession(sess *session.Session, store kv.Service, kmsID string, encryptionContext map[string]string) (kv.Service, error) {
@@ -63,8 +67,8 @@ func New(store kv.Service, region string, kmsID string, encryptionContext map[st
 	return NewWithSession(sess, store, kmsID, encryptionContext)
 }
 
-func (a *awsKMS) decrypt(cipherText []byte) ([]byte, error) {
-	out, err := a.kmsService.Decrypt(&kms.DecryptInput{
+func (a *awsKMS) decrypt(ctx context.Context, cipherText []byte) ([]byte, error) {
+	out, err := a.kmsService.DecryptWithContext(ctx, &kms.DecryptInput{
 		KeyId:             aws.String(a.kmsID),
 		CiphertextBlob:    cipherText,
 		EncryptionContext: a.encryptionContext,
@@ -80,16 +84,20 @@ func (a *awsKMS) decrypt(cipherText []byte) ([]byte, error) {
 }
 
 func (a *awsKMS) Get(key string) ([]byte, error) {
-	cipherText, err := a.store.Get(key)
+	return a.GetContext(context.Background(), key)
+}
+
+func (a *awsKMS) GetContext(ctx context.Context, key string) ([]byte, error) {
+	cipherText, err := kv.WithContext(a.store).GetContext(ctx, key)
 	if err != nil {
 		return nil, errors.WrapIf(err, "failed to get data for KMS client")
 	}
 
-	return a.decrypt(cipherText)
+	return a.decrypt(ctx, cipherText)
 }
 
-func (a *awsKMS) encrypt(plainText []byte) ([]byte, error) {
-	out, err := a.kmsService.Encrypt(&kms.EncryptInput{
+func (a *awsKMS) encrypt(ctx context.Context, plainText []byte) ([]byte, error) {
+	out, err := a.kmsService.EncryptWithContext(ctx, &kms.EncryptInput{
 		KeyId:             aws.String(a.kmsID),
 		Plaintext:         plainText,
 		EncryptionContext: a.encryptionContext,
@@ -103,10 +111,22 @@ func (a *awsKMS) encrypt(plainText []byte) ([]byte, error) {
 }
 
 func (a *awsKMS) Set(key string, val []byte) error {
-	cipherText, err := a.encrypt(val)
+	return a.SetContext(context.Background(), key, val)
+}
+
+func (a *awsKMS) SetContext(ctx context.Context, key string, val []byte) error {
+	cipherText, err := a.encrypt(ctx, val)
 	if err != nil {
 		return err
 	}
 
-	return a.store.Set(key, cipherText)
+	return kv.WithContext(a.store).SetContext(ctx, key, cipherText)
+}
+
+func (a *awsKMS) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	return kv.List(ctx, a.store, prefix)
+}
+
+func (a *awsKMS) DeleteContext(ctx context.Context, key string) error {
+	return kv.Delete(ctx, a.store, key)
 }
@@ -51,7 +51,10 @@ type AuthConfig struct {
 	ClientSecret string `json:"clientSecret"`
 }
 
-var _ kv.Service = &azureKeyVault{}
+var (
+	_ kv.Service        = &azureKeyVault{}
+	_ kv.ManagedService = &azureKeyVault{}
+)
 
 // New creates a new kv.Service backed by Azure Key Vault
 func New(name string) (kv.Service, error) {
@@ -77,7 +80,11 @@ func New(name string) (kv.Service, error) {
 }
 
 func (a *azureKeyVault) Get(key string) ([]byte, error) {
-	bundle, err := a.client.GetSecret(context.Background(), key, "", nil)
+	return a.GetContext(context.Background(), key)
+}
+
+func (a *azureKeyVault) GetContext(ctx context.Context, key string) ([]byte, error) {
+	bundle, err := a.client.GetSecret(ctx, key, "", nil)
 	if err != nil {
 		var aerr *azcore.ResponseError
 		if errors.As(err, &aerr) && aerr.StatusCode == http.StatusNotFound {
@@ -91,12 +98,52 @@ func (a *azureKeyVault) Get(key string) ([]byte, error) {
 }
 
 func (a *azureKeyVault) Set(key string, val []byte) error {
+	return a.SetContext(context.Background(), key, val)
+}
+
+func (a *azureKeyVault) SetContext(ctx context.Context, key string, val []byte) error {
 	value := string(val)
 	parameters := azsecrets.SetSecretParameters{Value: &value}
-	_, err := a.client.SetSecret(context.Background(), key, parameters, nil)
+	_, err := a.client.SetSecret(ctx, key, parameters, nil)
 	return errors.Wrapf(err, "failed to set key: %s", key)
 }
 
+func (a *azureKeyVault) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+
+	pager := a.client.NewListSecretPropertiesPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list keys with prefix: %s", prefix)
+		}
+
+		for _, secret := range page.Value {
+			if secret.ID != nil {
+				keys = append(keys, secret.ID.Name())
+			}
+		}
+	}
+
+	return kv.FilterKeys(keys, prefix), nil
+}
+
+// DeleteContext deletes the secret, if soft-delete is enabled on the Key Vault
+// it is still recoverable until the retention period is over.
+func (a *azureKeyVault) DeleteContext(ctx context.Context, key string) error {
+	_, err := a.client.DeleteSecret(ctx, key, nil)
+	if err != nil {
+		var aerr *azcore.ResponseError
+		if errors.As(err, &aerr) && aerr.StatusCode == http.StatusNotFound {
+			return nil
+		}
+
+		return errors.Wrapf(err, "failed to delete key: %s", key)
+	}
+
+	return nil
+}
+
 type AzureAuthCredentials struct {
 	creds map[string]azcore.TokenCredential
 }
@@ -15,6 +15,7 @@
 package dev
 
 import (
+	"context"
 	"os"
 
 	"emperror.dev/errors"
@@ -26,6 +27,8 @@ type dev struct {
 	rootToken []byte
 }
 
+var _ kv.ContextService = &dev{}
+
 // New creates a new kv.Service backed by memory, only the root token is stored, should be used with: vault server -dev
 func New() (service kv.Service, err error) {
 	rootToken := []byte(os.Getenv("VAULT_TOKEN"))
@@ -46,6 +49,14 @@ func (d *dev) Set(_ string, _ []byte) error {
 	return nil
 }
 
+func (d *dev) SetContext(_ context.Context, key string, val []byte) error {
+	return d.Set(key, val)
+}
+
+func (d *dev) GetContext(_ context.Context, key string) ([]byte, error) {
+	return d.Get(key)
+}
+
 func (d *dev) Get(key string) ([]byte, error) {
 	if key == "vault-root" {
 		return d.rootToken, nil
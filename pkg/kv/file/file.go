@@ -15,6 +15,7 @@
 package file
 
 import (
+	"context"
 	"os"
 	"path"
 
@@ -27,6 +28,8 @@ type file struct {
 	path string
 }
 
+var _ kv.ManagedService = &file{}
+
 // New creates a new kv.Service backed by files, without any encryption
 func New(path string) (service kv.Service, err error) {
 	service = &file{path: path}
@@ -35,10 +38,26 @@ func New(path string) (service kv.Service, err error) {
 }
 
 func (f *file) Set(key string, val []byte) error {
+	return f.SetContext(context.Background(), key, val)
+}
+
+func (f *file) SetContext(ctx context.Context, key string, val []byte) error {
+	if err := ctx.Err(); err != nil {
+		return errors.WrapIff(err, "failed to write file for key: %s", key)
+	}
+
 	return os.WriteFile(path.Join(f.path, key), val, 0600)
 }
 
 func (f *file) Get(key string) ([]byte, error) {
+	return f.GetContext(context.Background(), key)
+}
+
+func (f *file) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WrapIff(err, "failed to read file for key: %s", key)
+	}
+
 	val, err := os.ReadFile(path.Join(f.path, key))
 	if os.IsNotExist(err) {
 		return nil, kv.NewNotFoundError("key '%s' is not present in file", key)
@@ -46,3 +65,36 @@ func (f *file) Get(key string) ([]byte, error) {
 
 	return val, errors.WrapIff(err, "failed to read file for key: %s", key)
 }
+
+func (f *file) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WrapIff(err, "failed to list files with prefix: %s", prefix)
+	}
+
+	entries, err := os.ReadDir(f.path)
+	if err != nil {
+		return nil, errors.WrapIff(err, "failed to list files with prefix: %s", prefix)
+	}
+
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			keys = append(keys, entry.Name())
+		}
+	}
+
+	return kv.FilterKeys(keys, prefix), nil
+}
+
+func (f *file) DeleteContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return errors.WrapIff(err, "failed to delete file for key: %s", key)
+	}
+
+	err := os.Remove(path.Join(f.path, key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return errors.WrapIff(err, "failed to delete file for key: %s", key)
+}
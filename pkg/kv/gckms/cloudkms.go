@@ -38,7 +38,10 @@ type googleKms struct {
 	keyPath string
 }
 
-var _ kv.Service = &googleKms{}
+var (
+	_ kv.Service        = &googleKms{}
+	_ kv.ManagedService = &googleKms{}
+)
 
 // New creates a new kv.Service encrypted by Google KMS
 func New(store kv.Service, project, location, keyring, cryptoKey string) (kv.Service, error) {
@@ -60,10 +63,10 @@ func New(store kv.Service, project, location, keyring, cryptoKey string) (kv.Ser
 	}, nil
 }
 
-func (g *googleKms) encrypt(s []byte) ([]byte, error) {
+func (g *googleKms) encrypt(ctx context.Context, s []byte) ([]byte, error) {
 	resp, err := g.svc.Projects.Locations.KeyRings.CryptoKeys.Encrypt(g.keyPath, &cloudkms.EncryptRequest{
 		Plaintext: base64.StdEncoding.EncodeToString(s),
-	}).Do()
+	}).Context(ctx).Do()
 	if err != nil {
 		return nil, errors.Wrap(err, "error encrypting data")
 	}
@@ -71,10 +74,10 @@ func (g *googleKms) encrypt(s []byte) ([]byte, error) {
 	return base64.StdEncoding.DecodeString(resp.Ciphertext)
 }
 
-func (g *googleKms) decrypt(s []byte) ([]byte, error) {
+func (g *googleKms) decrypt(ctx context.Context, s []byte) ([]byte, error) {
 	resp, err := g.svc.Projects.Locations.KeyRings.CryptoKeys.Decrypt(g.keyPath, &cloudkms.DecryptRequest{
 		Ciphertext: base64.StdEncoding.EncodeToString(s),
-	}).Do()
+	}).Context(ctx).Do()
 	if err != nil {
 		return nil, errors.Wrap(err, "error decrypting data")
 	}
@@ -83,23 +86,40 @@ func (g *googleKms) decrypt(s []byte) ([]byte, error) {
 }
 
 func (g *googleKms) Get(key string) ([]byte, error) {
-	cipherText, err := g.store.Get(key)
+	return g.GetContext(context.Background(), key)
+}
+
+func (g *googleKms) GetContext(ctx context.Context, key string) ([]byte, error) {
+	cipherText, err := kv.WithContext(g.store).GetContext(ctx, key)
 	if err != nil {
 		return nil, errors.Wrap(err, "error getting data")
 	}
 
-	return g.decrypt(cipherText)
+	return g.decrypt(ctx, cipherText)
 }
 
 func (g *googleKms) Set(key string, val []byte) error {
+	return g.SetContext(context.Background(), key, val)
+}
+
+func (g *googleKms) SetContext(ctx context.Context, key string, val []byte) error {
+	store := kv.WithContext(g.store)
 	if !strings.HasPrefix(key, "keybase:") { //already encrypted by PGP key
 		slog.Info("Encrypting data with Google KMS")
-		cipherText, err := g.encrypt(val)
+		cipherText, err := g.encrypt(ctx, val)
 		if err != nil {
 			return errors.Wrap(err, "error setting data")
 		}
-		return g.store.Set(key, cipherText)
+		return store.SetContext(ctx, key, cipherText)
 	}
 	slog.Info("Data is already encrypted with PGP key, skipping encryption with Google KMS")
-	return g.store.Set(key, val)
+	return store.SetContext(ctx, key, val)
+}
+
+func (g *googleKms) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	return kv.List(ctx, g.store, prefix)
+}
+
+func (g *googleKms) DeleteContext(ctx context.Context, key string) error {
+	return kv.Delete(ctx, g.store, key)
 }
@@ -15,6 +15,7 @@
 package hsm
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -44,6 +45,11 @@ type hsmCrypto struct {
 	decrypt    cryptoFunc
 }
 
+var (
+	_ kv.ManagedService = &hsmCrypto{}
+	_ kv.ManagedService = &hsmStorage{}
+)
+
 // Config holds the HSM access information
 type Config struct {
 	ModulePath string
@@ -236,11 +242,20 @@ func New(config Config, storage kv.Service) (kv.Service, error) {
 }
 
 func (h *hsmCrypto) Get(key string) ([]byte, error) {
-	ciphertext, err := h.storage.Get(key)
+	return h.GetContext(context.Background(), key)
+}
+
+func (h *hsmCrypto) GetContext(ctx context.Context, key string) ([]byte, error) {
+	ciphertext, err := kv.WithContext(h.storage).GetContext(ctx, key)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get data from storage")
 	}
 
+	// PKCS#11 calls can't be interrupted, so the context is checked before them.
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WrapIf(err, "can't decrypt data with HSM")
+	}
+
 	plaintext, err := h.decrypt(ciphertext)
 	if err != nil {
 		return nil, errors.WrapIf(err, "can't decrypt data with HSM")
@@ -250,12 +265,28 @@ func (h *hsmCrypto) Get(key string) ([]byte, error) {
 }
 
 func (h *hsmCrypto) Set(key string, value []byte) error {
+	return h.SetContext(context.Background(), key, value)
+}
+
+func (h *hsmCrypto) SetContext(ctx context.Context, key string, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return errors.WrapIf(err, "can't encrypt data with HSM")
+	}
+
 	ciphertext, err := h.encrypt(value)
 	if err != nil {
 		return errors.WrapIf(err, "can't encrypt data with HSM")
 	}
 
-	return h.storage.Set(key, ciphertext)
+	return kv.WithContext(h.storage).SetContext(ctx, key, ciphertext)
+}
+
+func (h *hsmCrypto) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	return kv.List(ctx, h.storage, prefix)
+}
+
+func (h *hsmCrypto) DeleteContext(ctx context.Context, key string) error {
+	return kv.Delete(ctx, h.storage, key)
 }
 
 /*
@@ -297,6 +328,14 @@ type hsmStorage struct {
 }
 
 func (h *hsmStorage) Get(key string) ([]byte, error) {
+	return h.GetContext(context.Background(), key)
+}
+
+func (h *hsmStorage) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read object from HSM")
+	}
+
 	attributes := []*pkcs11.Attribute{
 		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
 		pkcs11.NewAttribute(pkcs11.CKA_LABEL, key),
@@ -315,6 +354,14 @@ func (h *hsmStorage) Get(key string) ([]byte, error) {
 }
 
 func (h *hsmStorage) Set(key string, value []byte) error {
+	return h.SetContext(context.Background(), key, value)
+}
+
+func (h *hsmStorage) SetContext(ctx context.Context, key string, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "failed to write object to HSM")
+	}
+
 	attributes := []*pkcs11.Attribute{
 		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
 		pkcs11.NewAttribute(pkcs11.CKA_VALUE, value),
@@ -325,3 +372,55 @@ func (h *hsmStorage) Set(key string, value []byte) error {
 
 	return errors.Wrap(err, "failed to write object to HSM")
 }
+
+func (h *hsmStorage) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to list objects in HSM")
+	}
+
+	attributes := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
+	}
+
+	objects, err := h.session.FindObjects(attributes)
+	if err != nil {
+		if err.Error() == noObjectsFoundErrMsg {
+			return []string{}, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to list objects in HSM")
+	}
+
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		label, err := object.Label()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read object label from HSM")
+		}
+		keys = append(keys, label)
+	}
+
+	return kv.FilterKeys(keys, prefix), nil
+}
+
+func (h *hsmStorage) DeleteContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "failed to delete object from HSM")
+	}
+
+	attributes := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, key),
+	}
+
+	object, err := h.session.FindObject(attributes)
+	if err != nil {
+		if err.Error() == noObjectsFoundErrMsg {
+			return nil
+		}
+
+		return errors.Wrap(err, "failed to delete object from HSM")
+	}
+
+	return errors.Wrap(object.Destroy(), "failed to delete object from HSM")
+}
@@ -42,6 +42,8 @@ type k8sStorage struct {
 	ownerReference *metav1.OwnerReference
 }
 
+var _ kv.ManagedService = &k8sStorage{}
+
 // New creates a new kv.Service backed by K8S Secrets
 func New(namespace, secret string, labels map[string]string) (kv.Service, error) {
 	kubeconfig := os.Getenv(clientcmd.RecommendedConfigPathEnvVar)
@@ -83,7 +85,11 @@ func New(namespace, secret string, labels map[string]string) (kv.Service, error)
 }
 
 func (k *k8sStorage) Set(key string, val []byte) error {
-	secret, err := k.client.CoreV1().Secrets(k.namespace).Get(context.Background(), k.secret, metav1.GetOptions{})
+	return k.SetContext(context.Background(), key, val)
+}
+
+func (k *k8sStorage) SetContext(ctx context.Context, key string, val []byte) error {
+	secret, err := k.client.CoreV1().Secrets(k.namespace).Get(ctx, k.secret, metav1.GetOptions{})
 
 	switch {
 	case k8serrors.IsNotFound(err):
@@ -98,13 +104,13 @@ func (k *k8sStorage) Set(key string, val []byte) error {
 		if k.ownerReference != nil {
 			secret.ObjectMeta.SetOwnerReferences([]metav1.OwnerReference{*k.ownerReference})
 		}
-		_, err = k.client.CoreV1().Secrets(k.namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+		_, err = k.client.CoreV1().Secrets(k.namespace).Create(ctx, secret, metav1.CreateOptions{})
 	case err == nil:
 		if secret.Data == nil {
 			secret.Data = map[string][]byte{}
 		}
 		secret.Data[key] = val
-		_, err = k.client.CoreV1().Secrets(k.namespace).Update(context.Background(), secret, metav1.UpdateOptions{})
+		_, err = k.client.CoreV1().Secrets(k.namespace).Update(ctx, secret, metav1.UpdateOptions{})
 	default:
 		return errors.Wrapf(err, "error checking if '%s' secret exists", k.secret)
 	}
@@ -117,7 +123,11 @@ func (k *k8sStorage) Set(key string, val []byte) error {
 }
 
 func (k *k8sStorage) Get(key string) ([]byte, error) {
-	secret, err := k.client.CoreV1().Secrets(k.namespace).Get(context.Background(), k.secret, metav1.GetOptions{})
+	return k.GetContext(context.Background(), key)
+}
+
+func (k *k8sStorage) GetContext(ctx context.Context, key string) ([]byte, error) {
+	secret, err := k.client.CoreV1().Secrets(k.namespace).Get(ctx, k.secret, metav1.GetOptions{})
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			return nil, kv.NewNotFoundError("error getting secret for key '%s': %s", key, err.Error())
@@ -133,3 +143,43 @@ func (k *k8sStorage) Get(key string) ([]byte, error) {
 
 	return val, nil
 }
+
+func (k *k8sStorage) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	secret, err := k.client.CoreV1().Secrets(k.namespace).Get(ctx, k.secret, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return []string{}, nil
+		}
+
+		return nil, errors.Wrapf(err, "error listing keys with prefix '%s' in secret '%s'", prefix, k.secret)
+	}
+
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+
+	return kv.FilterKeys(keys, prefix), nil
+}
+
+func (k *k8sStorage) DeleteContext(ctx context.Context, key string) error {
+	secret, err := k.client.CoreV1().Secrets(k.namespace).Get(ctx, k.secret, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+
+		return errors.Wrapf(err, "error checking if '%s' secret exists", k.secret)
+	}
+
+	if _, ok := secret.Data[key]; !ok {
+		return nil
+	}
+
+	delete(secret.Data, key)
+	if _, err = k.client.CoreV1().Secrets(k.namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrapf(err, "error deleting secret key '%s' from secret '%s'", key, k.secret)
+	}
+
+	return nil
+}
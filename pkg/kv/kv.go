@@ -15,7 +15,10 @@
 package kv
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"emperror.dev/errors"
 )
@@ -53,3 +56,98 @@ type Service interface {
 	Set(key string, value []byte) error
 	Get(key string) ([]byte, error)
 }
+
+// ContextService is the context-aware variant of Service. Implementations
+// should stop in-flight calls when the context is canceled or its deadline
+// is exceeded.
+type ContextService interface {
+	SetContext(ctx context.Context, key string, value []byte) error
+	GetContext(ctx context.Context, key string) ([]byte, error)
+}
+
+// WithContext returns a ContextService for the given Service. If the Service
+// implements ContextService natively it is returned as is, otherwise it is
+// wrapped so that the context is at least checked before each call.
+func WithContext(service Service) ContextService {
+	if cs, ok := service.(ContextService); ok {
+		return cs
+	}
+
+	return &contextAdapter{Service: service}
+}
+
+type contextAdapter struct {
+	Service
+}
+
+func (a *contextAdapter) SetContext(ctx context.Context, key string, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return errors.WrapIff(err, "error setting key '%s'", key)
+	}
+
+	return a.Set(key, value)
+}
+
+func (a *contextAdapter) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WrapIff(err, "error getting key '%s'", key)
+	}
+
+	return a.Get(key)
+}
+
+// ManagedService is a ContextService which can also enumerate and remove its
+// keys, so stale entries can be cleaned up. Deleting a key which is not
+// present is not an error.
+type ManagedService interface {
+	ContextService
+	ListContext(ctx context.Context, prefix string) ([]string, error)
+	DeleteContext(ctx context.Context, key string) error
+}
+
+// CompositeService is a Service which distributes its keys across multiple
+// Services, all of them have to be available to store every key.
+type CompositeService interface {
+	Service
+	Services() []Service
+}
+
+// List returns the sorted keys of the Service starting with the given prefix.
+// It returns an error if the Service doesn't implement ManagedService.
+func List(ctx context.Context, service Service, prefix string) ([]string, error) {
+	ms, ok := service.(ManagedService)
+	if !ok {
+		return nil, errors.Errorf("listing keys is not supported by %T", service)
+	}
+
+	return ms.ListContext(ctx, prefix)
+}
+
+// Delete removes the given key from the Service.
+// It returns an error if the Service doesn't implement ManagedService.
+func Delete(ctx context.Context, service Service, key string) error {
+	ms, ok := service.(ManagedService)
+	if !ok {
+		return errors.Errorf("deleting keys is not supported by %T", service)
+	}
+
+	return ms.DeleteContext(ctx, key)
+}
+
+// FilterKeys returns the sorted and de-duplicated keys starting with the given
+// prefix, it can be used by implementations which can't filter on the server side.
+func FilterKeys(keys []string, prefix string) []string {
+	seen := make(map[string]bool, len(keys))
+	filtered := []string{}
+
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) && !seen[key] {
+			seen[key] = true
+			filtered = append(filtered, key)
+		}
+	}
+
+	sort.Strings(filtered)
+
+	return filtered
+}
@@ -15,6 +15,7 @@
 package kv
 
 import (
+	"context"
 	"io"
 	"testing"
 
@@ -27,3 +28,102 @@ func TestIsNotFoundError(t *testing.T) {
 
 	assert.False(t, IsNotFoundError(io.EOF))
 }
+
+type inMemoryService struct {
+	data map[string][]byte
+}
+
+func (s *inMemoryService) Set(key string, value []byte) error {
+	s.data[key] = value
+	return nil
+}
+
+func (s *inMemoryService) Get(key string) ([]byte, error) {
+	value, ok := s.data[key]
+	if !ok {
+		return nil, NewNotFoundError("key '%s' not found", key)
+	}
+
+	return value, nil
+}
+
+func TestWithContext(t *testing.T) {
+	service := WithContext(&inMemoryService{data: map[string][]byte{}})
+
+	assert.NoError(t, service.SetContext(context.Background(), "key", []byte("value")))
+
+	value, err := service.GetContext(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	_, err = service.GetContext(context.Background(), "missing")
+	assert.True(t, IsNotFoundError(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = service.GetContext(ctx, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, service.SetContext(ctx, "key", []byte("other")), context.Canceled)
+
+	// Native ContextService implementations are returned as is
+	assert.Same(t, service, WithContext(service.(Service)))
+}
+
+type managedInMemoryService struct {
+	inMemoryService
+}
+
+func (s *managedInMemoryService) SetContext(_ context.Context, key string, value []byte) error {
+	return s.Set(key, value)
+}
+
+func (s *managedInMemoryService) GetContext(_ context.Context, key string) ([]byte, error) {
+	return s.Get(key)
+}
+
+func (s *managedInMemoryService) ListContext(_ context.Context, prefix string) ([]string, error) {
+	keys := make([]string, 0, len(s.data))
+	for key := range s.data {
+		keys = append(keys, key)
+	}
+
+	return FilterKeys(keys, prefix), nil
+}
+
+func (s *managedInMemoryService) DeleteContext(_ context.Context, key string) error {
+	delete(s.data, key)
+	return nil
+}
+
+func TestFilterKeys(t *testing.T) {
+	keys := []string{"vault-unseal-1", "vault-root", "vault-unseal-0", "vault-unseal-1", "other"}
+
+	assert.Equal(t, []string{"vault-unseal-0", "vault-unseal-1"}, FilterKeys(keys, "vault-unseal-"))
+	assert.Equal(t, []string{"other", "vault-root", "vault-unseal-0", "vault-unseal-1"}, FilterKeys(keys, ""))
+	assert.Empty(t, FilterKeys(keys, "missing"))
+}
+
+func TestListAndDelete(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := List(ctx, &inMemoryService{data: map[string][]byte{}}, "")
+	assert.Error(t, err)
+	assert.Error(t, Delete(ctx, &inMemoryService{data: map[string][]byte{}}, "key"))
+
+	service := &managedInMemoryService{inMemoryService{data: map[string][]byte{
+		"vault-root":     []byte("root"),
+		"vault-unseal-0": []byte("unseal"),
+	}}}
+
+	keys, err := List(ctx, service, "vault-unseal-")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vault-unseal-0"}, keys)
+
+	assert.NoError(t, Delete(ctx, service, "vault-unseal-0"))
+	assert.NoError(t, Delete(ctx, service, "vault-unseal-0"))
+
+	keys, err = List(ctx, service, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vault-root"}, keys)
+}
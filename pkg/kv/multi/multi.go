@@ -15,6 +15,7 @@
 package multi
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 
@@ -27,15 +28,21 @@ type multi struct {
 	services []kv.Service
 }
 
+var _ kv.ManagedService = &multi{}
+
 // New creates a new kv.Service backed by multiple kv.Services in a multi-write and single-read fashion.
 func New(services []kv.Service) kv.Service {
 	return &multi{services: services}
 }
 
 func (f *multi) Set(key string, val []byte) error {
+	return f.SetContext(context.Background(), key, val)
+}
+
+func (f *multi) SetContext(ctx context.Context, key string, val []byte) error {
 	slog.Info(fmt.Sprintf("setting key %q in all %d key/value Services", key, len(f.services)))
 	for _, service := range f.services {
-		err := service.Set(key, val)
+		err := kv.WithContext(service).SetContext(ctx, key, val)
 		if err != nil {
 			return err //nolint:wrapcheck
 		}
@@ -45,11 +52,20 @@ func (f *multi) Set(key string, val []byte) error {
 }
 
 func (f *multi) Get(key string) ([]byte, error) {
+	return f.GetContext(context.Background(), key)
+}
+
+func (f *multi) GetContext(ctx context.Context, key string) ([]byte, error) {
 	multiErr := errors.NewPlain("Can't find key in any of the backends")
 
 	for _, service := range f.services {
-		val, err := service.Get(key)
+		val, err := kv.WithContext(service).GetContext(ctx, key)
 		if err != nil {
+			// A canceled context would fail on every other backend as well.
+			if ctx.Err() != nil {
+				return nil, errors.WrapIf(ctx.Err(), "error getting key from key/value Services")
+			}
+
 			// Not found error means that they given object is not present, that is a hard error.
 			if kv.IsNotFoundError(err) {
 				return nil, err //nolint:wrapcheck
@@ -63,3 +79,37 @@ func (f *multi) Get(key string) ([]byte, error) {
 
 	return nil, multiErr //nolint:wrapcheck
 }
+
+// ListContext returns the union of the keys found in the key/value Services.
+func (f *multi) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	var multiErr error
+
+	for _, service := range f.services {
+		serviceKeys, err := kv.List(ctx, service, prefix)
+		if err != nil {
+			slog.Info(fmt.Sprintf("error listing keys in key/value Service, skipping it: %s", err))
+			multiErr = errors.Append(multiErr, err)
+
+			continue
+		}
+		keys = append(keys, serviceKeys...)
+	}
+
+	if multiErr != nil && len(keys) == 0 {
+		return nil, errors.WrapIf(multiErr, "error listing keys from key/value Services")
+	}
+
+	return kv.FilterKeys(keys, prefix), nil
+}
+
+func (f *multi) DeleteContext(ctx context.Context, key string) error {
+	slog.Info(fmt.Sprintf("deleting key %q from all %d key/value Services", key, len(f.services)))
+
+	var multiErr error
+	for _, service := range f.services {
+		multiErr = errors.Append(multiErr, kv.Delete(ctx, service, key))
+	}
+
+	return errors.WrapIf(multiErr, "error deleting key from key/value Services")
+}
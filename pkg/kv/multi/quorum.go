@@ -0,0 +1,192 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"emperror.dev/errors"
+
+	"github.com/bank-vaults/bank-vaults/pkg/kv"
+)
+
+type quorumService struct {
+	services []kv.Service
+	quorum   int
+}
+
+var _ kv.ManagedService = &quorumService{}
+
+// NewQuorum creates a new kv.Service backed by multiple kv.Services, where writes succeed
+// only if at least quorum Services acknowledge them and reads return the value agreed on
+// by at least quorum Services. Services returning a missing or divergent value on a read
+// are repaired with the agreed value.
+func NewQuorum(services []kv.Service, quorum int) (kv.Service, error) {
+	if quorum < 1 || quorum > len(services) {
+		return nil, errors.Errorf("quorum must be between 1 and the number of key/value Services (%d): %d", len(services), quorum)
+	}
+
+	return &quorumService{services: services, quorum: quorum}, nil
+}
+
+// result is the outcome of an operation on a single key/value Service
+type result struct {
+	value []byte
+	err   error
+}
+
+// each runs the operation on all key/value Services in parallel and returns the results in the order of the Services.
+func (q *quorumService) each(ctx context.Context, operation func(ctx context.Context, service kv.ContextService) ([]byte, error)) []result {
+	results := make([]result, len(q.services))
+
+	var wg sync.WaitGroup
+	for i, service := range q.services {
+		wg.Add(1)
+		go func(i int, service kv.ContextService) {
+			defer wg.Done()
+			value, err := operation(ctx, service)
+			results[i] = result{value: value, err: err}
+		}(i, kv.WithContext(service))
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (q *quorumService) Set(key string, val []byte) error {
+	return q.SetContext(context.Background(), key, val)
+}
+
+func (q *quorumService) SetContext(ctx context.Context, key string, val []byte) error {
+	slog.Info(fmt.Sprintf("setting key %q in %d key/value Services with a quorum of %d", key, len(q.services), q.quorum))
+
+	results := q.each(ctx, func(ctx context.Context, service kv.ContextService) ([]byte, error) {
+		return nil, service.SetContext(ctx, key, val)
+	})
+
+	acknowledged := 0
+	var multiErr error
+	for i, result := range results {
+		if result.err != nil {
+			slog.Warn(fmt.Sprintf("error setting key %q in key/value Service #%d: %s", key, i, result.err))
+			multiErr = errors.Append(multiErr, result.err)
+
+			continue
+		}
+		acknowledged++
+	}
+
+	if acknowledged < q.quorum {
+		return errors.WrapIff(multiErr, "key %q was acknowledged by %d key/value Services, quorum is %d", key, acknowledged, q.quorum)
+	}
+
+	return nil
+}
+
+func (q *quorumService) Get(key string) ([]byte, error) {
+	return q.GetContext(context.Background(), key)
+}
+
+func (q *quorumService) GetContext(ctx context.Context, key string) ([]byte, error) {
+	results := q.each(ctx, func(ctx context.Context, service kv.ContextService) ([]byte, error) {
+		return service.GetContext(ctx, key)
+	})
+
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WrapIf(err, "error getting key from key/value Services")
+	}
+
+	// Group the Services by the value they returned, in the order of the first occurrence
+	var values [][]byte
+	votes := map[string][]int{}
+	notFound := []int{}
+	var multiErr error
+
+	for i, result := range results {
+		switch {
+		case result.err == nil:
+			if _, ok := votes[string(result.value)]; !ok {
+				values = append(values, result.value)
+			}
+			votes[string(result.value)] = append(votes[string(result.value)], i)
+		case kv.IsNotFoundError(result.err):
+			notFound = append(notFound, i)
+		default:
+			slog.Warn(fmt.Sprintf("error getting key %q from key/value Service #%d: %s", key, i, result.err))
+			multiErr = errors.Append(multiErr, result.err)
+		}
+	}
+
+	var agreed []byte
+	found := false
+	for _, value := range values {
+		if len(votes[string(value)]) >= q.quorum {
+			agreed = value
+			found = true
+
+			break
+		}
+	}
+
+	if len(values) > 1 || (len(values) == 1 && len(notFound) > 0) {
+		slog.Warn(fmt.Sprintf("key %q diverges across key/value Services: %s", key, divergence(values, votes, notFound)))
+	}
+
+	if !found {
+		if len(notFound) >= q.quorum {
+			return nil, kv.NewNotFoundError("key %q not found in %d key/value Services", key, len(notFound))
+		}
+
+		return nil, errors.WrapIff(
+			errors.Append(multiErr, errors.NewPlain("no quorum")),
+			"no value of key %q is agreed on by %d key/value Services: %s", key, q.quorum, divergence(values, votes, notFound),
+		)
+	}
+
+	// Read-repair the Services which returned a missing or a divergent value
+	for i, result := range results {
+		if (result.err == nil && !bytes.Equal(result.value, agreed)) || kv.IsNotFoundError(result.err) {
+			slog.Info(fmt.Sprintf("repairing key %q in key/value Service #%d", key, i))
+			if err := kv.WithContext(q.services[i]).SetContext(ctx, key, agreed); err != nil {
+				slog.Warn(fmt.Sprintf("error repairing key %q in key/value Service #%d: %s", key, i, err))
+			}
+		}
+	}
+
+	return agreed, nil
+}
+
+// divergence describes which key/value Services returned which value, without revealing the values.
+func divergence(values [][]byte, votes map[string][]int, notFound []int) string {
+	description := ""
+	for i, value := range values {
+		description += fmt.Sprintf("value #%d from Services %v, ", i, votes[string(value)])
+	}
+
+	return description + fmt.Sprintf("missing from Services %v", notFound)
+}
+
+// ListContext returns the union of the keys found in the key/value Services.
+func (q *quorumService) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	return (&multi{services: q.services}).ListContext(ctx, prefix)
+}
+
+func (q *quorumService) DeleteContext(ctx context.Context, key string) error {
+	return (&multi{services: q.services}).DeleteContext(ctx, key)
+}
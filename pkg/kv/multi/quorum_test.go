@@ -0,0 +1,94 @@
+package multi
+
+import (
+	"testing"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/bank-vaults/pkg/kv"
+)
+
+type memoryService struct {
+	data map[string][]byte
+	err  error
+}
+
+func newMemoryService() *memoryService {
+	return &memoryService{data: map[string][]byte{}}
+}
+
+func (m *memoryService) Set(key string, value []byte) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.data[key] = value
+
+	return nil
+}
+
+func (m *memoryService) Get(key string) ([]byte, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	value, ok := m.data[key]
+	if !ok {
+		return nil, kv.NewNotFoundError("key '%s' not found", key)
+	}
+
+	return value, nil
+}
+
+func TestNewQuorum(t *testing.T) {
+	_, err := NewQuorum([]kv.Service{newMemoryService()}, 2)
+	assert.Error(t, err)
+
+	_, err = NewQuorum([]kv.Service{newMemoryService()}, 0)
+	assert.Error(t, err)
+}
+
+func TestQuorumSet(t *testing.T) {
+	a, b, c := newMemoryService(), newMemoryService(), newMemoryService()
+	c.err = errors.New("unavailable")
+
+	service, err := NewQuorum([]kv.Service{a, b, c}, 2)
+	require.NoError(t, err)
+
+	assert.NoError(t, service.Set("key", []byte("value")))
+	assert.Equal(t, []byte("value"), a.data["key"])
+
+	b.err = errors.New("unavailable")
+	assert.Error(t, service.Set("key", []byte("other")))
+}
+
+func TestQuorumGet(t *testing.T) {
+	a, b, c := newMemoryService(), newMemoryService(), newMemoryService()
+
+	service, err := NewQuorum([]kv.Service{a, b, c}, 2)
+	require.NoError(t, err)
+
+	_, err = service.Get("key")
+	assert.True(t, kv.IsNotFoundError(err))
+
+	// No value is agreed on by two Services
+	a.data["key"] = []byte("value")
+	b.data["key"] = []byte("stale")
+	c.data["other"] = []byte("value")
+	_, err = service.Get("key")
+	assert.Error(t, err)
+
+	// c diverges, it is repaired from the quorum
+	b.data["key"] = []byte("value")
+	c.data["key"] = []byte("stale")
+	value, err := service.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+	assert.Equal(t, []byte("value"), c.data["key"])
+
+	// c missed the write, it is repaired from the quorum
+	delete(c.data, "key")
+	_, err = service.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), c.data["key"])
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"strings"
 
 	"emperror.dev/errors"
 	"github.com/oracle/oci-go-sdk/v65/common"
@@ -37,6 +38,8 @@ type ociStorage struct {
 	prefix    string
 }
 
+var _ kv.ManagedService = &ociStorage{}
+
 // New creates a new kv.Service backed by Oracle OCI Object Storage
 func New(namespace, bucket, prefix string) (kv.Service, error) {
 	client, err := objectstorage.NewObjectStorageClientWithConfigurationProvider(common.DefaultConfigProvider())
@@ -48,7 +51,10 @@ func New(namespace, bucket, prefix string) (kv.Service, error) {
 }
 
 func (oci *ociStorage) Get(key string) ([]byte, error) {
-	ctx := context.Background()
+	return oci.GetContext(context.Background(), key)
+}
+
+func (oci *ociStorage) GetContext(ctx context.Context, key string) ([]byte, error) {
 	n := objectNameWithPrefix(oci.prefix, key)
 	request := objectstorage.GetObjectRequest{
 		NamespaceName: &oci.namespace,
@@ -81,7 +87,10 @@ func (oci *ociStorage) Get(key string) ([]byte, error) {
 }
 
 func (oci *ociStorage) Set(key string, val []byte) error {
-	ctx := context.Background()
+	return oci.SetContext(context.Background(), key, val)
+}
+
+func (oci *ociStorage) SetContext(ctx context.Context, key string, val []byte) error {
 	n := objectNameWithPrefix(oci.prefix, key)
 	request := objectstorage.PutObjectRequest{
 		NamespaceName: &oci.namespace,
@@ -97,6 +106,53 @@ func (oci *ociStorage) Set(key string, val []byte) error {
 	return nil
 }
 
+func (oci *ociStorage) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	n := objectNameWithPrefix(oci.prefix, prefix)
+	request := objectstorage.ListObjectsRequest{
+		NamespaceName: &oci.namespace,
+		BucketName:    &oci.bucket,
+		Prefix:        &n,
+	}
+
+	var keys []string
+	for {
+		response, err := oci.client.ListObjects(ctx, request)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error listing objects with prefix '%s'", n)
+		}
+
+		for _, object := range response.Objects {
+			keys = append(keys, strings.TrimPrefix(*object.Name, objectNameWithPrefix(oci.prefix, "")))
+		}
+
+		if response.NextStartWith == nil {
+			break
+		}
+		request.Start = response.NextStartWith
+	}
+
+	return kv.FilterKeys(keys, prefix), nil
+}
+
+func (oci *ociStorage) DeleteContext(ctx context.Context, key string) error {
+	n := objectNameWithPrefix(oci.prefix, key)
+	request := objectstorage.DeleteObjectRequest{
+		NamespaceName: &oci.namespace,
+		BucketName:    &oci.bucket,
+		ObjectName:    &n,
+	}
+	_, err := oci.client.DeleteObject(ctx, request)
+	if err != nil {
+		if failure, ok := common.IsServiceError(err); ok && failure.GetCode() == "ObjectNotFound" {
+			return nil
+		}
+
+		return errors.Wrapf(err, "error deleting object for key '%s'", n)
+	}
+
+	return nil
+}
+
 func objectNameWithPrefix(prefix, key string) string {
 	return fmt.Sprintf("%s/%s", prefix, key)
 }
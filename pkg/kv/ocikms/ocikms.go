@@ -33,7 +33,10 @@ type ociKms struct {
 	keyOCID string
 }
 
-var _ kv.Service = &ociKms{}
+var (
+	_ kv.Service        = &ociKms{}
+	_ kv.ManagedService = &ociKms{}
+)
 
 // New creates a new kv.Service encrypted by Oracle KMS
 func New(store kv.Service, keyOCID, endpoint string) (kv.Service, error) {
@@ -52,8 +55,7 @@ func New(store kv.Service, keyOCID, endpoint string) (kv.Service, error) {
 	}, nil
 }
 
-func (oci *ociKms) encrypt(b []byte) ([]byte, error) {
-	ctx := context.Background()
+func (oci *ociKms) encrypt(ctx context.Context, b []byte) ([]byte, error) {
 	request := keymanagement.EncryptRequest{
 		EncryptDataDetails: keymanagement.EncryptDataDetails{
 			KeyId:     &oci.keyOCID,
@@ -68,8 +70,7 @@ func (oci *ociKms) encrypt(b []byte) ([]byte, error) {
 	return []byte(*response.Ciphertext), nil
 }
 
-func (oci *ociKms) decrypt(b []byte) ([]byte, error) {
-	ctx := context.Background()
+func (oci *ociKms) decrypt(ctx context.Context, b []byte) ([]byte, error) {
 	request := keymanagement.DecryptRequest{
 		DecryptDataDetails: keymanagement.DecryptDataDetails{
 			KeyId:      &oci.keyOCID,
@@ -89,19 +90,35 @@ func (oci *ociKms) decrypt(b []byte) ([]byte, error) {
 }
 
 func (oci *ociKms) Get(key string) ([]byte, error) {
-	cipherText, err := oci.store.Get(key)
+	return oci.GetContext(context.Background(), key)
+}
+
+func (oci *ociKms) GetContext(ctx context.Context, key string) ([]byte, error) {
+	cipherText, err := kv.WithContext(oci.store).GetContext(ctx, key)
 	if err != nil {
 		return nil, errors.Wrap(err, "error getting data")
 	}
 
-	return oci.decrypt(cipherText)
+	return oci.decrypt(ctx, cipherText)
 }
 
 func (oci *ociKms) Set(key string, val []byte) error {
-	cipherText, err := oci.encrypt(val)
+	return oci.SetContext(context.Background(), key, val)
+}
+
+func (oci *ociKms) SetContext(ctx context.Context, key string, val []byte) error {
+	cipherText, err := oci.encrypt(ctx, val)
 	if err != nil {
 		return errors.Wrap(err, "error setting data")
 	}
 
-	return oci.store.Set(key, cipherText)
+	return kv.WithContext(oci.store).SetContext(ctx, key, cipherText)
+}
+
+func (oci *ociKms) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	return kv.List(ctx, oci.store, prefix)
+}
+
+func (oci *ociKms) DeleteContext(ctx context.Context, key string) error {
+	return kv.Delete(ctx, oci.store, key)
 }
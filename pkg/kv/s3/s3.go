@@ -16,8 +16,10 @@ package s3
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"strings"
 
 	"emperror.dev/errors"
 	"github.com/aws/aws-sdk-go/aws"
@@ -37,6 +39,8 @@ type s3Storage struct {
 	sseKeyID string
 }
 
+var _ kv.ManagedService = &s3Storage{}
+
 // New creates a new kv.Service backed by AWS S3
 func New(region, bucket, prefix, sseAlgo, sseKeyID string) (kv.Service, error) {
 	if region == "" {
@@ -63,6 +67,10 @@ func New(region, bucket, prefix, sseAlgo, sseKeyID string) (kv.Service, error) {
 }
 
 func (s3 *s3Storage) Set(key string, val []byte) error {
+	return s3.SetContext(context.Background(), key, val)
+}
+
+func (s3 *s3Storage) SetContext(ctx context.Context, key string, val []byte) error {
 	n := objectNameWithPrefix(s3.prefix, key)
 	input := awss3.PutObjectInput{
 		Bucket: aws.String(s3.bucket),
@@ -76,7 +84,7 @@ func (s3 *s3Storage) Set(key string, val []byte) error {
 		}
 	}
 
-	if _, err := s3.client.PutObject(&input); err != nil {
+	if _, err := s3.client.PutObjectWithContext(ctx, &input); err != nil {
 		return errors.Wrapf(err, "error writing key '%s' to s3 bucket '%s'", n, s3.bucket)
 	}
 
@@ -84,6 +92,10 @@ func (s3 *s3Storage) Set(key string, val []byte) error {
 }
 
 func (s3 *s3Storage) Get(key string) ([]byte, error) {
+	return s3.GetContext(context.Background(), key)
+}
+
+func (s3 *s3Storage) GetContext(ctx context.Context, key string) ([]byte, error) {
 	n := objectNameWithPrefix(s3.prefix, key)
 
 	input := awss3.GetObjectInput{
@@ -91,7 +103,7 @@ func (s3 *s3Storage) Get(key string) ([]byte, error) {
 		Key:    aws.String(n),
 	}
 
-	r, err := s3.client.GetObject(&input)
+	r, err := s3.client.GetObjectWithContext(ctx, &input)
 	if err != nil {
 		var aerr awserr.Error
 		if errors.As(err, &aerr) && aerr.Code() == awss3.ErrCodeNoSuchKey {
@@ -111,6 +123,43 @@ func (s3 *s3Storage) Get(key string) ([]byte, error) {
 	return b, nil
 }
 
+func (s3 *s3Storage) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	input := awss3.ListObjectsV2Input{
+		Bucket: aws.String(s3.bucket),
+		Prefix: aws.String(objectNameWithPrefix(s3.prefix, prefix)),
+	}
+
+	var keys []string
+	err := s3.client.ListObjectsV2PagesWithContext(ctx, &input, func(page *awss3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(aws.StringValue(object.Key), s3.prefix))
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing objects with prefix '%s' in s3 bucket '%s'", prefix, s3.bucket)
+	}
+
+	return kv.FilterKeys(keys, prefix), nil
+}
+
+func (s3 *s3Storage) DeleteContext(ctx context.Context, key string) error {
+	n := objectNameWithPrefix(s3.prefix, key)
+
+	input := awss3.DeleteObjectInput{
+		Bucket: aws.String(s3.bucket),
+		Key:    aws.String(n),
+	}
+
+	// S3 doesn't report an error for missing objects
+	if _, err := s3.client.DeleteObjectWithContext(ctx, &input); err != nil {
+		return errors.Wrapf(err, "error deleting key '%s' from s3 bucket '%s'", n, s3.bucket)
+	}
+
+	return nil
+}
+
 func objectNameWithPrefix(prefix, key string) string {
 	return fmt.Sprintf("%s%s", prefix, key)
 }
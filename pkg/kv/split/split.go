@@ -0,0 +1,110 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package split
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+
+	"emperror.dev/errors"
+
+	"github.com/bank-vaults/bank-vaults/pkg/kv"
+)
+
+// shareKey matches the unseal and recovery share keys (eg. vault-unseal-2 or keybase:user-vault-recovery-0)
+var shareKey = regexp.MustCompile(`(?:^|-)vault-(?:unseal|recovery)-(\d+)$`)
+
+type split struct {
+	services []kv.Service
+	mapping  map[int]int
+}
+
+var (
+	_ kv.ManagedService   = &split{}
+	_ kv.CompositeService = &split{}
+)
+
+// New creates a new kv.Service which distributes the unseal and recovery shares across multiple
+// kv.Services, so none of them holds enough shares alone. Share N is stored in the Service given
+// by mapping[N], or in Service N modulo the number of Services (round-robin) if it's not mapped.
+// Every other key (eg. the root token) is stored in the first Service.
+func New(services []kv.Service, mapping map[int]int) (kv.Service, error) {
+	if len(services) == 0 {
+		return nil, errors.New("at least one key/value Service is required")
+	}
+
+	for share, service := range mapping {
+		if share < 0 || service < 0 || service >= len(services) {
+			return nil, errors.Errorf("invalid mapping of share %d to key/value Service %d, there are %d Services", share, service, len(services))
+		}
+	}
+
+	return &split{services: services, mapping: mapping}, nil
+}
+
+// Services returns the kv.Services the keys are distributed across.
+func (s *split) Services() []kv.Service {
+	return s.services
+}
+
+// service returns the kv.Service responsible for the given key
+func (s *split) service(key string) kv.Service {
+	match := shareKey.FindStringSubmatch(key)
+	if match == nil {
+		return s.services[0]
+	}
+
+	share, _ := strconv.Atoi(match[1])
+	if service, ok := s.mapping[share]; ok {
+		return s.services[service]
+	}
+
+	return s.services[share%len(s.services)]
+}
+
+func (s *split) Set(key string, val []byte) error {
+	return s.SetContext(context.Background(), key, val)
+}
+
+func (s *split) SetContext(ctx context.Context, key string, val []byte) error {
+	return kv.WithContext(s.service(key)).SetContext(ctx, key, val)
+}
+
+func (s *split) Get(key string) ([]byte, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+func (s *split) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return kv.WithContext(s.service(key)).GetContext(ctx, key)
+}
+
+// ListContext returns the keys found in all of the key/value Services.
+func (s *split) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for i, service := range s.services {
+		serviceKeys, err := kv.List(ctx, service, prefix)
+		if err != nil {
+			return nil, errors.WrapIff(err, "error listing keys in key/value Service #%d", i)
+		}
+		keys = append(keys, serviceKeys...)
+	}
+
+	return kv.FilterKeys(keys, prefix), nil
+}
+
+func (s *split) DeleteContext(ctx context.Context, key string) error {
+	return kv.Delete(ctx, s.service(key), key)
+}
@@ -0,0 +1,48 @@
+package split
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/bank-vaults/pkg/kv"
+)
+
+type memoryService map[string][]byte
+
+func (m memoryService) Set(key string, value []byte) error {
+	m[key] = value
+	return nil
+}
+
+func (m memoryService) Get(key string) ([]byte, error) {
+	value, ok := m[key]
+	if !ok {
+		return nil, kv.NewNotFoundError("key '%s' not found", key)
+	}
+
+	return value, nil
+}
+
+func TestSplit(t *testing.T) {
+	a, b, c := memoryService{}, memoryService{}, memoryService{}
+
+	_, err := New([]kv.Service{a, b, c}, map[int]int{0: 3})
+	assert.Error(t, err)
+
+	service, err := New([]kv.Service{a, b, c}, map[int]int{0: 2, 1: 2})
+	require.NoError(t, err)
+
+	for _, key := range []string{"vault-root", "vault-unseal-0", "vault-unseal-1", "vault-unseal-2", "vault-unseal-4", "vault-recovery-3", "keybase:user-vault-unseal-1"} {
+		require.NoError(t, service.Set(key, []byte(key)))
+	}
+
+	assert.Equal(t, memoryService{"vault-root": []byte("vault-root"), "vault-recovery-3": []byte("vault-recovery-3")}, a)
+	assert.Equal(t, memoryService{"vault-unseal-4": []byte("vault-unseal-4")}, b)
+	assert.Len(t, c, 4)
+
+	value, err := service.Get("vault-unseal-2")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("vault-unseal-2"), value)
+}
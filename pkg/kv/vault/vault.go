@@ -15,8 +15,10 @@
 package vault
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"strings"
 
 	"emperror.dev/errors"
 	"github.com/bank-vaults/vault-sdk/vault"
@@ -30,6 +32,8 @@ type vaultStorage struct {
 	path   string
 }
 
+var _ kv.ManagedService = &vaultStorage{}
+
 // New creates a new kv.Service backed by Vault KV Version 2
 func New(addr, unsealKeysPath, role, authPath, tokenPath, token string) (kv.Service, error) {
 	client, err := vault.NewClientWithOptions(
@@ -49,9 +53,14 @@ func New(addr, unsealKeysPath, role, authPath, tokenPath, token string) (kv.Serv
 }
 
 func (v *vaultStorage) Set(key string, val []byte) error {
+	return v.SetContext(context.Background(), key, val)
+}
+
+func (v *vaultStorage) SetContext(ctx context.Context, key string, val []byte) error {
 	// Done to prevent overwrite in Vault
 	path := fmt.Sprintf("%s/%s", v.path, key)
-	if _, err := v.client.RawClient().Logical().Write(
+	if _, err := v.client.RawClient().Logical().WriteWithContext(
+		ctx,
 		path,
 		map[string]interface{}{
 			"data": map[string]interface{}{
@@ -66,8 +75,12 @@ func (v *vaultStorage) Set(key string, val []byte) error {
 }
 
 func (v *vaultStorage) Get(key string) ([]byte, error) {
+	return v.GetContext(context.Background(), key)
+}
+
+func (v *vaultStorage) GetContext(ctx context.Context, key string) ([]byte, error) {
 	path := fmt.Sprintf("%s/%s", v.path, key)
-	secret, err := v.client.RawClient().Logical().Read(path)
+	secret, err := v.client.RawClient().Logical().ReadWithContext(ctx, path)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error getting object for key '%s'", key)
 	}
@@ -82,3 +95,45 @@ func (v *vaultStorage) Get(key string) ([]byte, error) {
 
 	return base64.StdEncoding.DecodeString(data[key].(string))
 }
+
+func (v *vaultStorage) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	secret, err := v.client.RawClient().Logical().ListWithContext(ctx, metadataPath(v.path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing keys with prefix '%s' under path '%s'", prefix, v.path)
+	}
+	if secret == nil {
+		return []string{}, nil
+	}
+
+	var keys []string
+	for _, key := range cast.ToStringSlice(secret.Data["keys"]) {
+		// Skip sub-folders, keys are stored flat under the path
+		if !strings.HasSuffix(key, "/") {
+			keys = append(keys, key)
+		}
+	}
+
+	return kv.FilterKeys(keys, prefix), nil
+}
+
+// DeleteContext removes every version of the key together with its metadata.
+func (v *vaultStorage) DeleteContext(ctx context.Context, key string) error {
+	path := fmt.Sprintf("%s/%s", metadataPath(v.path), key)
+	if _, err := v.client.RawClient().Logical().DeleteWithContext(ctx, path); err != nil {
+		return errors.Wrapf(err, "error deleting key '%s' from vault addr %s and path '%s'", key, v.client.RawClient().Address(), v.path)
+	}
+
+	return nil
+}
+
+// metadataPath converts a KV Version 2 data path (eg. secret/data/unseal)
+// into the corresponding metadata path (eg. secret/metadata/unseal).
+// Only the segment after the mount is replaced, the mount itself may be called data.
+func metadataPath(dataPath string) string {
+	segments := strings.Split(strings.Trim(dataPath, "/"), "/")
+	if len(segments) > 1 && segments[1] == "data" {
+		segments[1] = "metadata"
+	}
+
+	return strings.Join(segments, "/")
+}
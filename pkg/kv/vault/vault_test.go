@@ -0,0 +1,20 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataPath(t *testing.T) {
+	tests := map[string]string{
+		"secret/data/unseal":    "secret/metadata/unseal",
+		"/secret/data/unseal/":  "secret/metadata/unseal",
+		"data/data/unseal":      "data/metadata/unseal",
+		"secret/data/data/keys": "secret/metadata/data/keys",
+	}
+
+	for dataPath, expected := range tests {
+		assert.Equal(t, expected, metadataPath(dataPath), dataPath)
+	}
+}